@@ -5,11 +5,24 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/attributes"
+	"github.com/adamstrickland/dapper-api/internal/audit"
 	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/emailchanges"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/imports"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/privacy"
+	"github.com/adamstrickland/dapper-api/internal/ratelimit"
 	"github.com/adamstrickland/dapper-api/internal/routes"
+	"github.com/adamstrickland/dapper-api/internal/rpc"
+	"github.com/adamstrickland/dapper-api/internal/security"
 	"github.com/adamstrickland/dapper-api/internal/users"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/xo/dburl"
@@ -66,6 +79,21 @@ func Migrate(cfg *config.Config) {
 
 	models := []interface{}{
 		&users.User{},
+		&users.Revision{},
+		&ratelimit.Bucket{},
+		&security.Revocation{},
+		&emailchanges.EmailChange{},
+		&invitations.Invitation{},
+		&invitations.Redemption{},
+		&audit.Entry{},
+		&attributes.Schema{},
+		&organizations.Organization{},
+		&organizations.Membership{},
+		&groups.Group{},
+		&groups.GroupMembership{},
+		&preferences.Preference{},
+		&privacy.Export{},
+		&privacy.Erasure{},
 	}
 
 	for _, m := range models {
@@ -73,6 +101,40 @@ func Migrate(cfg *config.Config) {
 	}
 
 	conn.DB.AutoMigrate(models...)
+
+	rebuilt, err := users.AutoIncrementIDs(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to stop user IDs from being reused: %e", err)
+	}
+
+	if rebuilt {
+		log.Print("  Rebuilt users with IDs that are never reused")
+	}
+
+	n, err := users.BackfillPublicIDs(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to backfill public user IDs: %e", err)
+	}
+
+	log.Printf("  Assigned public IDs to %d users", n)
+
+	n, err = users.BackfillRevisions(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to backfill user revisions: %e", err)
+	}
+
+	log.Printf("  Recorded first revisions of %d users", n)
+
+	index, err := users.EnsureSearchIndex(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to prepare the user search index: %e", err)
+	}
+
+	log.Printf("  Prepared %s user search", index)
 	log.Print("Migration complete")
 }
 
@@ -86,7 +148,173 @@ func Bootstrap(cfg *config.Config, reset bool) {
 	Migrate(cfg)
 }
 
+func Reindex(cfg *config.Config) {
+	index, err := users.EnsureSearchIndex(cfg)
+
+	if err == nil {
+		err = users.RebuildSearchIndex(cfg)
+	}
+
+	if err != nil {
+		log.Fatalf("Unable to rebuild the user search index: %e", err)
+	}
+
+	log.Printf("Rebuilt %s user search index", index)
+}
+
+func Import(cfg *config.Config, path string, report string, opts imports.Options) {
+	in := os.Stdin
+
+	if path != "-" {
+		f, err := os.Open(path)
+
+		if err != nil {
+			log.Fatalf("Unable to open import '%s': %e", path, err)
+		}
+
+		defer f.Close()
+
+		in = f
+	}
+
+	if opts.Format == "" {
+		opts.Format = imports.FormatFor(path)
+	}
+
+	if report != "" {
+		f, err := os.Create(report)
+
+		if err != nil {
+			log.Fatalf("Unable to create report '%s': %e", report, err)
+		}
+
+		defer f.Close()
+
+		opts.Report = f
+	}
+
+	s, err := imports.Run(cfg, in, opts)
+
+	if s != nil {
+		mode := ""
+
+		if opts.DryRun {
+			mode = " (dry run)"
+		}
+
+		log.Printf("Read %d rows%s: %d created, %d updated, %d skipped, %d failed", s.Rows, mode, s.Created, s.Updated, s.Skipped, s.Failed)
+	}
+
+	if err != nil {
+		log.Fatalf("Unable to import users: %e", err)
+	}
+}
+
+func Export(cfg *config.Config, path, format, query string) {
+	out := os.Stdout
+
+	if path != "-" {
+		f, err := os.Create(path)
+
+		if err != nil {
+			log.Fatalf("Unable to create export '%s': %e", path, err)
+		}
+
+		defer f.Close()
+
+		out = f
+	}
+
+	switch format {
+	case "", imports.FormatJSONL:
+		format = users.ExportNDJSON
+	}
+
+	qs, err := url.ParseQuery(query)
+
+	if err != nil {
+		log.Fatalf("Bad export query: %e", err)
+	}
+
+	q, fields, errs := users.ParseExport(cfg, qs)
+
+	if errs != nil {
+		log.Fatalf("Bad export query: %s", errs)
+	}
+
+	n, err := users.WriteExport(cfg, out, format, q, fields, nil)
+
+	if err != nil {
+		log.Fatalf("Unable to export users after %d rows: %e", n, err)
+	}
+
+	log.Printf("Exported %d users", n)
+}
+
+func GrantAdmin(cfg *config.Config, email string) {
+	_, err := users.SetRole(cfg, email, users.RoleAdmin)
+
+	if err != nil {
+		log.Fatalf("Unable to grant admin role to '%s': %e", email, err)
+	}
+
+	log.Printf("Granted admin role to '%s'", email)
+}
+
+// Sweep erases closed accounts whose cooling-off period has passed, builds
+// data exports whose build was lost and removes expired ones; it is meant to
+// be run periodically.
+func Sweep(cfg *config.Config) {
+	n, err := privacy.EraseDue(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to erase users after %d: %e", n, err)
+	}
+
+	log.Printf("Erased %d users", n)
+
+	n, err = privacy.BuildStalled(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to build stalled data exports after %d: %e", n, err)
+	}
+
+	log.Printf("Built %d stalled data exports", n)
+
+	n, err = privacy.PurgeExports(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to remove expired data exports: %e", err)
+	}
+
+	log.Printf("Removed %d expired data exports", n)
+}
+
+func Restore(cfg *config.Config, email string) {
+	_, err := privacy.Restore(cfg, email)
+
+	if err != nil {
+		log.Fatalf("Unable to restore account '%s': %e", email, err)
+	}
+
+	log.Printf("Restored account '%s'", email)
+}
+
 func Run(cfg *config.Config) {
+	index, err := users.EnsureSearchIndex(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to prepare the user search index: %e", err)
+	}
+
+	log.Printf("Searching users with %s", index)
+
+	if cfg.GetString("grpc.port") != "" {
+		go func() {
+			log.Fatal(rpc.Serve(cfg))
+		}()
+	}
+
 	router := routes.NewRouter(cfg)
 
 	http.Handle("/", router)
@@ -98,6 +326,18 @@ func main() {
 	bootstrap := flag.Bool("bootstrap", false, "setup the application")
 	reset := flag.Bool("reset", false, "force-recreate the database (if it exists)")
 	migrate := flag.Bool("migrate", false, "migrate the database")
+	reindex := flag.Bool("reindex", false, "rebuild the user search index")
+	grantAdmin := flag.String("grant-admin", "", "grant the admin role to the user with the given email")
+	sweep := flag.Bool("sweep", false, "erase closed accounts whose cooling-off period has passed, build stalled data exports and remove expired ones")
+	restore := flag.String("restore", "", "reopen the closed account with the given email, cancelling its erasure")
+	importPath := flag.String("import", "", "import users from the given CSV or JSONL file ('-' for stdin)")
+	exportPath := flag.String("export", "", "export users to the given file ('-' for stdout)")
+	exportQuery := flag.String("query", "", "fields and filters of the export, as GET /users query parameters")
+	format := flag.String("format", "", "format of the import or export: csv, or jsonl/ndjson (default: from the import's file extension, or ndjson)")
+	dryRun := flag.Bool("dry-run", false, "validate the import and roll it back")
+	onDuplicate := flag.String("on-duplicate", imports.DuplicateFail, "what to do with imported emails that are taken: skip, update or fail")
+	notify := flag.String("notify", imports.NotifyNone, "email imported users: none, invitation or reset")
+	report := flag.String("report", "", "write the rows the import rejected to the given file")
 
 	flag.Parse()
 
@@ -108,6 +348,23 @@ func main() {
 		Bootstrap(cfg, *reset)
 	case *migrate:
 		Migrate(cfg)
+	case *reindex:
+		Reindex(cfg)
+	case *grantAdmin != "":
+		GrantAdmin(cfg, *grantAdmin)
+	case *sweep:
+		Sweep(cfg)
+	case *restore != "":
+		Restore(cfg, *restore)
+	case *exportPath != "":
+		Export(cfg, *exportPath, *format, *exportQuery)
+	case *importPath != "":
+		Import(cfg, *importPath, *report, imports.Options{
+			Format:      *format,
+			DryRun:      *dryRun,
+			OnDuplicate: *onDuplicate,
+			Notify:      *notify,
+		})
 	default:
 		Run(cfg)
 	}
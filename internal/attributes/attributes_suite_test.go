@@ -0,0 +1,16 @@
+package attributes
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAttributes(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Attributes Suite")
+}
@@ -0,0 +1,248 @@
+package attributes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gorm.io/gorm"
+)
+
+// DefaultDocument is the schema in force until an admin defines one: it
+// admits no attributes at all.
+const DefaultDocument = `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","additionalProperties":false}`
+
+// schemaURL names the schema document while it is compiled; it is never
+// fetched.
+const schemaURL = "urn:dapper-api:attributes"
+
+var ErrInvalidSchema = errors.New("Invalid attribute schema")
+
+// Schema is a version of the JSON Schema user attributes must conform to.
+// Schemas are never changed in place; the latest one is in force.
+type Schema struct {
+	gorm.Model
+	Document  string `gorm:"type:text;not null"`
+	CreatedBy string
+}
+
+func (Schema) TableName() string {
+	return "attribute_schemas"
+}
+
+// compiled caches the compiled form of the schema in force, keyed by its
+// primary key (zero for the default).
+var (
+	compiledMu sync.Mutex
+	compiled   = map[uint]*jsonschema.Schema{}
+)
+
+// compile parses a schema document, which must describe a JSON object and
+// may not refer to schemas outside itself.
+func compile(doc []byte) (*jsonschema.Schema, error) {
+	var root map[string]interface{}
+
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, fmt.Errorf("%w: the schema must be a JSON object", ErrInvalidSchema)
+	}
+
+	if root["type"] != "object" {
+		return nil, fmt.Errorf("%w: the schema must describe an object", ErrInvalidSchema)
+	}
+
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	c.AssertFormat = true
+	c.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("%w: cannot refer to '%s'", ErrInvalidSchema, s)
+	}
+
+	if err := c.AddResource(schemaURL, bytes.NewReader(doc)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	s, err := c.Compile(schemaURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	return s, nil
+}
+
+// Current returns the schema in force, or one holding DefaultDocument when
+// none has been defined.
+func Current(cfg *config.Config) (*Schema, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var s Schema
+
+	result := db.Order("id DESC").Limit(1).Find(&s)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return &Schema{Document: DefaultDocument}, nil
+	}
+
+	return &s, nil
+}
+
+// Define puts a new schema in force.  Attributes already stored are not
+// checked against it; they are validated the next time they are written.
+func Define(cfg *config.Config, doc []byte, actor string) (*Schema, error) {
+	if _, err := compile(doc); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if err := json.Compact(&buf, doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	s := &Schema{
+		Document:  buf.String(),
+		CreatedBy: actor,
+	}
+
+	result := db.Create(s)
+
+	if result.Error != nil {
+		log.Printf("Unable to create attribute Schema record: %e", result.Error)
+		return nil, result.Error
+	}
+
+	return s, nil
+}
+
+func currentCompiled(cfg *config.Config) (*jsonschema.Schema, error) {
+	s, err := Current(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+
+	if c, ok := compiled[s.ID]; ok {
+		return c, nil
+	}
+
+	c, err := compile([]byte(s.Document))
+
+	if err != nil {
+		return nil, err
+	}
+
+	compiled = map[uint]*jsonschema.Schema{s.ID: c}
+
+	return c, nil
+}
+
+// Validate checks attributes against the schema in force, returning an
+// error for each failing value, named by its path under "attributes".
+func Validate(cfg *config.Config, attrs json.RawMessage) (validation.Errors, error) {
+	s, err := currentCompiled(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return check(s, attrs)
+}
+
+func check(s *jsonschema.Schema, attrs json.RawMessage) (validation.Errors, error) {
+	dec := json.NewDecoder(bytes.NewReader(attrs))
+	dec.UseNumber()
+
+	var v interface{}
+
+	if err := dec.Decode(&v); err != nil {
+		return validation.Errors{{
+			Field:   "attributes",
+			Code:    validation.CodeInvalid,
+			Message: "attributes must be a JSON object",
+		}}, nil
+	}
+
+	err := s.Validate(v)
+
+	var ve *jsonschema.ValidationError
+
+	if !errors.As(err, &ve) {
+		return nil, err
+	}
+
+	var errs validation.Errors
+
+	for _, leaf := range leaves(ve) {
+		errs = append(errs, validation.FieldError{
+			Field:   fieldName(leaf.InstanceLocation),
+			Code:    validation.CodeInvalid,
+			Message: leaf.Message,
+		})
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Field < errs[j].Field
+	})
+
+	return errs, nil
+}
+
+// leaves returns the most specific causes of a validation error.
+func leaves(ve *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(ve.Causes) == 0 {
+		return []*jsonschema.ValidationError{ve}
+	}
+
+	var out []*jsonschema.ValidationError
+
+	for _, c := range ve.Causes {
+		out = append(out, leaves(c)...)
+	}
+
+	return out
+}
+
+// fieldName turns a JSON pointer into the instance into a dotted field name,
+// e.g. "/address/city" into "attributes.address.city".
+func fieldName(ptr string) string {
+	if ptr == "" {
+		return "attributes"
+	}
+
+	var parts []string
+
+	for _, p := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+		parts = append(parts, strings.NewReplacer("~1", "/", "~0", "~").Replace(p))
+	}
+
+	return "attributes." + strings.Join(parts, ".")
+}
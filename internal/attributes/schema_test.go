@@ -0,0 +1,107 @@
+package attributes
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("attributes/schema.go", func() {
+	const doc = `{
+		"type": "object",
+		"properties": {
+			"department": {"type": "string", "maxLength": 20},
+			"employeeNumber": {"type": "integer", "minimum": 1},
+			"locale": {"type": "string", "enum": ["en-GB", "en-US"]}
+		},
+		"additionalProperties": false
+	}`
+
+	Describe("compile()", func() {
+		It("compiles draft 2020-12 schemas", func() {
+			_, err := compile([]byte(doc))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects documents that are not JSON", func() {
+			_, err := compile([]byte(`{"type":`))
+			Expect(errors.Is(err, ErrInvalidSchema)).To(BeTrue())
+		})
+
+		It("rejects schemas that do not describe an object", func() {
+			_, err := compile([]byte(`{"type":"string"}`))
+			Expect(errors.Is(err, ErrInvalidSchema)).To(BeTrue())
+		})
+
+		It("rejects schemas that are themselves invalid", func() {
+			_, err := compile([]byte(`{"type":"object","properties":{"a":{"type":"strung"}}}`))
+			Expect(errors.Is(err, ErrInvalidSchema)).To(BeTrue())
+		})
+
+		It("rejects references to remote schemas", func() {
+			_, err := compile([]byte(`{"type":"object","properties":{"a":{"$ref":"https://example.com/a.json"}}}`))
+			Expect(errors.Is(err, ErrInvalidSchema)).To(BeTrue())
+		})
+	})
+
+	Describe("check()", func() {
+		validate := func(attrs string) validation.Errors {
+			s, err := compile([]byte(doc))
+			Expect(err).NotTo(HaveOccurred())
+
+			errs, err := check(s, json.RawMessage(attrs))
+			Expect(err).NotTo(HaveOccurred())
+
+			return errs
+		}
+
+		It("accepts conforming attributes", func() {
+			Expect(validate(`{"department":"Sales","employeeNumber":42}`)).To(BeNil())
+		})
+
+		It("names each failing value", func() {
+			errs := validate(`{"employeeNumber":0,"locale":"fr-FR"}`)
+
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0].Field).To(Equal("attributes.employeeNumber"))
+			Expect(errs[1].Field).To(Equal("attributes.locale"))
+		})
+
+		It("rejects unknown attributes", func() {
+			errs := validate(`{"shoeSize":9}`)
+
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("attributes"))
+			Expect(errs[0].Code).To(Equal(validation.CodeInvalid))
+		})
+	})
+
+	Describe("Define()", func() {
+		var (
+			cfg *config.Config
+		)
+
+		BeforeEach(func() {
+			cfg = config.Configuration()
+		})
+
+		It("refuses an invalid schema", func() {
+			_, err := Define(cfg, []byte(`{"type":"array"}`), "")
+			Expect(errors.Is(err, ErrInvalidSchema)).To(BeTrue())
+		})
+
+		It("puts the schema in force", func() {
+			s, err := Define(cfg, []byte(DefaultDocument), "")
+			Expect(err).NotTo(HaveOccurred())
+
+			c, err := Current(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.ID).To(BeNumerically(">=", s.ID))
+			Expect(c.Document).To(Equal(DefaultDocument))
+		})
+	})
+})
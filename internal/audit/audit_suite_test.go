@@ -0,0 +1,16 @@
+package audit
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAudit(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Audit Suite")
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"log"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"gorm.io/gorm"
+)
+
+// Entry is an append-only record of something security-relevant happening.
+type Entry struct {
+	gorm.Model
+	Action     string `gorm:"index"`
+	Subject    string `gorm:"index"`
+	Actor      string
+	Outcome    string
+	Reason     string `gorm:"index"`
+	RemoteAddr string
+}
+
+func Record(cfg *config.Config, e *Entry) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	result := db.Create(e)
+
+	if result.Error != nil {
+		log.Printf("Unable to record audit Entry: %e", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// Count returns the number of entries for the action, optionally narrowed to
+// a reason.
+func Count(cfg *config.Config, action, reason string) (int64, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return 0, err
+	}
+
+	var count int64
+
+	query := db.Model(&Entry{}).Where("action = ?", action)
+
+	if reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+
+	result := query.Count(&count)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// Involving returns the entries whose subject or actor is any of the given
+// identifiers, oldest first.
+func Involving(cfg *config.Config, ids ...string) (*[]Entry, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var es []Entry
+
+	result := db.Where("subject IN ? OR actor IN ?", ids, ids).Order("id ASC").Find(&es)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &es, nil
+}
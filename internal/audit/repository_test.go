@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("audit/repository.go", func() {
+	var (
+		cfg    *config.Config
+		action string
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		action = faker.Word() + "." + faker.UUIDDigit()
+	})
+
+	Describe("Record()", func() {
+		It("stores the entry", func() {
+			Expect(Record(cfg, &Entry{Action: action, Reason: "foo"})).NotTo(HaveOccurred())
+
+			count, err := Count(cfg, action, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(BeNumerically("==", 1))
+		})
+	})
+
+	Describe("Count()", func() {
+		BeforeEach(func() {
+			Record(cfg, &Entry{Action: action, Reason: "foo"})
+			Record(cfg, &Entry{Action: action, Reason: "foo"})
+			Record(cfg, &Entry{Action: action, Reason: "bar"})
+		})
+
+		It("counts entries by reason", func() {
+			count, _ := Count(cfg, action, "foo")
+			Expect(count).To(BeNumerically("==", 2))
+		})
+	})
+
+	Describe("Involving()", func() {
+		It("finds entries by subject or actor", func() {
+			who := faker.Email()
+
+			Record(cfg, &Entry{Action: action, Subject: who})
+			Record(cfg, &Entry{Action: action, Actor: who})
+			Record(cfg, &Entry{Action: action, Subject: faker.Email()})
+
+			es, err := Involving(cfg, who)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*es).To(HaveLen(2))
+		})
+	})
+})
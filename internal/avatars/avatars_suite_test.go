@@ -0,0 +1,16 @@
+package avatars
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAvatars(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Avatars Suite")
+}
@@ -0,0 +1,225 @@
+package avatars
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/blobs"
+	"github.com/adamstrickland/dapper-api/internal/caching"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/gorilla/mux"
+)
+
+// formField is the multipart field carrying the image.
+const formField = "avatar"
+
+// blobKey is where a variant is stored; an avatar is named by the key of its
+// largest variant without the "avatars/" prefix, e.g. "<token>/256.png".
+func blobKey(token string, size int, ext string) string {
+	return fmt.Sprintf("avatars/%s/%d.%s", token, size, ext)
+}
+
+// Remove deletes the stored variants of an avatar that is no longer in use.
+func Remove(cfg *config.Config, store blobs.BlobStore, avatar string) {
+	token, file := path.Split(avatar)
+	ext := strings.TrimPrefix(path.Ext(file), ".")
+
+	for _, size := range cfg.GetIntSlice("avatars.sizes") {
+		if err := store.Delete(blobKey(strings.TrimSuffix(token, "/"), size, ext)); err != nil {
+			log.Printf("Unable to remove avatar '%s': %e", avatar, err)
+		}
+	}
+}
+
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrUnsupportedImage):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// readUpload reads the image from the multipart body, failing with 413 when
+// it exceeds the configured size.
+func readUpload(cfg *config.Config, w http.ResponseWriter, r *http.Request) ([]byte, int, error) {
+	max := cfg.GetInt64("avatars.maxBytes")
+
+	// leaves room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, max+64<<10)
+
+	if err := r.ParseMultipartForm(max); err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			return nil, http.StatusRequestEntityTooLarge, ErrImageTooLarge
+		}
+
+		return nil, http.StatusBadRequest, err
+	}
+
+	f, _, err := r.FormFile(formField)
+
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, max+1))
+
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if int64(len(data)) > max {
+		return nil, http.StatusRequestEntityTooLarge, ErrImageTooLarge
+	}
+
+	return data, http.StatusOK, nil
+}
+
+func NewPutHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data bytes.Buffer
+
+		w.Header().Set("Content-Type", "application/json")
+
+		store, err := blobs.NewStore(cfg)
+
+		if err != nil {
+			log.Printf("Unable to open blob store: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		user, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		upload, status, err := readUpload(cfg, w, r)
+
+		if status == http.StatusUnprocessableEntity {
+			validation.WriteErrors(w, validation.Errors{{
+				Field:   formField,
+				Code:    validation.CodeRequired,
+				Message: "avatar is required",
+			}})
+			return
+		}
+
+		if err != nil {
+			log.Printf("Unable to read avatar: %e", err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		ext, variants, err := Process(upload, cfg.GetIntSlice("avatars.sizes"), cfg.GetInt("avatars.maxPixels"))
+
+		if err != nil {
+			http.Error(w, err.Error(), statusFor(err))
+			return
+		}
+
+		token, err := security.RandomToken()
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, v := range variants {
+			if err := store.Put(blobKey(token, v.Size, ext), v.Data); err != nil {
+				log.Printf("Unable to store avatar: %e", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		largest := variants[len(variants)-1].Size
+		avatar := strings.TrimPrefix(blobKey(token, largest, ext), "avatars/")
+
+		old, u, err := users.SetAvatar(cfg, user.ID, avatar)
+
+		if err != nil {
+			Remove(cfg, store, avatar)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if old != "" {
+			Remove(cfg, store, old)
+		}
+
+		up := users.NewUserPayload(u)
+
+		err = json.NewEncoder(&data).Encode(&up)
+
+		if err != nil {
+			log.Printf("Unable to generate payload: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("ETag", users.ETag(u))
+
+		_, err = w.Write(data.Bytes())
+
+		if err != nil {
+			log.Printf("Unable to write body: %e", err)
+		}
+	}
+}
+
+// NewGetHandler serves stored avatar variants.  Their keys are random and
+// never reused, so they can be cached indefinitely.
+func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store, err := blobs.NewStore(cfg)
+
+		if err != nil {
+			log.Printf("Unable to open blob store: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		vars := mux.Vars(r)
+		key := fmt.Sprintf("avatars/%s/%s", vars["token"], vars["file"])
+
+		b, err := store.Get(key)
+
+		if errors.Is(err, blobs.ErrNotFound) || errors.Is(err, blobs.ErrInvalidKey) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			log.Printf("Unable to read avatar '%s': %e", key, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", b.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		_, err = caching.Write(w, r, `"`+vars["token"]+"/"+vars["file"]+`"`, b.ModTime, b.Data)
+
+		if err != nil {
+			log.Printf("Unable to write body: %e", err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package avatars_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/avatars"
+	"github.com/adamstrickland/dapper-api/internal/blobs"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func pngImage(w, h int) []byte {
+	var buf bytes.Buffer
+
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)))
+
+	return buf.Bytes()
+}
+
+var _ = Describe("avatars/handlers.go", func() {
+	var (
+		rr     *httptest.ResponseRecorder
+		cfg    *config.Config
+		user   *users.User
+		token  string
+		field  string
+		upload []byte
+		result map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("blobs.store", "memory")
+		cfg.Set("avatars.sizes", []int{32, 64})
+
+		rr = httptest.NewRecorder()
+		result = nil
+
+		user, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		token, _ = security.NewTokenForSubject(cfg, user.PublicID)
+
+		field = "avatar"
+		upload = pngImage(100, 80)
+	})
+
+	put := func() {
+		var body bytes.Buffer
+
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile(field, "me.png")
+		fw.Write(upload)
+		mw.Close()
+
+		req, err := http.NewRequest("PUT", "/users/me/avatar", &body)
+		Expect(err).NotTo(HaveOccurred())
+
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		http.HandlerFunc(avatars.NewPutHandler(cfg)).ServeHTTP(rr, req)
+
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	Describe("NewPutHandler()", func() {
+		When("a PNG is uploaded", func() {
+			JustBeforeEach(put)
+
+			It("is OK", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the avatar URL", func() {
+				Expect(result["avatarUrl"]).To(MatchRegexp(`^/avatars/[\w-]+/64\.png$`))
+			})
+
+			It("stores every variant", func() {
+				base := strings.TrimSuffix(strings.TrimPrefix(result["avatarUrl"].(string), "/"), "64.png")
+
+				for _, size := range []string{"32", "64"} {
+					_, err := blobs.Memory.Get(base + size + ".png")
+					Expect(err).NotTo(HaveOccurred())
+				}
+			})
+		})
+
+		When("the avatar is replaced", func() {
+			var first string
+
+			JustBeforeEach(func() {
+				put()
+				first = strings.TrimPrefix(result["avatarUrl"].(string), "/")
+
+				rr = httptest.NewRecorder()
+				put()
+			})
+
+			It("removes the old variants", func() {
+				_, err := blobs.Memory.Get(first)
+				Expect(err).To(MatchError(blobs.ErrNotFound))
+			})
+		})
+
+		When("the upload is not an image", func() {
+			BeforeEach(func() {
+				upload = []byte("<svg xmlns='http://www.w3.org/2000/svg'/>")
+			})
+
+			JustBeforeEach(put)
+
+			It("is unsupported", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnsupportedMediaType))
+			})
+		})
+
+		When("the upload is too large", func() {
+			BeforeEach(func() {
+				cfg.Set("avatars.maxBytes", 64)
+			})
+
+			JustBeforeEach(put)
+
+			It("is too large", func() {
+				Expect(rr.Code).To(Equal(http.StatusRequestEntityTooLarge))
+			})
+		})
+
+		When("the image is missing", func() {
+			BeforeEach(func() {
+				field = "picture"
+			})
+
+			JustBeforeEach(put)
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+		})
+	})
+
+	Describe("NewGetHandler()", func() {
+		var vars map[string]string
+
+		JustBeforeEach(func() {
+			put()
+
+			parts := strings.Split(result["avatarUrl"].(string), "/")
+			vars = map[string]string{"token": parts[2], "file": parts[3]}
+
+			rr = httptest.NewRecorder()
+
+			req, _ := http.NewRequest("GET", result["avatarUrl"].(string), nil)
+			req = mux.SetURLVars(req, vars)
+
+			http.HandlerFunc(avatars.NewGetHandler(cfg)).ServeHTTP(rr, req)
+		})
+
+		It("serves the image", func() {
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Header().Get("Content-Type")).To(Equal("image/png"))
+
+			ic, _, err := image.DecodeConfig(bytes.NewReader(rr.Body.Bytes()))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ic.Width).To(Equal(64))
+		})
+
+		It("sends validators", func() {
+			Expect(rr.Header().Get("ETag")).NotTo(BeEmpty())
+			Expect(rr.Header().Get("Last-Modified")).NotTo(BeEmpty())
+		})
+	})
+})
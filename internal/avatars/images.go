@@ -0,0 +1,114 @@
+package avatars
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("Avatars must be PNG or JPEG images")
+	ErrImageTooLarge    = errors.New("Avatar image is too large")
+)
+
+// Variant is an avatar rendered at one size.
+type Variant struct {
+	Size int
+	Data []byte
+}
+
+// formatOf identifies the image by its content rather than by what the
+// client claims it is.
+func formatOf(data []byte) (string, error) {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return "png", nil
+	case "image/jpeg":
+		return "jpg", nil
+	default:
+		return "", ErrUnsupportedImage
+	}
+}
+
+// square returns the largest centred square within the bounds.
+func square(b image.Rectangle) image.Rectangle {
+	side := b.Dx()
+
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// Process checks that the upload is a PNG or JPEG of at most maxPixels, and
+// renders it cropped to a square at each size, never enlarging it.  The
+// variants are encoded afresh, which leaves out any metadata such as EXIF.
+func Process(data []byte, sizes []int, maxPixels int) (string, []Variant, error) {
+	ext, err := formatOf(data)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	ic, _, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil {
+		return "", nil, ErrUnsupportedImage
+	}
+
+	// checked before decoding, so that small files cannot expand into huge
+	// images in memory
+	if ic.Width <= 0 || ic.Height <= 0 || ic.Width*ic.Height > maxPixels {
+		return "", nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil {
+		return "", nil, ErrUnsupportedImage
+	}
+
+	crop := square(src.Bounds())
+
+	sizes = append([]int{}, sizes...)
+	sort.Ints(sizes)
+
+	variants := make([]Variant, 0, len(sizes))
+
+	for _, size := range sizes {
+		side := size
+
+		if crop.Dx() < side {
+			side = crop.Dx()
+		}
+
+		dst := image.NewRGBA(image.Rect(0, 0, side, side))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+		var buf bytes.Buffer
+
+		if ext == "png" {
+			err = png.Encode(&buf, dst)
+		} else {
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		}
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		variants = append(variants, Variant{Size: size, Data: buf.Bytes()})
+	}
+
+	return ext, variants, nil
+}
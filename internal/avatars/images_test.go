@@ -0,0 +1,96 @@
+package avatars
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testImage encodes a w×h gradient as PNG, or JPEG when asked.
+func testImage(w, h int, asJPEG bool) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if asJPEG {
+		jpeg.Encode(&buf, img, nil)
+	} else {
+		png.Encode(&buf, img)
+	}
+
+	return buf.Bytes()
+}
+
+var _ = Describe("avatars/images.go", func() {
+	Describe("Process()", func() {
+		It("renders a square variant of each size", func() {
+			ext, vs, err := Process(testImage(300, 200, false), []int{128, 64}, 1000000)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ext).To(Equal("png"))
+			Expect(vs).To(HaveLen(2))
+
+			for i, size := range []int{64, 128} {
+				ic, format, err := image.DecodeConfig(bytes.NewReader(vs[i].Data))
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(format).To(Equal("png"))
+				Expect(vs[i].Size).To(Equal(size))
+				Expect(ic.Width).To(Equal(size))
+				Expect(ic.Height).To(Equal(size))
+			}
+		})
+
+		It("keeps JPEGs as JPEGs", func() {
+			ext, vs, err := Process(testImage(100, 100, true), []int{64}, 1000000)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ext).To(Equal("jpg"))
+
+			_, format, _ := image.DecodeConfig(bytes.NewReader(vs[0].Data))
+			Expect(format).To(Equal("jpeg"))
+		})
+
+		It("does not enlarge small images", func() {
+			_, vs, _ := Process(testImage(40, 50, false), []int{64}, 1000000)
+
+			ic, _, _ := image.DecodeConfig(bytes.NewReader(vs[0].Data))
+			Expect(ic.Width).To(Equal(40))
+		})
+
+		It("strips metadata", func() {
+			data := testImage(100, 100, true)
+			// an APP1 (EXIF) segment right after the SOI marker
+			exif := append([]byte{0xff, 0xe1, 0x00, 0x0e}, []byte("Exif\x00\x00secret")...)
+			data = append(append(append([]byte{}, data[:2]...), exif...), data[2:]...)
+
+			_, vs, err := Process(data, []int{64}, 1000000)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bytes.Contains(vs[0].Data, []byte("secret"))).To(BeFalse())
+		})
+
+		It("rejects other types", func() {
+			_, _, err := Process([]byte("GIF89a not really"), []int{64}, 1000000)
+
+			Expect(err).To(MatchError(ErrUnsupportedImage))
+		})
+
+		It("rejects images with too many pixels", func() {
+			_, _, err := Process(testImage(200, 200, false), []int{64}, 100*100)
+
+			Expect(err).To(MatchError(ErrImageTooLarge))
+		})
+	})
+})
@@ -0,0 +1,108 @@
+package blobs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps blobs as files below a root directory.
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{root: root}
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file first, so that readers never see a
+// partially written one.
+func (s *LocalStore) Put(key string, data []byte) error {
+	p, err := s.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), ".blob-*")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+func (s *LocalStore) Get(key string) (*Blob, error) {
+	p, err := s.path(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(p)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Blob{
+		Key:         key,
+		Data:        data,
+		ContentType: contentType(key),
+		ModTime:     fi.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	p, err := s.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
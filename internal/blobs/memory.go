@@ -0,0 +1,70 @@
+package blobs
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps blobs in memory, for tests.
+type MemoryStore struct {
+	mu    sync.Mutex
+	blobs map[string]Blob
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blobs: make(map[string]Blob)}
+}
+
+func (s *MemoryStore) Put(key string, data []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blobs[key] = Blob{
+		Key:         key,
+		Data:        append([]byte{}, data...),
+		ContentType: contentType(key),
+		ModTime:     time.Now(),
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Get(key string) (*Blob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.blobs[key]
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &b, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.blobs, key)
+
+	return nil
+}
+
+// Keys lists the keys of the stored blobs.
+func (s *MemoryStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.blobs))
+
+	for k := range s.blobs {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
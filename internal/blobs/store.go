@@ -0,0 +1,66 @@
+package blobs
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+var (
+	ErrNotFound   = errors.New("No blob found")
+	ErrInvalidKey = errors.New("Invalid blob key")
+)
+
+// Blob is a stored file; its content type follows from its key's extension.
+type Blob struct {
+	Key         string
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+}
+
+// BlobStore keeps files under slash-separated keys such as
+// "avatars/abc/128.png".
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) (*Blob, error)
+	Delete(key string) error
+}
+
+// Memory is the store used when blobs.store is "memory", shared so that
+// tests can see what handlers stored.
+var Memory = NewMemoryStore()
+
+func NewStore(cfg *config.Config) (BlobStore, error) {
+	switch s := cfg.GetString("blobs.store"); s {
+	case "local":
+		return NewLocalStore(cfg.GetString("blobs.root")), nil
+	case "memory":
+		return Memory, nil
+	default:
+		return nil, fmt.Errorf("Unknown blob store '%s'", s)
+	}
+}
+
+// checkKey rejects keys that are not clean relative paths, so that they
+// cannot escape a local store's root.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
+
+func contentType(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+
+	return "application/octet-stream"
+}
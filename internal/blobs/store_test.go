@@ -0,0 +1,76 @@
+package blobs
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("blobs/store.go", func() {
+	var (
+		store BlobStore
+		root  string
+	)
+
+	behavesLikeABlobStore := func() {
+		It("returns what was put", func() {
+			Expect(store.Put("avatars/a/1.png", []byte("data"))).To(Succeed())
+
+			b, err := store.Get("avatars/a/1.png")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b.Data).To(Equal([]byte("data")))
+			Expect(b.ContentType).To(Equal("image/png"))
+			Expect(b.ModTime).NotTo(BeZero())
+		})
+
+		It("replaces existing blobs", func() {
+			store.Put("a.txt", []byte("one"))
+			store.Put("a.txt", []byte("two"))
+
+			b, _ := store.Get("a.txt")
+			Expect(b.Data).To(Equal([]byte("two")))
+		})
+
+		It("forgets deleted blobs", func() {
+			store.Put("a.txt", []byte("one"))
+
+			Expect(store.Delete("a.txt")).To(Succeed())
+
+			_, err := store.Get("a.txt")
+			Expect(err).To(MatchError(ErrNotFound))
+		})
+
+		It("ignores deleting missing blobs", func() {
+			Expect(store.Delete("missing.txt")).To(Succeed())
+		})
+
+		It("rejects keys outside the store", func() {
+			Expect(store.Put("../escape.txt", []byte("x"))).To(MatchError(ErrInvalidKey))
+			Expect(store.Put("/etc/escape.txt", []byte("x"))).To(MatchError(ErrInvalidKey))
+		})
+	}
+
+	Describe("LocalStore", func() {
+		BeforeEach(func() {
+			root, _ = ioutil.TempDir("", "blobs")
+			store = NewLocalStore(root)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(root)
+		})
+
+		behavesLikeABlobStore()
+	})
+
+	Describe("MemoryStore", func() {
+		BeforeEach(func() {
+			store = NewMemoryStore()
+		})
+
+		behavesLikeABlobStore()
+	})
+})
@@ -0,0 +1,83 @@
+package caching
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WeakETag derives a weak entity tag from a response body, for resources such
+// as collections that have no version of their own.
+func WeakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// opaque strips the weakness indicator, for the weak comparison used by
+// If-None-Match.
+func opaque(tag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+}
+
+// SetValidators sets the ETag and Last-Modified headers of a response; either
+// may be left empty or zero.
+func SetValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// NotModified reports whether a GET or HEAD request's If-None-Match or, in its
+// absence, If-Modified-Since header shows the client's copy is still current.
+func NotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if strings.TrimSpace(inm) == "*" {
+			return etag != ""
+		}
+
+		for _, tag := range strings.Split(inm, ",") {
+			if etag != "" && opaque(tag) == opaque(etag) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+
+		if err != nil {
+			return false
+		}
+
+		return !lastModified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
+
+// Write sets the validators and writes the body, or only 304 Not Modified
+// when the request shows the client already has it.
+func Write(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time, body []byte) (int, error) {
+	SetValidators(w, etag, lastModified)
+
+	if NotModified(r, etag, lastModified) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return 0, nil
+	}
+
+	return w.Write(body)
+}
@@ -0,0 +1,103 @@
+package caching
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("caching/conditional.go", func() {
+	var (
+		r            *http.Request
+		etag         string
+		lastModified time.Time
+	)
+
+	BeforeEach(func() {
+		r = httptest.NewRequest("GET", "/users", nil)
+		etag = `"3"`
+		lastModified = time.Date(2022, 1, 2, 3, 4, 5, 600, time.UTC)
+	})
+
+	Describe("WeakETag()", func() {
+		It("is weak", func() {
+			Expect(WeakETag([]byte("{}"))).To(HavePrefix(`W/"`))
+		})
+
+		It("depends on the body", func() {
+			Expect(WeakETag([]byte("{}"))).NotTo(Equal(WeakETag([]byte("[]"))))
+		})
+	})
+
+	Describe("NotModified()", func() {
+		It("is false without conditional headers", func() {
+			Expect(NotModified(r, etag, lastModified)).To(BeFalse())
+		})
+
+		It("matches If-None-Match", func() {
+			r.Header.Set("If-None-Match", `"1", "3"`)
+			Expect(NotModified(r, etag, lastModified)).To(BeTrue())
+		})
+
+		It("compares If-None-Match weakly", func() {
+			r.Header.Set("If-None-Match", `W/"3"`)
+			Expect(NotModified(r, etag, lastModified)).To(BeTrue())
+		})
+
+		It("is false when If-None-Match does not match", func() {
+			r.Header.Set("If-None-Match", `"2"`)
+			Expect(NotModified(r, etag, lastModified)).To(BeFalse())
+		})
+
+		It("matches If-Modified-Since at second precision", func() {
+			r.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+			Expect(NotModified(r, etag, lastModified)).To(BeTrue())
+		})
+
+		It("is false when modified since", func() {
+			r.Header.Set("If-Modified-Since", lastModified.Add(-time.Minute).Format(http.TimeFormat))
+			Expect(NotModified(r, etag, lastModified)).To(BeFalse())
+		})
+
+		It("prefers If-None-Match over If-Modified-Since", func() {
+			r.Header.Set("If-None-Match", `"2"`)
+			r.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+			Expect(NotModified(r, etag, lastModified)).To(BeFalse())
+		})
+
+		It("ignores unsafe methods", func() {
+			r = httptest.NewRequest("PUT", "/users", nil)
+			r.Header.Set("If-None-Match", etag)
+			Expect(NotModified(r, etag, lastModified)).To(BeFalse())
+		})
+	})
+
+	Describe("Write()", func() {
+		var rr *httptest.ResponseRecorder
+
+		BeforeEach(func() {
+			rr = httptest.NewRecorder()
+		})
+
+		It("writes the body and validators", func() {
+			Write(rr, r, etag, lastModified, []byte("{}"))
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(Equal("{}"))
+			Expect(rr.Header().Get("ETag")).To(Equal(etag))
+			Expect(rr.Header().Get("Last-Modified")).To(Equal(lastModified.Format(http.TimeFormat)))
+		})
+
+		It("writes 304 when the client is current", func() {
+			r.Header.Set("If-None-Match", etag)
+			Write(rr, r, etag, lastModified, []byte("{}"))
+
+			Expect(rr.Code).To(Equal(http.StatusNotModified))
+			Expect(rr.Body.Len()).To(BeZero())
+			Expect(rr.Header().Get("ETag")).To(Equal(etag))
+		})
+	})
+})
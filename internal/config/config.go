@@ -25,6 +25,13 @@ func Configuration() *Config {
 	v.BindEnv("port", "PORT")
 	log.Printf("Listening on port %s", v.GetString("port"))
 
+	v.SetDefault("grpc.port", "50051")
+	v.BindEnv("grpc.port", "GRPC_PORT")
+	log.Printf("Listening for gRPC on port %s", v.GetString("grpc.port"))
+
+	v.SetDefault("grpc.reflection", true)
+	v.BindEnv("grpc.reflection", "GRPC_REFLECTION")
+
 	v.SetDefault("env", "development")
 	v.BindEnv("env", "APP_ENV")
 	log.Printf("Starting %s environment", v.GetString("env"))
@@ -34,6 +41,9 @@ func Configuration() *Config {
 	v.SetDefault("secret", "samplesecret")
 	v.BindEnv("secret", "APP_SECRET")
 
+	v.SetDefault("tokens.groupClaims", false)
+	v.BindEnv("tokens.groupClaims", "TOKENS_GROUP_CLAIMS")
+
 	dds := "sqlite"
 	ddrp := fmt.Sprintf("./.data/dapper-api_%s.sqlite3", v.GetString("env"))
 	ddfp, _ := filepath.Abs(filepath.Join(Root, ddrp))
@@ -43,6 +53,148 @@ func Configuration() *Config {
 	v.BindEnv("databaseUrl", "DATABASE_URL")
 	log.Printf("Using database URL '%s'", v.GetString("databaseUrl"))
 
+	v.SetDefault("rateLimit.enabled", true)
+	v.SetDefault("rateLimit.store", "memory")
+	v.BindEnv("rateLimit.store", "RATE_LIMIT_STORE")
+	v.SetDefault("rateLimit.apiKeyHeader", "X-API-Key")
+	v.SetDefault("rateLimit.default.requests", 60)
+	v.SetDefault("rateLimit.default.period", "1m")
+	v.SetDefault("rateLimit.default.key", "ip")
+	v.SetDefault("rateLimit.default.failOpen", false)
+	v.SetDefault("rateLimit.routes.signup.requests", 5)
+	v.SetDefault("rateLimit.routes.login.requests", 10)
+	v.SetDefault("rateLimit.routes.getUsers.key", "subject")
+	v.SetDefault("rateLimit.routes.putUsers.key", "subject")
+	v.SetDefault("rateLimit.routes.getCurrentUser.key", "subject")
+	v.SetDefault("rateLimit.routes.getUser.key", "subject")
+	v.SetDefault("rateLimit.routes.patchCurrentUser.key", "subject")
+	v.SetDefault("rateLimit.routes.searchUsers.key", "subject")
+	v.SetDefault("rateLimit.routes.putAvatar.key", "subject")
+	v.SetDefault("rateLimit.routes.getAttributeSchema.key", "subject")
+	v.SetDefault("rateLimit.routes.putAttributeSchema.key", "subject")
+	v.SetDefault("rateLimit.routes.exportUsers.key", "subject")
+	v.SetDefault("rateLimit.routes.getCurrentUserHistory.key", "subject")
+	v.SetDefault("rateLimit.routes.getUserHistory.key", "subject")
+	v.SetDefault("rateLimit.routes.getOrganizations.key", "subject")
+	v.SetDefault("rateLimit.routes.createOrganization.key", "subject")
+	v.SetDefault("rateLimit.routes.acceptOrganizationInvitation.key", "subject")
+	v.SetDefault("rateLimit.routes.inviteToOrganization.key", "subject")
+	v.SetDefault("rateLimit.routes.switchOrganization.key", "subject")
+	v.SetDefault("rateLimit.routes.getGroups.key", "subject")
+	v.SetDefault("rateLimit.routes.createGroup.key", "subject")
+	v.SetDefault("rateLimit.routes.getGroup.key", "subject")
+	v.SetDefault("rateLimit.routes.putGroup.key", "subject")
+	v.SetDefault("rateLimit.routes.deleteGroup.key", "subject")
+	v.SetDefault("rateLimit.routes.addGroupMember.key", "subject")
+	v.SetDefault("rateLimit.routes.removeGroupMember.key", "subject")
+	v.SetDefault("rateLimit.routes.getCurrentUserGroups.key", "subject")
+	v.SetDefault("rateLimit.routes.getPreferences.key", "subject")
+	v.SetDefault("rateLimit.routes.putPreferences.key", "subject")
+	v.SetDefault("rateLimit.routes.deleteCurrentUser.key", "subject")
+	v.SetDefault("rateLimit.routes.requestDataExport.key", "subject")
+	v.SetDefault("rateLimit.routes.requestDataExport.requests", 3)
+	v.SetDefault("rateLimit.routes.getDataExport.key", "subject")
+	v.SetDefault("rateLimit.routes.downloadDataExport.key", "subject")
+	v.SetDefault("rateLimit.routes.confirmEmailChange.requests", 10)
+	v.SetDefault("rateLimit.routes.graphql.key", "subject")
+	v.SetDefault("rateLimit.routes.cancelEmailChange.requests", 10)
+
+	v.SetDefault("cacheControl.default", "private, no-cache")
+	v.SetDefault("cacheControl.routes.signup", "no-store")
+	v.SetDefault("cacheControl.routes.login", "no-store")
+	v.SetDefault("cacheControl.routes.confirmEmailChange", "no-store")
+	v.SetDefault("cacheControl.routes.getAvatar", "public, max-age=31536000, immutable")
+	v.SetDefault("cacheControl.routes.exportUsers", "no-store")
+	v.SetDefault("cacheControl.routes.switchOrganization", "no-store")
+	v.SetDefault("cacheControl.routes.downloadDataExport", "no-store")
+	v.SetDefault("cacheControl.routes.graphql", "no-store")
+
+	v.SetDefault("contentTypes.default", []string{"application/json"})
+	v.SetDefault("contentTypes.routes.patchCurrentUser", []string{"application/merge-patch+json", "application/json-patch+json"})
+	v.SetDefault("contentTypes.routes.putAvatar", []string{"multipart/form-data"})
+	v.SetDefault("contentTypes.routes.getAvatar", []string{})
+	v.SetDefault("contentTypes.routes.downloadDataExport", []string{})
+	v.SetDefault("contentTypes.routes.putAttributeSchema", []string{"application/schema+json", "application/json"})
+	v.SetDefault("contentTypes.routes.scimGetServiceProviderConfig", []string{})
+	v.SetDefault("contentTypes.routes.scimGetResourceTypes", []string{})
+	v.SetDefault("contentTypes.routes.scimGetSchemas", []string{})
+	v.SetDefault("contentTypes.routes.scimGetSchema", []string{})
+	v.SetDefault("contentTypes.routes.scimGetUsers", []string{})
+	v.SetDefault("contentTypes.routes.scimCreateUser", []string{"application/scim+json", "application/json"})
+	v.SetDefault("contentTypes.routes.scimGetUser", []string{})
+	v.SetDefault("contentTypes.routes.scimPutUser", []string{"application/scim+json", "application/json"})
+	v.SetDefault("contentTypes.routes.scimPatchUser", []string{"application/scim+json", "application/json"})
+	v.SetDefault("contentTypes.routes.scimDeleteUser", []string{})
+	v.SetDefault("contentTypes.routes.scimGetGroups", []string{})
+	v.SetDefault("contentTypes.routes.scimCreateGroup", []string{"application/scim+json", "application/json"})
+	v.SetDefault("contentTypes.routes.scimGetGroup", []string{})
+	v.SetDefault("contentTypes.routes.scimPutGroup", []string{"application/scim+json", "application/json"})
+	v.SetDefault("contentTypes.routes.scimPatchGroup", []string{"application/scim+json", "application/json"})
+	v.SetDefault("contentTypes.routes.scimDeleteGroup", []string{})
+
+	v.SetDefault("users.visibility", "all")
+	v.BindEnv("users.visibility", "USERS_VISIBILITY")
+
+	v.SetDefault("users.requireIfMatch", false)
+	v.BindEnv("users.requireIfMatch", "USERS_REQUIRE_IF_MATCH")
+
+	v.SetDefault("pagination.defaultLimit", 50)
+	v.SetDefault("pagination.maxLimit", 200)
+
+	v.SetDefault("graphql.maxDepth", 10)
+	v.SetDefault("graphql.maxComplexity", 5000)
+	// deep enough for the introspection query of GraphiQL and similar tools
+	v.SetDefault("graphql.maxIntrospectionDepth", 15)
+
+	v.SetDefault("preferences.themes", []string{"system", "light", "dark"})
+	v.SetDefault("preferences.defaults.theme", "system")
+	v.SetDefault("preferences.defaults.locale", "en")
+	v.SetDefault("preferences.defaults.timezone", "UTC")
+	v.SetDefault("preferences.defaults.email.invitations", true)
+	v.SetDefault("preferences.defaults.email.announcements", true)
+
+	v.SetDefault("mailer", "log")
+	v.BindEnv("mailer", "MAILER")
+
+	v.SetDefault("blobs.store", "local")
+	v.BindEnv("blobs.store", "BLOB_STORE")
+	v.SetDefault("blobs.root", ".data/blobs")
+	v.BindEnv("blobs.root", "BLOB_ROOT")
+
+	v.SetDefault("avatars.maxBytes", 5<<20)
+	v.SetDefault("avatars.maxPixels", 4096*4096)
+	v.SetDefault("avatars.sizes", []int{64, 128, 256})
+
+	v.SetDefault("emailChange.confirmationTtl", "24h")
+	v.SetDefault("emailChange.gracePeriod", "72h")
+
+	v.SetDefault("signupMode", "open")
+	v.BindEnv("signupMode", "SIGNUP_MODE")
+	log.Printf("Accepting signups in '%s' mode", v.GetString("signupMode"))
+
+	v.SetDefault("signups.checkSyntax", true)
+	v.SetDefault("signups.allowedDomains", []string{})
+	v.SetDefault("signups.deniedDomains", []string{})
+	v.SetDefault("signups.blockDisposable", true)
+	v.SetDefault("signups.disposableDomainsFile", "")
+	v.BindEnv("signups.disposableDomainsFile", "DISPOSABLE_DOMAINS_FILE")
+
+	v.SetDefault("invitations.ttl", "168h")
+	v.SetDefault("invitations.maxUses", 1)
+	v.SetDefault("invitations.userQuota", 0)
+
+	v.SetDefault("imports.batchSize", 500)
+
+	v.SetDefault("privacy.exportTtl", "168h")
+	v.SetDefault("privacy.exportStallAfter", "15m")
+	v.SetDefault("privacy.erasureCoolingOff", "720h")
+	v.BindEnv("privacy.erasureCoolingOff", "PRIVACY_ERASURE_COOLING_OFF")
+
+	v.SetDefault("scim.token", "")
+	v.BindEnv("scim.token", "SCIM_TOKEN")
+	v.SetDefault("scim.organization", "")
+	v.BindEnv("scim.organization", "SCIM_ORGANIZATION")
+
 	return &Config{
 		Viper: *v,
 	}
@@ -0,0 +1,201 @@
+package emailchanges
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/notifications"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+)
+
+type requestPayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type tokenPayload struct {
+	Token string `json:"token"`
+}
+
+type EmailChangePayload struct {
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrExpired):
+		return http.StatusGone
+	case errors.Is(err, ErrEmailTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func notify(cfg *config.Config, ec *EmailChange) {
+	notifications.Send(cfg, notifications.Message{
+		To:      ec.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Confirm this address by sending the token below to /users/me/email/confirm before %s.\n\n%s",
+			ec.ExpiresAt.Format(time.RFC1123),
+			ec.ConfirmationToken,
+		),
+	})
+
+	notifications.Send(cfg, notifications.Message{
+		To:      ec.OldEmail,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf(
+			"A change of your email address to '%s' was requested.  If this was not you, cancel it by sending the token below to /users/me/email/cancel.\n\n%s",
+			ec.NewEmail,
+			ec.CancellationToken,
+		),
+	})
+}
+
+func NewPostHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			data bytes.Buffer
+			qp   requestPayload
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewDecoder(r.Body).Decode(&qp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		if user.UnencryptedPassword != qp.Password {
+			log.Println("Unable to authenticate password!")
+			http.Error(w, "UNAUTHORIZED", http.StatusUnauthorized)
+			return
+		}
+
+		if qp.Email == "" || qp.Email == user.Email {
+			http.Error(w, "A new email address is required", http.StatusBadRequest)
+			return
+		}
+
+		ec, err := Request(cfg, user, qp.Email)
+
+		if err != nil {
+			http.Error(w, err.Error(), statusFor(err))
+			return
+		}
+
+		notify(cfg, ec)
+
+		err = json.NewEncoder(&data).Encode(&EmailChangePayload{
+			Email:     ec.NewEmail,
+			ExpiresAt: ec.ExpiresAt,
+		})
+
+		if err != nil {
+			log.Printf("Unable to generate payload: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+
+		_, err = w.Write(data.Bytes())
+
+		if err != nil {
+			log.Printf("Unable to write body: %e", err)
+		}
+	}
+}
+
+func NewConfirmHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var tp tokenPayload
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewDecoder(r.Body).Decode(&tp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ec, err := Confirm(cfg, tp.Token)
+
+		if err != nil {
+			http.Error(w, err.Error(), statusFor(err))
+			return
+		}
+
+		user, err := users.FindByEmail(cfg, ec.NewEmail)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data, err := security.NewTokenPayload(cfg, user.PublicID)
+
+		if err != nil {
+			log.Printf("Unable to create session: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = w.Write(data)
+
+		if err != nil {
+			log.Printf("Unable to write body: %e", err)
+		}
+	}
+}
+
+func NewCancelHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var tp tokenPayload
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewDecoder(r.Body).Decode(&tp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		_, err = Cancel(cfg, tp.Token)
+
+		if err != nil {
+			http.Error(w, err.Error(), statusFor(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,180 @@
+package emailchanges_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/emailchanges"
+	"github.com/adamstrickland/dapper-api/internal/notifications"
+	"github.com/adamstrickland/dapper-api/internal/routes"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("emailchanges/handlers.go", func() {
+	var (
+		rr                     *httptest.ResponseRecorder
+		cfg                    *config.Config
+		oldEmail, email, token string
+		body                   string
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("mailer", "memory")
+
+		rr = httptest.NewRecorder()
+		oldEmail = faker.Email()
+		email = faker.Email()
+
+		u, err := users.Create(cfg, &users.User{
+			Email:               oldEmail,
+			UnencryptedPassword: "p@ssw0rd",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		token, _ = security.NewTokenForSubject(cfg, u.PublicID)
+	})
+
+	Describe("NewPostHandler()", func() {
+		JustBeforeEach(func() {
+			req, err := http.NewRequest("POST", "/users/me/email", bytes.NewBufferString(body))
+			Expect(err).NotTo(HaveOccurred())
+
+			req.Header.Set(cfg.GetString("tokenHeader"), token)
+
+			http.HandlerFunc(emailchanges.NewPostHandler(cfg)).ServeHTTP(rr, req)
+		})
+
+		When("the password is wrong", func() {
+			BeforeEach(func() {
+				body = fmt.Sprintf(`{"email": "%s", "password": "wrong"}`, email)
+			})
+
+			It("is unauthorized", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		When("the password is right", func() {
+			BeforeEach(func() {
+				body = fmt.Sprintf(`{"email": "%s", "password": "p@ssw0rd"}`, email)
+			})
+
+			It("is accepted", func() {
+				Expect(rr.Code).To(Equal(http.StatusAccepted))
+			})
+
+			It("sends a confirmation to the new address", func() {
+				Expect(notifications.Outbox.To(email)).To(HaveLen(1))
+			})
+
+			It("sends a notice to the old address", func() {
+				Expect(notifications.Outbox.To(oldEmail)).To(HaveLen(1))
+			})
+		})
+
+		When("the new address is in use", func() {
+			BeforeEach(func() {
+				users.Create(cfg, &users.User{Email: email})
+				body = fmt.Sprintf(`{"email": "%s", "password": "p@ssw0rd"}`, email)
+			})
+
+			It("is a conflict", func() {
+				Expect(rr.Code).To(Equal(http.StatusConflict))
+			})
+		})
+	})
+
+	Describe("NewConfirmHandler()", func() {
+		var legacy string
+
+		BeforeEach(func() {
+			u, _ := users.FindByEmail(cfg, oldEmail)
+			ec, err := emailchanges.Request(cfg, u, email)
+			Expect(err).NotTo(HaveOccurred())
+
+			body = fmt.Sprintf(`{"token": "%s"}`, ec.ConfirmationToken)
+			legacy, _ = security.NewTokenForSubject(cfg, oldEmail)
+		})
+
+		JustBeforeEach(func() {
+			req, err := http.NewRequest("POST", "/users/me/email/confirm", bytes.NewBufferString(body))
+			Expect(err).NotTo(HaveOccurred())
+
+			http.HandlerFunc(emailchanges.NewConfirmHandler(cfg)).ServeHTTP(rr, req)
+		})
+
+		It("is OK", func() {
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+
+		It("invalidates the user's tokens", func() {
+			ok, _ := security.IsValidToken(cfg, token)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("bumps the user's version", func() {
+			u, _ := users.FindByEmail(cfg, email)
+			Expect(u.Version).To(BeEquivalentTo(2))
+		})
+
+		It("invalidates tokens issued for the old address", func() {
+			ok, _ := security.IsValidToken(cfg, legacy)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("issues a token the API accepts", func() {
+			var tp security.TokenPayload
+			Expect(json.Unmarshal(rr.Body.Bytes(), &tp)).To(Succeed())
+
+			req, _ := http.NewRequest("GET", "/users/me", nil)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(cfg.GetString("tokenHeader"), tp.Token)
+
+			me := httptest.NewRecorder()
+			routes.NewRouter(cfg).ServeHTTP(me, req)
+
+			Expect(me.Code).To(Equal(http.StatusOK))
+			Expect(me.Body.String()).To(ContainSubstring(email))
+		})
+
+		When("the token is unknown", func() {
+			BeforeEach(func() {
+				body = `{"token": "nope"}`
+			})
+
+			It("is not found", func() {
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("NewCancelHandler()", func() {
+		BeforeEach(func() {
+			u, _ := users.FindByEmail(cfg, oldEmail)
+			ec, err := emailchanges.Request(cfg, u, email)
+			Expect(err).NotTo(HaveOccurred())
+
+			body = fmt.Sprintf(`{"token": "%s"}`, ec.CancellationToken)
+		})
+
+		JustBeforeEach(func() {
+			req, err := http.NewRequest("POST", "/users/me/email/cancel", bytes.NewBufferString(body))
+			Expect(err).NotTo(HaveOccurred())
+
+			http.HandlerFunc(emailchanges.NewCancelHandler(cfg)).ServeHTTP(rr, req)
+		})
+
+		It("has no content", func() {
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+		})
+	})
+})
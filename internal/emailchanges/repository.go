@@ -0,0 +1,246 @@
+package emailchanges
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound   = errors.New("No pending email change found")
+	ErrExpired    = errors.New("Email change has expired")
+	ErrEmailTaken = errors.New("Email is already in use")
+)
+
+type EmailChange struct {
+	gorm.Model
+	UserID            uint `gorm:"index"`
+	OldEmail          string
+	NewEmail          string
+	ConfirmationToken string `gorm:"uniqueIndex"`
+	CancellationToken string `gorm:"uniqueIndex"`
+	ExpiresAt         time.Time
+	ConfirmedAt       *time.Time
+	CancelledAt       *time.Time
+}
+
+func isEmailTaken(tx *gorm.DB, email string) (bool, error) {
+	var count int64
+
+	result := tx.Model(&users.User{}).Where("email = ?", email).Count(&count)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// changeEmail moves the user from one address to another, recording the
+// revision and revoking every token issued to the user, whether to their
+// public ID or, before those were the subject, to the address being left.
+func changeEmail(tx *gorm.DB, userID uint, from, to string) error {
+	taken, err := isEmailTaken(tx, to)
+
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrEmailTaken
+	}
+
+	result := tx.Model(&users.User{}).
+		Where("id = ? AND email = ?", userID, from).
+		UpdateColumns(users.Changed(map[string]interface{}{"email": to}))
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	if err := users.RecordRevision(tx, userID, ""); err != nil {
+		return err
+	}
+
+	var u users.User
+
+	if err := tx.Select("id", "public_id").Where("id = ?", userID).Take(&u).Error; err != nil {
+		return err
+	}
+
+	if err := security.RevokeSubjectTx(tx, u.PublicID); err != nil {
+		return err
+	}
+
+	return security.RevokeSubjectTx(tx, from)
+}
+
+// Request records a pending change of the user's address; any change already
+// pending for the user is cancelled.
+func Request(cfg *config.Config, u *users.User, email string) (*EmailChange, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	ct, err := security.RandomToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	xt, err := security.RandomToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ec := &EmailChange{
+		UserID:            u.ID,
+		OldEmail:          u.Email,
+		NewEmail:          email,
+		ConfirmationToken: ct,
+		CancellationToken: xt,
+		ExpiresAt:         time.Now().Add(cfg.GetDuration("emailChange.confirmationTtl")),
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		taken, err := isEmailTaken(tx, email)
+
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return ErrEmailTaken
+		}
+
+		result := tx.Model(&EmailChange{}).
+			Where("user_id = ? AND confirmed_at IS NULL AND cancelled_at IS NULL", u.ID).
+			Update("cancelled_at", time.Now())
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Create(ec).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to request email change for '%s': %e", u.Email, err)
+		return nil, err
+	}
+
+	return ec, nil
+}
+
+// Confirm switches the user to the pending address.
+func Confirm(cfg *config.Config, token string) (*EmailChange, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var ec EmailChange
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("confirmation_token = ? AND confirmed_at IS NULL AND cancelled_at IS NULL", token).
+			Limit(1).
+			Find(&ec)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		now := time.Now()
+
+		if now.After(ec.ExpiresAt) {
+			return ErrExpired
+		}
+
+		if err := changeEmail(tx, ec.UserID, ec.OldEmail, ec.NewEmail); err != nil {
+			return err
+		}
+
+		ec.ConfirmedAt = &now
+
+		return tx.Save(&ec).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to confirm email change: %e", err)
+		return nil, err
+	}
+
+	return &ec, nil
+}
+
+// Cancel abandons a pending change, or reverts a confirmed one that is still
+// within the grace period.
+func Cancel(cfg *config.Config, token string) (*EmailChange, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var ec EmailChange
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("cancellation_token = ? AND cancelled_at IS NULL", token).
+			Limit(1).
+			Find(&ec)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		now := time.Now()
+
+		if ec.ConfirmedAt != nil {
+			if now.After(ec.ConfirmedAt.Add(cfg.GetDuration("emailChange.gracePeriod"))) {
+				return ErrExpired
+			}
+
+			if err := changeEmail(tx, ec.UserID, ec.NewEmail, ec.OldEmail); err != nil {
+				return err
+			}
+		}
+
+		ec.CancelledAt = &now
+
+		return tx.Save(&ec).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to cancel email change: %e", err)
+		return nil, err
+	}
+
+	return &ec, nil
+}
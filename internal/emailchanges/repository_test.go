@@ -0,0 +1,147 @@
+package emailchanges
+
+import (
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("emailchanges/repository.go", func() {
+	var (
+		cfg             *config.Config
+		user            *users.User
+		oldEmail, email string
+		ec              *EmailChange
+		err             error
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		oldEmail = faker.Email()
+		email = faker.Email()
+
+		user, err = users.Create(cfg, &users.User{Email: oldEmail})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Request()", func() {
+		When("the new email is not in use", func() {
+			It("records a pending change", func() {
+				ec, err = Request(cfg, user, email)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ec.NewEmail).To(Equal(email))
+				Expect(ec.ConfirmationToken).NotTo(BeEmpty())
+				Expect(ec.CancellationToken).NotTo(BeEmpty())
+			})
+
+			It("does not change the address yet", func() {
+				Request(cfg, user, email)
+
+				u, _ := users.FindByEmail(cfg, oldEmail)
+				Expect(u).NotTo(BeNil())
+			})
+
+			It("cancels earlier pending changes", func() {
+				first, _ := Request(cfg, user, faker.Email())
+				Request(cfg, user, email)
+
+				_, err = Confirm(cfg, first.ConfirmationToken)
+				Expect(err).To(MatchError(ErrNotFound))
+			})
+		})
+
+		When("the new email is in use", func() {
+			BeforeEach(func() {
+				users.Create(cfg, &users.User{Email: email})
+			})
+
+			It("returns an error", func() {
+				_, err = Request(cfg, user, email)
+				Expect(err).To(MatchError(ErrEmailTaken))
+			})
+		})
+	})
+
+	Describe("Confirm()", func() {
+		BeforeEach(func() {
+			ec, err = Request(cfg, user, email)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("switches the address", func() {
+			_, err = Confirm(cfg, ec.ConfirmationToken)
+			Expect(err).NotTo(HaveOccurred())
+
+			u, _ := users.FindByEmail(cfg, email)
+			Expect(u).NotTo(BeNil())
+			Expect(u.ID).To(Equal(user.ID))
+		})
+
+		It("cannot be used twice", func() {
+			Confirm(cfg, ec.ConfirmationToken)
+
+			_, err = Confirm(cfg, ec.ConfirmationToken)
+			Expect(err).To(MatchError(ErrNotFound))
+		})
+
+		When("the change has expired", func() {
+			BeforeEach(func() {
+				db, _ := internal.NewConnection(cfg)
+				db.Model(ec).Update("expires_at", time.Now().Add(-time.Minute))
+			})
+
+			It("returns an error", func() {
+				_, err = Confirm(cfg, ec.ConfirmationToken)
+				Expect(err).To(MatchError(ErrExpired))
+			})
+		})
+	})
+
+	Describe("Cancel()", func() {
+		BeforeEach(func() {
+			ec, err = Request(cfg, user, email)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		When("the change is pending", func() {
+			It("prevents it from being confirmed", func() {
+				_, err = Cancel(cfg, ec.CancellationToken)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = Confirm(cfg, ec.ConfirmationToken)
+				Expect(err).To(MatchError(ErrNotFound))
+			})
+		})
+
+		When("the change has been confirmed", func() {
+			BeforeEach(func() {
+				_, err = Confirm(cfg, ec.ConfirmationToken)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("restores the old address", func() {
+				_, err = Cancel(cfg, ec.CancellationToken)
+				Expect(err).NotTo(HaveOccurred())
+
+				u, _ := users.FindByEmail(cfg, oldEmail)
+				Expect(u).NotTo(BeNil())
+			})
+
+			When("the grace period has passed", func() {
+				BeforeEach(func() {
+					cfg.Set("emailChange.gracePeriod", "-1m")
+				})
+
+				It("returns an error", func() {
+					_, err = Cancel(cfg, ec.CancellationToken)
+					Expect(err).To(MatchError(ErrExpired))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+)
+
+// Path is where the GraphQL endpoint is served.
+const Path = "/graphql"
+
+// The codes errors carry in their extensions.
+const (
+	CodeUnauthenticated      = "unauthenticated"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeInvalid              = "invalid"
+	CodeConflict             = "conflict"
+	CodePreconditionRequired = "precondition_required"
+	CodeTooDeep              = "too_deep"
+	CodeTooComplex           = "too_complex"
+	CodeRateLimited          = "rate_limited"
+)
+
+// Error is a failure reported to the client with a code in its extensions,
+// along with the reason a signup was rejected, the fields that failed
+// validation or when a rate-limited call may be retried, if any.
+type Error struct {
+	Code       string
+	Message    string
+	Reason     string
+	Fields     validation.Errors
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions are added to the error as the client sees it.
+func (e *Error) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Code}
+
+	if e.Reason != "" {
+		ext["reason"] = e.Reason
+	}
+
+	if e.Fields != nil {
+		ext["fields"] = e.Fields
+	}
+
+	if e.RetryAfter > 0 {
+		ext["retryAfter"] = int(e.RetryAfter.Seconds())
+	}
+
+	return ext
+}
+
+func newError(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// invalid reports the fields that failed validation.
+func invalid(errs validation.Errors) *Error {
+	return &Error{Code: CodeInvalid, Message: errs.Error(), Fields: errs}
+}
+
+type stateKey struct{}
+
+// state is what the resolvers of one request share: the request, whose token
+// and tenant were resolved by the same middleware as the REST endpoints, the
+// user it was made by, once looked up, and the loaders batching its lookups.
+type state struct {
+	r       *http.Request
+	viewer  *users.User
+	loaders *loaders
+}
+
+func withState(ctx context.Context, s *state) context.Context {
+	return context.WithValue(ctx, stateKey{}, s)
+}
+
+func stateOf(ctx context.Context) *state {
+	s, _ := ctx.Value(stateKey{}).(*state)
+
+	return s
+}
+
+// viewer returns the user the request's token was issued to, failing when it
+// carries none.
+func viewer(ctx context.Context, cfg *config.Config) (*users.User, error) {
+	s := stateOf(ctx)
+
+	if s.viewer != nil {
+		return s.viewer, nil
+	}
+
+	if s.r.Header.Get(cfg.GetString("tokenHeader")) == "" {
+		return nil, newError(CodeUnauthenticated, "A token is required")
+	}
+
+	u, err := users.CurrentUser(cfg, s.r)
+
+	if errors.Is(err, users.ErrNotFound) {
+		return nil, newError(CodeUnauthenticated, "The token's user no longer exists")
+	}
+
+	if err != nil {
+		log.Printf("Unable to identify user: %e", err)
+		return nil, err
+	}
+
+	s.viewer = u
+
+	return u, nil
+}
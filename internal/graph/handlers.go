@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type requestPayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func writeResult(w http.ResponseWriter, status int, res *graphql.Result) {
+	var data bytes.Buffer
+
+	err := json.NewEncoder(&data).Encode(res)
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+	}
+}
+
+// NewPostHandler executes GraphQL operations.  Operations that do not parse,
+// fail validation or exceed the depth and complexity limits are refused
+// before any of their fields are resolved.
+func NewPostHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	schema, err := NewSchema(cfg)
+
+	if err != nil {
+		log.Fatalf("Unable to build GraphQL schema: %e", err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var qp requestPayload
+
+		err := json.NewDecoder(r.Body).Decode(&qp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		doc, err := parser.Parse(parser.ParseParams{
+			Source: source.NewSource(&source.Source{Body: []byte(qp.Query), Name: "GraphQL request"}),
+		})
+
+		if err != nil {
+			writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+			return
+		}
+
+		if vr := graphql.ValidateDocument(&schema, doc, nil); !vr.IsValid {
+			writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: vr.Errors})
+			return
+		}
+
+		if err := checkLimits(cfg, &schema, doc, qp.OperationName, qp.Variables); err != nil {
+			writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: []gqlerrors.FormattedError{{
+				Message:    err.Error(),
+				Extensions: err.Extensions(),
+			}}})
+			return
+		}
+
+		ctx := withState(r.Context(), &state{
+			r:       r,
+			loaders: newLoaders(cfg, security.RequestTenant(r)),
+		})
+
+		res := graphql.Execute(graphql.ExecuteParams{
+			Schema:        schema,
+			AST:           doc,
+			OperationName: qp.OperationName,
+			Args:          qp.Variables,
+			Context:       ctx,
+		})
+
+		writeResult(w, http.StatusOK, res)
+	}
+}
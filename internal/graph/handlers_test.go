@@ -0,0 +1,275 @@
+package graph_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/graph"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+var _ = Describe("graph/handlers.go", func() {
+	var (
+		rr                   *httptest.ResponseRecorder
+		cfg                  *config.Config
+		handler              http.HandlerFunc
+		owner, member, other *users.User
+		org                  *organizations.Organization
+		g                    *groups.Group
+		result               response
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("signupMode", "open")
+		handler = graph.NewPostHandler(cfg)
+
+		owner, _ = users.Create(cfg, &users.User{Email: faker.Email(), UnencryptedPassword: "p@ssw0rd", FirstName: "Zaphod"})
+		member, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: "Ford"})
+		other, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+
+		org, _ = organizations.Create(cfg, &organizations.Organization{Name: "Heart of Gold"}, owner)
+		organizations.AddMember(cfg, org.ID, member.ID, organizations.RoleMember)
+
+		g, _ = groups.Create(cfg, org.ID, &groups.Group{Name: "Crew"}, owner)
+	})
+
+	// serve posts the query as the user, acting in the organization, or
+	// anonymously when the user is nil.
+	serve := func(u *users.User, query string, variables map[string]interface{}) {
+		body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+
+		req, err := http.NewRequest("POST", graph.Path, bytes.NewBuffer(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		req = security.WithTenant(req, 0, "")
+
+		if u != nil {
+			m, _ := organizations.FindMembership(cfg, org.PublicID, u.ID)
+			role := ""
+
+			if m != nil {
+				role = m.Role
+			}
+
+			req = security.WithTenant(req, org.ID, role)
+
+			token, _ := security.NewTokenForSubjectInOrganization(cfg, u.PublicID, org.PublicID)
+			req.Header.Set(cfg.GetString("tokenHeader"), token)
+		}
+
+		rr = httptest.NewRecorder()
+		result = response{}
+
+		handler.ServeHTTP(rr, req)
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	code := func() interface{} {
+		Expect(result.Errors).NotTo(BeEmpty())
+		return result.Errors[0].Extensions["code"]
+	}
+
+	Describe("query me", func() {
+		It("returns the user with their groups, preferences and sessions", func() {
+			preferences.Save(cfg, owner.ID, `{"theme":"dark"}`)
+			security.RevokeSubject(cfg, owner.Email)
+
+			serve(owner, `{ me { id firstName groups { id name role } preferences { theme email { invitations } } sessions { subject } } }`, nil)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result.Errors).To(BeEmpty())
+
+			me := result.Data["me"].(map[string]interface{})
+			Expect(me["id"]).To(Equal(owner.PublicID))
+			Expect(me["firstName"]).To(Equal("Zaphod"))
+			Expect(me["groups"]).To(ConsistOf(map[string]interface{}{"id": g.PublicID, "name": "Crew", "role": groups.RoleOwner}))
+			Expect(me["preferences"]).To(HaveKeyWithValue("theme", "dark"))
+			Expect(me["sessions"]).To(ConsistOf(HaveKeyWithValue("subject", owner.Email)))
+		})
+
+		It("needs a token", func() {
+			serve(nil, `{ me { id } }`, nil)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(code()).To(Equal(graph.CodeUnauthenticated))
+		})
+	})
+
+	Describe("query user", func() {
+		It("returns users of the tenant", func() {
+			serve(owner, `query($id: ID!) { user(id: $id) { email groups { id } } }`, map[string]interface{}{"id": member.PublicID})
+
+			Expect(result.Data["user"]).To(HaveKeyWithValue("email", member.Email))
+		})
+
+		It("is null for users of other tenants", func() {
+			serve(owner, `query($id: ID!) { user(id: $id) { email } }`, map[string]interface{}{"id": other.PublicID})
+
+			Expect(result.Errors).To(BeEmpty())
+			Expect(result.Data["user"]).To(BeNil())
+		})
+
+		It("keeps other users' sessions and preferences from non-admins", func() {
+			serve(member, `query($id: ID!) { user(id: $id) { sessions { subject } } }`, map[string]interface{}{"id": owner.PublicID})
+
+			Expect(code()).To(Equal(graph.CodeForbidden))
+
+			serve(member, `query($id: ID!) { user(id: $id) { preferences { theme } } }`, map[string]interface{}{"id": owner.PublicID})
+
+			Expect(code()).To(Equal(graph.CodeForbidden))
+		})
+	})
+
+	Describe("query users", func() {
+		It("pages through the users of the tenant with their groups", func() {
+			serve(owner, `{ users(limit: 1, sort: "firstName") { users { firstName groups { name } } next } }`, nil)
+
+			Expect(result.Errors).To(BeEmpty())
+
+			page := result.Data["users"].(map[string]interface{})
+			Expect(page["users"]).To(ConsistOf(map[string]interface{}{"firstName": "Ford", "groups": []interface{}{}}))
+			Expect(page["next"]).NotTo(BeNil())
+
+			serve(owner, `query($c: String) { users(limit: 1, sort: "firstName", cursor: $c) { users { firstName groups { name } } next } }`,
+				map[string]interface{}{"c": page["next"]})
+
+			page = result.Data["users"].(map[string]interface{})
+			Expect(page["users"]).To(ConsistOf(map[string]interface{}{
+				"firstName": "Zaphod",
+				"groups":    []interface{}{map[string]interface{}{"name": "Crew"}},
+			}))
+			Expect(page["next"]).To(BeNil())
+		})
+
+		It("reports invalid arguments by field", func() {
+			serve(owner, `{ users(sort: "shoeSize") { next } }`, nil)
+
+			Expect(code()).To(Equal(graph.CodeInvalid))
+			Expect(result.Errors[0].Extensions["fields"]).To(ConsistOf(HaveKeyWithValue("field", "sort")))
+		})
+	})
+
+	Describe("mutation signUp", func() {
+		It("creates the user and issues them a token", func() {
+			email := faker.Email()
+
+			serve(nil, `mutation($email: String!) { signUp(email: $email, password: "p@ssw0rd") { token user { email } } }`,
+				map[string]interface{}{"email": email})
+
+			Expect(result.Errors).To(BeEmpty())
+
+			payload := result.Data["signUp"].(map[string]interface{})
+			Expect(payload["token"]).NotTo(BeEmpty())
+			Expect(payload["user"]).To(HaveKeyWithValue("email", email))
+		})
+
+		It("reports invalid fields", func() {
+			serve(nil, `mutation { signUp(email: "nope", password: "short") { token } }`, nil)
+
+			Expect(code()).To(Equal(graph.CodeInvalid))
+			Expect(result.Errors[0].Extensions["fields"]).To(HaveLen(2))
+		})
+
+		It("says why signups are refused", func() {
+			cfg.Set("signupMode", "closed")
+
+			serve(nil, fmt.Sprintf(`mutation { signUp(email: "%s", password: "p@ssw0rd") { token } }`, faker.Email()), nil)
+
+			Expect(code()).To(Equal(graph.CodeForbidden))
+			Expect(result.Errors[0].Extensions["reason"]).To(Equal("signups_closed"))
+		})
+	})
+
+	Describe("mutation login", func() {
+		It("issues a token acting in the user's organization", func() {
+			serve(nil, fmt.Sprintf(`mutation { login(email: "%s", password: "p@ssw0rd") { token user { id } } }`, owner.Email), nil)
+
+			Expect(result.Errors).To(BeEmpty())
+
+			payload := result.Data["login"].(map[string]interface{})
+			Expect(payload["user"]).To(HaveKeyWithValue("id", owner.PublicID))
+
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set(cfg.GetString("tokenHeader"), payload["token"].(string))
+
+			claimed, err := security.RequestOrganization(cfg, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(claimed).To(Equal(org.PublicID))
+		})
+
+		It("refuses wrong passwords", func() {
+			serve(nil, fmt.Sprintf(`mutation { login(email: "%s", password: "hunter2") { token } }`, owner.Email), nil)
+
+			Expect(code()).To(Equal(graph.CodeUnauthenticated))
+		})
+
+		It("counts each aliased login against the login rate limit", func() {
+			cfg.Set("rateLimit.routes.login.requests", 2)
+
+			serve(nil, fmt.Sprintf(`mutation {
+				a: login(email: "%[1]s", password: "p@ssw0rd") { token }
+				b: login(email: "%[1]s", password: "p@ssw0rd") { token }
+				c: login(email: "%[1]s", password: "p@ssw0rd") { token }
+			}`, owner.Email), nil)
+
+			Expect(code()).To(Equal(graph.CodeRateLimited))
+			Expect(result.Errors[0].Extensions).To(HaveKey("retryAfter"))
+			Expect(result.Data).To(BeNil())
+		})
+	})
+
+	Describe("mutation updateMe", func() {
+		It("changes the user", func() {
+			serve(member, `mutation { updateMe(lastName: "Prefect") { lastName version } }`, nil)
+
+			Expect(result.Errors).To(BeEmpty())
+			Expect(result.Data["updateMe"]).To(HaveKeyWithValue("lastName", "Prefect"))
+			Expect(result.Data["updateMe"]).To(HaveKeyWithValue("version", float64(member.Version+1)))
+		})
+
+		It("refuses stale versions", func() {
+			serve(member, fmt.Sprintf(`mutation { updateMe(lastName: "Prefect", version: %d) { lastName } }`, member.Version+1), nil)
+
+			Expect(code()).To(Equal(graph.CodeConflict))
+		})
+	})
+
+	Describe("limits", func() {
+		It("refuses queries nested too deeply before resolving them", func() {
+			cfg.Set("graphql.maxDepth", 2)
+
+			serve(owner, `{ me { groups { id } } }`, nil)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			Expect(code()).To(Equal(graph.CodeTooDeep))
+			Expect(result.Data).To(BeNil())
+		})
+
+		It("refuses queries that do not parse", func() {
+			serve(owner, `{ me {`, nil)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			Expect(result.Errors).NotTo(BeEmpty())
+		})
+	})
+})
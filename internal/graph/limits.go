@@ -0,0 +1,236 @@
+package graph
+
+import (
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listEstimate is how many items a list field without a page size is assumed
+// to hold when measuring the cost of a query.
+const listEstimate = 10
+
+// cost is the size of a selection: how deep it nests and how many fields it
+// resolves, lists counting their items' fields once per item.  Introspection
+// nests deeper than data usually does, so its depth is kept apart.
+type cost struct {
+	depth         int
+	introspection int
+	complexity    int
+}
+
+// measurer walks an operation with the types of the schema, so that fields
+// are told apart by the type they belong to.
+type measurer struct {
+	cfg       *config.Config
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// multiplier is how many times the selection of a field is resolved: once
+// per user of a page of users, an estimate for other lists, and once for
+// anything else.  Introspection fields are charged once whatever they hold.
+func (m *measurer) multiplier(parent *graphql.Object, f *ast.Field, def *graphql.FieldDefinition) int {
+	if strings.HasPrefix(parent.Name(), "__") {
+		return 1
+	}
+
+	if parent.Name() == "Query" && f.Name.Value == "users" {
+		limit := m.cfg.GetInt("pagination.defaultLimit")
+
+		for _, arg := range f.Arguments {
+			if arg.Name.Value == "limit" {
+				if n, ok := m.intValue(arg.Value); ok && n > 0 {
+					limit = n
+				}
+			}
+		}
+
+		return limit
+	}
+
+	// the users of a page are counted by its size
+	if parent.Name() == "UserPage" {
+		return 1
+	}
+
+	t := def.Type
+
+	if nn, ok := t.(*graphql.NonNull); ok {
+		t = nn.OfType
+	}
+
+	if _, ok := t.(*graphql.List); ok {
+		return listEstimate
+	}
+
+	return 1
+}
+
+func (m *measurer) intValue(v ast.Value) (int, bool) {
+	switch v := v.(type) {
+	case *ast.IntValue:
+		n, ok := literal(v).(float64)
+		return int(n), ok
+	case *ast.Variable:
+		switch n := m.variables[v.Name.Value].(type) {
+		case float64:
+			return int(n), true
+		case int:
+			return n, true
+		}
+	}
+
+	return 0, false
+}
+
+// selection measures a selection set of the given type.  Fragments already on
+// the path are not followed again; validation refuses such cycles anyway.
+func (m *measurer) selection(parent *graphql.Object, set *ast.SelectionSet, visited map[string]bool) cost {
+	var total cost
+
+	if set == nil {
+		return total
+	}
+
+	add := func(c cost) {
+		if c.depth > total.depth {
+			total.depth = c.depth
+		}
+
+		if c.introspection > total.introspection {
+			total.introspection = c.introspection
+		}
+
+		total.complexity += c.complexity
+	}
+
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			add(m.field(parent, sel, visited))
+		case *ast.InlineFragment:
+			add(m.selection(parent, sel.SelectionSet, visited))
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			frag, ok := m.fragments[name]
+
+			if !ok || visited[name] {
+				continue
+			}
+
+			visited[name] = true
+			add(m.selection(parent, frag.SelectionSet, visited))
+			delete(visited, name)
+		}
+	}
+
+	return total
+}
+
+// field measures a field and its selection.  __typename is free; __schema
+// and __type count toward the depth of introspection instead of that of the
+// query.
+func (m *measurer) field(parent *graphql.Object, f *ast.Field, visited map[string]bool) cost {
+	switch f.Name.Value {
+	case "__typename":
+		return cost{}
+	case "__schema":
+		return m.introspect(graphql.SchemaMetaFieldDef, f, visited)
+	case "__type":
+		return m.introspect(graphql.TypeMetaFieldDef, f, visited)
+	}
+
+	def, ok := parent.Fields()[f.Name.Value]
+
+	if !ok {
+		return cost{depth: 1, complexity: 1}
+	}
+
+	child := cost{}
+
+	if obj, ok := graphql.GetNamed(def.Type).(*graphql.Object); ok {
+		child = m.selection(obj, f.SelectionSet, visited)
+	}
+
+	return cost{
+		depth:      child.depth + 1,
+		complexity: 1 + m.multiplier(parent, f, def)*child.complexity,
+	}
+}
+
+// introspect measures a __schema or __type field, whose selection is all
+// introspection.
+func (m *measurer) introspect(def *graphql.FieldDefinition, f *ast.Field, visited map[string]bool) cost {
+	child := cost{}
+
+	if obj, ok := graphql.GetNamed(def.Type).(*graphql.Object); ok {
+		child = m.selection(obj, f.SelectionSet, visited)
+	}
+
+	return cost{
+		introspection: child.depth + 1,
+		complexity:    1 + child.complexity,
+	}
+}
+
+// measure returns the cost of the operation with the given name, or of the
+// only one when the name is empty.
+func measure(cfg *config.Config, schema *graphql.Schema, doc *ast.Document, operation string, variables map[string]interface{}) cost {
+	m := &measurer{
+		cfg:       cfg,
+		fragments: map[string]*ast.FragmentDefinition{},
+		variables: variables,
+	}
+
+	var op *ast.OperationDefinition
+
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if op == nil && (operation == "" || (def.Name != nil && def.Name.Value == operation)) {
+				op = def
+			}
+		}
+	}
+
+	if op == nil {
+		return cost{}
+	}
+
+	root := schema.QueryType()
+
+	if op.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+
+	if root == nil {
+		return cost{}
+	}
+
+	return m.selection(root, op.SelectionSet, map[string]bool{})
+}
+
+// checkLimits refuses operations nesting deeper or resolving more fields than
+// configured.
+func checkLimits(cfg *config.Config, schema *graphql.Schema, doc *ast.Document, operation string, variables map[string]interface{}) *Error {
+	c := measure(cfg, schema, doc, operation, variables)
+
+	if max := cfg.GetInt("graphql.maxDepth"); c.depth > max {
+		return newError(CodeTooDeep, "The query is %d levels deep, more than the %d allowed", c.depth, max)
+	}
+
+	if max := cfg.GetInt("graphql.maxIntrospectionDepth"); c.introspection > max {
+		return newError(CodeTooDeep, "The introspection query is %d levels deep, more than the %d allowed", c.introspection, max)
+	}
+
+	if max := cfg.GetInt("graphql.maxComplexity"); c.complexity > max {
+		return newError(CodeTooComplex, "The query has a complexity of %d, more than the %d allowed", c.complexity, max)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/testutil"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("graph/limits.go", func() {
+	var (
+		cfg    *config.Config
+		schema graphql.Schema
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("pagination.defaultLimit", 50)
+
+		var err error
+		schema, err = NewSchema(cfg)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	measured := func(query string, variables map[string]interface{}) cost {
+		doc, err := parser.Parse(parser.ParseParams{Source: query})
+		Expect(err).NotTo(HaveOccurred())
+
+		return measure(cfg, &schema, doc, "", variables)
+	}
+
+	Describe("measure()", func() {
+		It("counts each field once", func() {
+			Expect(measured(`{ me { id email } }`, nil)).To(Equal(cost{depth: 2, complexity: 3}))
+		})
+
+		It("multiplies the fields of lists by an estimate", func() {
+			Expect(measured(`{ me { groups { id name } } }`, nil)).To(Equal(cost{depth: 3, complexity: 1 + 1 + listEstimate*2}))
+		})
+
+		It("multiplies the fields of a page of users by its size", func() {
+			Expect(measured(`{ users(limit: 5) { users { id } } }`, nil).complexity).To(Equal(1 + 5*2))
+			Expect(measured(`query($n: Int) { users(limit: $n) { users { id } } }`, map[string]interface{}{"n": float64(7)}).complexity).To(Equal(1 + 7*2))
+			Expect(measured(`{ users { users { id } } }`, nil).complexity).To(Equal(1 + 50*2))
+		})
+
+		It("follows fragments", func() {
+			c := measured(`{ me { ...names } } fragment names on User { firstName lastName }`, nil)
+			Expect(c).To(Equal(cost{depth: 2, complexity: 3}))
+		})
+
+		It("charges introspection fields once each, apart from the depth of the query", func() {
+			Expect(measured(`{ __schema { types { name fields { name } } } }`, nil)).To(Equal(cost{introspection: 4, complexity: 5}))
+			Expect(measured(`{ me { __typename id } }`, nil)).To(Equal(cost{depth: 2, complexity: 2}))
+		})
+	})
+
+	Describe("checkLimits()", func() {
+		It("refuses queries nested too deeply", func() {
+			cfg.Set("graphql.maxDepth", 2)
+
+			doc, _ := parser.Parse(parser.ParseParams{Source: `{ me { groups { id } } }`})
+
+			err := checkLimits(cfg, &schema, doc, "", nil)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Code).To(Equal(CodeTooDeep))
+		})
+
+		It("refuses introspection nested too deeply", func() {
+			cfg.Set("graphql.maxIntrospectionDepth", 15)
+
+			ofType := "name"
+			for i := 0; i < 20; i++ {
+				ofType = "ofType { " + ofType + " }"
+			}
+
+			doc, _ := parser.Parse(parser.ParseParams{Source: `{ __schema { types { fields { type { ` + ofType + ` } } } } }`})
+
+			err := checkLimits(cfg, &schema, doc, "", nil)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Code).To(Equal(CodeTooDeep))
+		})
+
+		It("allows the introspection query of GraphiQL", func() {
+			doc, err := parser.Parse(parser.ParseParams{Source: testutil.IntrospectionQuery})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(checkLimits(cfg, &schema, doc, "", nil)).To(BeNil())
+		})
+
+		It("refuses queries that are too complex", func() {
+			cfg.Set("graphql.maxComplexity", 100)
+
+			doc, _ := parser.Parse(parser.ParseParams{Source: `{ users(limit: 200) { users { id } } }`})
+
+			err := checkLimits(cfg, &schema, doc, "", nil)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Code).To(Equal(CodeTooComplex))
+		})
+	})
+})
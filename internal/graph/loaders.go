@@ -0,0 +1,197 @@
+package graph
+
+import (
+	"sync"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+)
+
+// loader batches lookups by the primary key of a user.  Load queues a key and
+// returns a thunk; the first thunk called fetches every key queued so far at
+// once.  GraphQL resolves the thunks of a level of the response only after
+// queuing all of them, so a list of users costs one fetch per field rather
+// than one per user.
+type loader struct {
+	fetch func(keys []uint) (map[uint]interface{}, error)
+
+	mu      sync.Mutex
+	pending []uint
+	queued  map[uint]bool
+	values  map[uint]interface{}
+	errs    map[uint]error
+	batches int
+}
+
+func newLoader(fetch func(keys []uint) (map[uint]interface{}, error)) *loader {
+	return &loader{
+		fetch:  fetch,
+		queued: map[uint]bool{},
+		values: map[uint]interface{}{},
+		errs:   map[uint]error{},
+	}
+}
+
+func (l *loader) resolved(key uint) bool {
+	_, ok := l.values[key]
+	_, failed := l.errs[key]
+
+	return ok || failed
+}
+
+// Load queues the key, unless its value is already known, and returns a thunk
+// yielding its value.
+func (l *loader) Load(key uint) func() (interface{}, error) {
+	l.mu.Lock()
+
+	if !l.resolved(key) && !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if !l.resolved(key) {
+			l.dispatch()
+		}
+
+		if err, ok := l.errs[key]; ok {
+			return nil, err
+		}
+
+		return l.values[key], nil
+	}
+}
+
+// dispatch fetches the queued keys.
+func (l *loader) dispatch() {
+	keys := l.pending
+	l.pending = nil
+	l.batches++
+
+	values, err := l.fetch(keys)
+
+	for _, k := range keys {
+		delete(l.queued, k)
+
+		if err != nil {
+			l.errs[k] = err
+		} else {
+			l.values[k] = values[k]
+		}
+	}
+}
+
+// Batches counts the fetches made so far.
+func (l *loader) Batches() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.batches
+}
+
+// loaders are the loaders of one request.
+type loaders struct {
+	groups      *loader
+	preferences *loader
+	sessions    *loader
+
+	// subjects are the subjects of the users whose sessions are queued.
+	subjects map[uint][]string
+}
+
+func newLoaders(cfg *config.Config, tenant uint) *loaders {
+	ls := &loaders{subjects: map[uint][]string{}}
+
+	ls.groups = newLoader(func(keys []uint) (map[uint]interface{}, error) {
+		byUser, err := groups.ForUsers(cfg, tenant, keys)
+
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[uint]interface{}, len(keys))
+
+		for _, k := range keys {
+			ms := byUser[k]
+
+			if ms == nil {
+				ms = []groups.GroupMembership{}
+			}
+
+			values[k] = ms
+		}
+
+		return values, nil
+	})
+
+	ls.preferences = newLoader(func(keys []uint) (map[uint]interface{}, error) {
+		byUser, err := preferences.ForUsers(cfg, keys)
+
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[uint]interface{}, len(keys))
+
+		for k, p := range byUser {
+			values[k] = p
+		}
+
+		return values, nil
+	})
+
+	ls.sessions = newLoader(func(keys []uint) (map[uint]interface{}, error) {
+		owners := map[string]uint{}
+		subjects := []string{}
+
+		for _, k := range keys {
+			for _, subj := range ls.subjects[k] {
+				owners[subj] = k
+				subjects = append(subjects, subj)
+			}
+		}
+
+		revs, err := security.RevocationsFor(cfg, subjects...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		byUser := make(map[uint][]security.Revocation, len(keys))
+
+		for _, k := range keys {
+			byUser[k] = []security.Revocation{}
+		}
+
+		for _, rev := range revs {
+			k := owners[rev.Subject]
+			byUser[k] = append(byUser[k], rev)
+		}
+
+		values := make(map[uint]interface{}, len(keys))
+
+		for k, rs := range byUser {
+			values[k] = rs
+		}
+
+		return values, nil
+	})
+
+	return ls
+}
+
+// sessionsOf queues the lookup of the sessions of the user, known by their
+// public ID or, in older tokens, their email.
+func (ls *loaders) sessionsOf(u *users.User) func() (interface{}, error) {
+	ls.subjects[u.ID] = []string{u.PublicID, u.Email}
+
+	return ls.sessions.Load(u.ID)
+}
@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("graph/loaders.go", func() {
+	var (
+		l       *loader
+		fetched [][]uint
+		failure error
+	)
+
+	BeforeEach(func() {
+		fetched = nil
+		failure = nil
+
+		l = newLoader(func(keys []uint) (map[uint]interface{}, error) {
+			fetched = append(fetched, keys)
+
+			if failure != nil {
+				return nil, failure
+			}
+
+			values := map[uint]interface{}{}
+
+			for _, k := range keys {
+				values[k] = k * 10
+			}
+
+			return values, nil
+		})
+	})
+
+	Describe("Load()", func() {
+		It("fetches the keys queued before any value is needed at once", func() {
+			a, b, c := l.Load(1), l.Load(2), l.Load(1)
+
+			Expect(a()).To(Equal(uint(10)))
+			Expect(b()).To(Equal(uint(20)))
+			Expect(c()).To(Equal(uint(10)))
+			Expect(fetched).To(Equal([][]uint{{1, 2}}))
+			Expect(l.Batches()).To(Equal(1))
+		})
+
+		It("does not fetch known keys again", func() {
+			l.Load(1)()
+			l.Load(1)()
+
+			Expect(l.Batches()).To(Equal(1))
+		})
+
+		It("fails the keys of a failed fetch", func() {
+			failure = errors.New("no")
+
+			_, err := l.Load(1)()
+			Expect(err).To(MatchError(failure))
+		})
+	})
+})
@@ -0,0 +1,682 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/logins"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/ratelimit"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/signups"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// userPage is a page of users, with the cursor of the next, if any.
+type userPage struct {
+	users []*users.User
+	next  string
+}
+
+// authPayload is a token issued to a user who signed up or logged in.
+type authPayload struct {
+	token string
+	user  *users.User
+}
+
+// literal converts a value written in a query to the JSON value it stands
+// for.
+func literal(v ast.Value) interface{} {
+	switch v := v.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.IntValue:
+		n, _ := strconv.ParseFloat(v.Value, 64)
+		return n
+	case *ast.FloatValue:
+		n, _ := strconv.ParseFloat(v.Value, 64)
+		return n
+	case *ast.ListValue:
+		out := make([]interface{}, 0, len(v.Values))
+
+		for _, item := range v.Values {
+			out = append(out, literal(item))
+		}
+
+		return out
+	case *ast.ObjectValue:
+		out := make(map[string]interface{}, len(v.Fields))
+
+		for _, f := range v.Fields {
+			out[f.Name.Value] = literal(f.Value)
+		}
+
+		return out
+	}
+
+	return nil
+}
+
+// jsonType carries arbitrary JSON, such as a user's custom attributes.
+var jsonType = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "An arbitrary JSON value.",
+	Serialize: func(v interface{}) interface{} {
+		raw, ok := v.(json.RawMessage)
+
+		if !ok {
+			return v
+		}
+
+		var out interface{}
+
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return nil
+		}
+
+		return out
+	},
+	ParseValue: func(v interface{}) interface{} {
+		return v
+	},
+	ParseLiteral: literal,
+})
+
+func userField(fn func(u *users.User) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*users.User)), nil
+	}
+}
+
+func groupField(fn func(m *groups.GroupMembership) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		m := p.Source.(groups.GroupMembership)
+
+		return fn(&m), nil
+	}
+}
+
+func sessionField(fn func(r *security.Revocation) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		r := p.Source.(security.Revocation)
+
+		return fn(&r), nil
+	}
+}
+
+func preferencesField(fn func(pr *preferences.Preferences) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*preferences.Preferences)), nil
+	}
+}
+
+func emailPreferencesField(fn func(ep preferences.EmailPreferences) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(preferences.EmailPreferences)), nil
+	}
+}
+
+// stringArg returns the argument, or an empty string when it was not given.
+func stringArg(p graphql.ResolveParams, name string) string {
+	s, _ := p.Args[name].(string)
+
+	return s
+}
+
+func newGroupType() *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Group",
+		Description: "A group of the tenant the request acts in, and the user's role in it.",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.ID),
+				Resolve: groupField(func(m *groups.GroupMembership) interface{} { return m.Group.PublicID }),
+			},
+			"name": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: groupField(func(m *groups.GroupMembership) interface{} { return m.Group.Name }),
+			},
+			"description": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: groupField(func(m *groups.GroupMembership) interface{} { return m.Group.Description }),
+			},
+			"role": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: groupField(func(m *groups.GroupMembership) interface{} { return m.Role }),
+			},
+		},
+	})
+}
+
+func newSessionType() *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Session",
+		Description: "The tokens issued to one of the user's subjects up to when they were last revoked.",
+		Fields: graphql.Fields{
+			"subject": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: sessionField(func(r *security.Revocation) interface{} { return r.Subject }),
+			},
+			"revokedAt": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.DateTime),
+				Resolve: sessionField(func(r *security.Revocation) interface{} { return r.RevokedAt }),
+			},
+		},
+	})
+}
+
+func newPreferencesType() *graphql.Object {
+	emailType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "EmailPreferences",
+		Fields: graphql.Fields{
+			"invitations": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Resolve: emailPreferencesField(func(ep preferences.EmailPreferences) interface{} { return ep.Invitations }),
+			},
+			"announcements": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Resolve: emailPreferencesField(func(ep preferences.EmailPreferences) interface{} { return ep.Announcements }),
+			},
+		},
+	})
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Preferences",
+		Fields: graphql.Fields{
+			"theme": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: preferencesField(func(pr *preferences.Preferences) interface{} { return pr.Theme }),
+			},
+			"locale": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: preferencesField(func(pr *preferences.Preferences) interface{} { return pr.Locale }),
+			},
+			"timezone": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: preferencesField(func(pr *preferences.Preferences) interface{} { return pr.Timezone }),
+			},
+			"email": &graphql.Field{
+				Type:    graphql.NewNonNull(emailType),
+				Resolve: preferencesField(func(pr *preferences.Preferences) interface{} { return pr.Email }),
+			},
+		},
+	})
+}
+
+func newUserType(cfg *config.Config) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.ID),
+				Resolve: userField(func(u *users.User) interface{} { return u.PublicID }),
+			},
+			"email": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: userField(func(u *users.User) interface{} { return u.Email }),
+			},
+			"firstName": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: userField(func(u *users.User) interface{} { return u.FirstName }),
+			},
+			"lastName": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: userField(func(u *users.User) interface{} { return u.LastName }),
+			},
+			"avatarUrl": &graphql.Field{
+				Type: graphql.String,
+				Resolve: userField(func(u *users.User) interface{} {
+					if url := users.AvatarURL(u); url != "" {
+						return url
+					}
+
+					return nil
+				}),
+			},
+			"attributes": &graphql.Field{
+				Type:    graphql.NewNonNull(jsonType),
+				Resolve: userField(func(u *users.User) interface{} { return users.NewUserPayload(u).Attributes }),
+			},
+			"version": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Changes with every update; pass it to updateMe to make the update conditional.",
+				Resolve:     userField(func(u *users.User) interface{} { return int(u.Version) }),
+			},
+			"createdAt": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.DateTime),
+				Resolve: userField(func(u *users.User) interface{} { return u.CreatedAt }),
+			},
+			"updatedAt": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.DateTime),
+				Resolve: userField(func(u *users.User) interface{} { return u.UpdatedAt }),
+			},
+			"groups": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(newGroupType()))),
+				Description: "The groups of the tenant the request acts in that the user belongs to.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					u := p.Source.(*users.User)
+
+					return stateOf(p.Context).loaders.groups.Load(u.ID), nil
+				},
+			},
+			"sessions": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(newSessionType()))),
+				Description: "Only the user and admins may see these.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					u := p.Source.(*users.User)
+
+					v, err := viewer(p.Context, cfg)
+
+					if err != nil {
+						return nil, err
+					}
+
+					if v.ID != u.ID && !v.IsAdmin() {
+						return nil, newError(CodeForbidden, "Only the user and admins may see their sessions")
+					}
+
+					return stateOf(p.Context).loaders.sessionsOf(u), nil
+				},
+			},
+			"preferences": &graphql.Field{
+				Type:        graphql.NewNonNull(newPreferencesType()),
+				Description: "Only the user may see these.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					u := p.Source.(*users.User)
+
+					v, err := viewer(p.Context, cfg)
+
+					if err != nil {
+						return nil, err
+					}
+
+					if v.ID != u.ID {
+						return nil, newError(CodeForbidden, "Only the user may see their preferences")
+					}
+
+					return stateOf(p.Context).loaders.preferences.Load(u.ID), nil
+				},
+			},
+		},
+	})
+}
+
+func newQueryType(cfg *config.Config, userType *graphql.Object) *graphql.Object {
+	pageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserPage",
+		Fields: graphql.Fields{
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*userPage).users, nil
+				},
+			},
+			"next": &graphql.Field{
+				Type:        graphql.String,
+				Description: "The cursor of the next page, or null on the last one.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if next := p.Source.(*userPage).next; next != "" {
+						return next, nil
+					}
+
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return viewer(p.Context, cfg)
+				},
+			},
+			"user": &graphql.Field{
+				Type:        userType,
+				Description: "Null when there is no such user in the tenant, or the viewer may not see them.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveUser(cfg, p)
+				},
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(pageType),
+				Args: graphql.FieldConfigArgument{
+					"limit":         &graphql.ArgumentConfig{Type: graphql.Int},
+					"cursor":        &graphql.ArgumentConfig{Type: graphql.String},
+					"email":         &graphql.ArgumentConfig{Type: graphql.String},
+					"name":          &graphql.ArgumentConfig{Type: graphql.String},
+					"createdAfter":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"createdBefore": &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"sort": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "One of createdAt, email, firstName or lastName, optionally prefixed with '-'.",
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveUsers(cfg, p)
+				},
+			},
+		},
+	})
+}
+
+func resolveUser(cfg *config.Config, p graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(p.Context, cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := users.FindByPublicID(cfg, stringArg(p, "id"))
+
+	if errors.Is(err, users.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	inTenant, err := users.InTenant(cfg, u.ID, security.RequestTenant(stateOf(p.Context).r))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// users who may not be seen are indistinguishable from missing ones
+	if !inTenant || !users.CanView(cfg, v, u) {
+		return nil, nil
+	}
+
+	return u, nil
+}
+
+func resolveUsers(cfg *config.Config, p graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(p.Context, cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := security.RequestTenant(stateOf(p.Context).r)
+
+	q := users.Query{
+		Tenant: &tenant,
+		Cursor: stringArg(p, "cursor"),
+		Email:  stringArg(p, "email"),
+		Name:   stringArg(p, "name"),
+		Sort:   stringArg(p, "sort"),
+	}
+
+	if t, ok := p.Args["createdAfter"].(time.Time); ok {
+		q.CreatedAfter = &t
+	}
+
+	if t, ok := p.Args["createdBefore"].(time.Time); ok {
+		q.CreatedBefore = &t
+	}
+
+	if limit, ok := p.Args["limit"].(int); ok {
+		max := cfg.GetInt("pagination.maxLimit")
+
+		if limit < 1 || limit > max {
+			return nil, invalid(validation.Errors{{
+				Field:   "limit",
+				Code:    validation.CodeInvalid,
+				Message: fmt.Sprintf("limit must be between 1 and %d", max),
+			}})
+		}
+
+		q.Limit = limit
+	}
+
+	us, next, err := users.Page(cfg, users.ScopeQuery(cfg, v, q))
+
+	switch {
+	case errors.Is(err, users.ErrInvalidSort):
+		return nil, invalid(validation.Errors{{
+			Field:   "sort",
+			Code:    validation.CodeInvalid,
+			Message: "sort must be one of createdAt, email, firstName or lastName, optionally prefixed with '-'",
+		}})
+	case errors.Is(err, users.ErrInvalidCursor):
+		return nil, invalid(validation.Errors{{
+			Field:   "cursor",
+			Code:    validation.CodeInvalid,
+			Message: err.Error(),
+		}})
+	case err != nil:
+		return nil, err
+	}
+
+	page := &userPage{users: make([]*users.User, 0, len(*us)), next: next}
+
+	for i := range *us {
+		page.users = append(page.users, &(*us)[i])
+	}
+
+	return page, nil
+}
+
+func newMutationType(cfg *config.Config, userType *graphql.Object) *graphql.Object {
+	authType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AuthPayload",
+		Fields: graphql.Fields{
+			"token": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*authPayload).token, nil
+				},
+			},
+			"user": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*authPayload).user, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"signUp": &graphql.Field{
+				Type: graphql.NewNonNull(authType),
+				Args: graphql.FieldConfigArgument{
+					"email":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"password":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"firstName":  &graphql.ArgumentConfig{Type: graphql.String},
+					"lastName":   &graphql.ArgumentConfig{Type: graphql.String},
+					"inviteCode": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveSignUp(cfg, p)
+				},
+			},
+			"login": &graphql.Field{
+				Type: graphql.NewNonNull(authType),
+				Args: graphql.FieldConfigArgument{
+					"email":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"password": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveLogin(cfg, p)
+				},
+			},
+			"updateMe": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Description: "Changes the names and custom attributes given.  Attributes are merged into " +
+					"the user's, a null value removing one.  When a version is given the update only " +
+					"applies to the user at that version.",
+				Args: graphql.FieldConfigArgument{
+					"firstName":  &graphql.ArgumentConfig{Type: graphql.String},
+					"lastName":   &graphql.ArgumentConfig{Type: graphql.String},
+					"attributes": &graphql.ArgumentConfig{Type: jsonType},
+					"version":    &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveUpdateMe(cfg, p)
+				},
+			},
+		},
+	})
+}
+
+// throttle counts the mutation against the named route's rate limit for the
+// request's client, as a request to that route would be, so that aliasing
+// several logins or signups in one operation gains no more attempts.
+func throttle(cfg *config.Config, p graphql.ResolveParams, name string) error {
+	res := ratelimit.TakeForAddr(cfg, name, stateOf(p.Context).r.RemoteAddr)
+
+	if res != nil && !res.Allowed {
+		return &Error{Code: CodeRateLimited, Message: "Too many attempts", RetryAfter: res.RetryAfter}
+	}
+
+	return nil
+}
+
+func resolveSignUp(cfg *config.Config, p graphql.ResolveParams) (interface{}, error) {
+	if err := throttle(cfg, p, "signup"); err != nil {
+		return nil, err
+	}
+
+	u, m, err := signups.SignUp(cfg, stateOf(p.Context).r.RemoteAddr, &signups.Request{
+		Email:      stringArg(p, "email"),
+		Password:   stringArg(p, "password"),
+		FirstName:  stringArg(p, "firstName"),
+		LastName:   stringArg(p, "lastName"),
+		InviteCode: stringArg(p, "inviteCode"),
+	})
+
+	var (
+		errs validation.Errors
+		rej  *signups.Rejection
+	)
+
+	switch {
+	case errors.As(err, &errs):
+		return nil, invalid(errs)
+	case errors.As(err, &rej):
+		return nil, &Error{Code: CodeForbidden, Message: rej.Message, Reason: rej.Code}
+	case err != nil:
+		return nil, newError(CodeInvalid, "%s", err.Error())
+	}
+
+	token, err := organizations.NewToken(cfg, u, m)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &authPayload{token: token, user: u}, nil
+}
+
+func resolveLogin(cfg *config.Config, p graphql.ResolveParams) (interface{}, error) {
+	if err := throttle(cfg, p, "login"); err != nil {
+		return nil, err
+	}
+
+	u, err := logins.Authenticate(cfg, stringArg(p, "email"), stringArg(p, "password"))
+
+	if errors.Is(err, logins.ErrInvalidPassword) || errors.Is(err, users.ErrNotFound) {
+		return nil, newError(CodeUnauthenticated, "Invalid email or password")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := organizations.DefaultMembership(cfg, u.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := organizations.NewToken(cfg, u, m)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &authPayload{token: token, user: u}, nil
+}
+
+func resolveUpdateMe(cfg *config.Config, p graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(p.Context, cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var version uint
+
+	if n, ok := p.Args["version"].(int); ok {
+		version = uint(n)
+	} else if cfg.GetBool("users.requireIfMatch") {
+		return nil, newError(CodePreconditionRequired, "A version is required")
+	}
+
+	changes := map[string]interface{}{}
+
+	for _, k := range []string{"firstName", "lastName", "attributes"} {
+		if value, ok := p.Args[k]; ok {
+			changes[k] = value
+		}
+	}
+
+	patch, err := json.Marshal(changes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := users.Modify(cfg, v.ID, version, func(u *users.User) error {
+		return users.PatchUser(cfg, u, users.MergePatchType, patch)
+	})
+
+	var errs validation.Errors
+
+	switch {
+	case errors.As(err, &errs):
+		return nil, invalid(errs)
+	case errors.Is(err, users.ErrVersionMismatch):
+		return nil, newError(CodeConflict, "%s", err.Error())
+	case errors.Is(err, users.ErrNotFound):
+		return nil, newError(CodeNotFound, "%s", err.Error())
+	case err != nil:
+		return nil, err
+	}
+
+	stateOf(p.Context).viewer = u
+
+	return u, nil
+}
+
+// NewSchema builds the schema of the GraphQL endpoint.
+func NewSchema(cfg *config.Config) (graphql.Schema, error) {
+	userType := newUserType(cfg)
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    newQueryType(cfg, userType),
+		Mutation: newMutationType(cfg, userType),
+	})
+}
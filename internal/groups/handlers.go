@@ -0,0 +1,482 @@
+package groups
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/gorilla/mux"
+)
+
+type requestPayload struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=1000"`
+}
+
+type memberRequestPayload struct {
+	ID   string `json:"id" validate:"required"`
+	Role string `json:"role"`
+}
+
+type MemberPayload struct {
+	ID    string `json:"id"`
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role"`
+}
+
+type GroupPayload struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Role        string          `json:"role,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Members     []MemberPayload `json:"members,omitempty"`
+}
+
+type groupsPayload struct {
+	Groups []GroupPayload `json:"groups"`
+}
+
+func NewMemberPayload(m *GroupMembership) MemberPayload {
+	return MemberPayload{
+		ID:    m.User.PublicID,
+		Email: m.User.Email,
+		Role:  m.Role,
+	}
+}
+
+func NewGroupPayload(g *Group) GroupPayload {
+	gp := GroupPayload{
+		ID:          g.PublicID,
+		Name:        g.Name,
+		Description: g.Description,
+		CreatedAt:   g.CreatedAt,
+	}
+
+	for i := range g.Memberships {
+		gp.Members = append(gp.Members, NewMemberPayload(&g.Memberships[i]))
+	}
+
+	return gp
+}
+
+// newVisibleMemberPayload leaves out the email of members the viewer may not
+// see the record of, as the users endpoints would.
+func newVisibleMemberPayload(cfg *config.Config, viewer *users.User, m *GroupMembership) MemberPayload {
+	mp := NewMemberPayload(m)
+
+	if !users.CanView(cfg, viewer, &m.User) {
+		mp.Email = ""
+	}
+
+	return mp
+}
+
+func newVisibleGroupPayload(cfg *config.Config, viewer *users.User, g *Group) GroupPayload {
+	gp := NewGroupPayload(g)
+
+	for i := range gp.Members {
+		gp.Members[i] = newVisibleMemberPayload(cfg, viewer, &g.Memberships[i])
+	}
+
+	return gp
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var data bytes.Buffer
+
+	err := json.NewEncoder(&data).Encode(v)
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+
+	_, err = w.Write(data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+	}
+}
+
+// mayManage reports whether the user may change a group: its owners may, as
+// may the owners and admins of its organization or, for groups outside any
+// organization, admins.
+func mayManage(r *http.Request, u *users.User, g *Group) bool {
+	for _, m := range g.Memberships {
+		if m.UserID == u.ID && m.Role == RoleOwner {
+			return true
+		}
+	}
+
+	if g.OrganizationID == 0 {
+		return u.IsAdmin()
+	}
+
+	// the organization roles that may manage its members
+	role := security.RequestTenantRole(r)
+
+	return role == "owner" || role == "admin"
+}
+
+// currentGroup returns the current user and the group named in the route,
+// which must belong to the request's tenant.
+func currentGroup(cfg *config.Config, w http.ResponseWriter, r *http.Request) (*users.User, *Group, bool) {
+	cu, err := users.CurrentUser(cfg, r)
+
+	if err != nil {
+		log.Printf("Unable to identify user: %e", err)
+		http.Error(w, "", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	g, err := Find(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return cu, g, true
+}
+
+func decodeGroup(w http.ResponseWriter, r *http.Request) (*requestPayload, bool) {
+	var qp requestPayload
+
+	err := json.NewDecoder(r.Body).Decode(&qp)
+
+	if err != nil {
+		log.Printf("Unable to unmarshal payload: %e", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if errs := validation.Validate(&qp); errs != nil {
+		validation.WriteErrors(w, errs)
+		return nil, false
+	}
+
+	return &qp, true
+}
+
+func nameTaken(w http.ResponseWriter) {
+	validation.WriteErrors(w, validation.Errors{{
+		Field:   "name",
+		Code:    validation.CodeInvalid,
+		Message: ErrNameTaken.Error(),
+	}})
+}
+
+// NewGetHandler lists the groups of the request's tenant.
+func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		gs, err := All(cfg, security.RequestTenant(r))
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		gps := make([]GroupPayload, 0, len(*gs))
+
+		for i := range *gs {
+			gps = append(gps, NewGroupPayload(&(*gs)[i]))
+		}
+
+		writeJSON(w, http.StatusOK, &groupsPayload{Groups: gps})
+	}
+}
+
+// NewPostHandler creates a group in the request's tenant, owned by the
+// current user.
+func NewPostHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		qp, ok := decodeGroup(w, r)
+
+		if !ok {
+			return
+		}
+
+		cu, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		g, err := Create(cfg, security.RequestTenant(r), &Group{
+			Name:        qp.Name,
+			Description: qp.Description,
+		}, cu)
+
+		if errors.Is(err, ErrNameTaken) {
+			nameTaken(w)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		g.Memberships = []GroupMembership{{UserID: cu.ID, Role: RoleOwner, User: *cu}}
+
+		writeJSON(w, http.StatusCreated, NewGroupPayload(g))
+	}
+}
+
+func NewGetOneHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		cu, g, ok := currentGroup(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newVisibleGroupPayload(cfg, cu, g))
+	}
+}
+
+func NewPutHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		qp, ok := decodeGroup(w, r)
+
+		if !ok {
+			return
+		}
+
+		cu, g, ok := currentGroup(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		if !mayManage(r, cu, g) {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		g.Name = qp.Name
+		g.Description = qp.Description
+
+		g, err := Update(cfg, g)
+
+		if errors.Is(err, ErrNameTaken) {
+			nameTaken(w)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newVisibleGroupPayload(cfg, cu, g))
+	}
+}
+
+func NewDeleteHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cu, g, ok := currentGroup(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		if !mayManage(r, cu, g) {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		if err := Delete(cfg, g); err != nil {
+			log.Printf("Unable to delete Group: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// NewAddMemberHandler puts a user of the same tenant in the group.
+func NewAddMemberHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var qp memberRequestPayload
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewDecoder(r.Body).Decode(&qp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if qp.Role == "" {
+			qp.Role = RoleMember
+		}
+
+		errs := validation.Validate(&qp)
+
+		if qp.Role != RoleMember && qp.Role != RoleOwner {
+			errs = append(errs, validation.FieldError{
+				Field:   "role",
+				Code:    validation.CodeInvalid,
+				Message: fmt.Sprintf("role must be %s or %s", RoleMember, RoleOwner),
+			})
+		}
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		cu, g, ok := currentGroup(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		if !mayManage(r, cu, g) {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		u, err := users.FindByPublicID(cfg, qp.ID)
+
+		if err != nil && !errors.Is(err, users.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		inTenant := false
+
+		if err == nil {
+			inTenant, err = users.InTenant(cfg, u.ID, g.OrganizationID)
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// users of other tenants are indistinguishable from missing ones
+		if !inTenant {
+			validation.WriteErrors(w, validation.Errors{{
+				Field:   "id",
+				Code:    validation.CodeInvalid,
+				Message: "no such user",
+			}})
+			return
+		}
+
+		m, err := AddMember(cfg, g, u, qp.Role)
+
+		if errors.Is(err, ErrAlreadyMember) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, newVisibleMemberPayload(cfg, cu, m))
+	}
+}
+
+// NewRemoveMemberHandler takes a user out of the group.  Members may always
+// remove themselves.
+func NewRemoveMemberHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cu, g, ok := currentGroup(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		uid := mux.Vars(r)["userId"]
+
+		if uid != cu.PublicID && !mayManage(r, cu, g) {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		for _, m := range g.Memberships {
+			if m.User.PublicID != uid {
+				continue
+			}
+
+			if err := RemoveMember(cfg, g, m.UserID); err != nil {
+				log.Printf("Unable to remove GroupMembership: %e", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		http.Error(w, "", http.StatusNotFound)
+	}
+}
+
+// NewGetCurrentUserGroupsHandler lists the groups of the request's tenant
+// the current user belongs to, with their role in each.
+func NewGetCurrentUserGroupsHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		cu, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		ms, err := ForUser(cfg, security.RequestTenant(r), cu.ID)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		gps := make([]GroupPayload, 0, len(*ms))
+
+		for i := range *ms {
+			gp := NewGroupPayload(&(*ms)[i].Group)
+			gp.Role = (*ms)[i].Role
+			gps = append(gps, gp)
+		}
+
+		writeJSON(w, http.StatusOK, &groupsPayload{Groups: gps})
+	}
+}
@@ -0,0 +1,191 @@
+package groups_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("groups/handlers.go", func() {
+	var (
+		rr                   *httptest.ResponseRecorder
+		cfg                  *config.Config
+		owner, member, other *users.User
+		org                  *organizations.Organization
+		g                    *groups.Group
+		vars                 map[string]string
+		result               map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		rr = httptest.NewRecorder()
+		result = nil
+
+		owner, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		member, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		other, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+
+		org, _ = organizations.Create(cfg, &organizations.Organization{Name: "Heart of Gold"}, owner)
+		organizations.AddMember(cfg, org.ID, member.ID, organizations.RoleMember)
+
+		g, _ = groups.Create(cfg, org.ID, &groups.Group{Name: "Crew"}, owner)
+		vars = map[string]string{"id": g.PublicID}
+	})
+
+	serve := func(handler http.HandlerFunc, method string, u *users.User, body string) {
+		req, err := http.NewRequest(method, "/groups", bytes.NewBufferString(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		m, _ := organizations.FindMembership(cfg, org.PublicID, u.ID)
+		role := ""
+
+		if m != nil {
+			role = m.Role
+		}
+
+		req = security.WithTenant(mux.SetURLVars(req, vars), org.ID, role)
+
+		token, _ := security.NewTokenForSubjectInOrganization(cfg, u.PublicID, org.PublicID)
+		req.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		handler.ServeHTTP(rr, req)
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	Describe("NewPostHandler()", func() {
+		It("creates a group owned by the user", func() {
+			serve(groups.NewPostHandler(cfg), "POST", member, `{"name": "Engineering"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			Expect(result["members"]).To(HaveLen(1))
+		})
+
+		It("refuses names in use", func() {
+			serve(groups.NewPostHandler(cfg), "POST", member, `{"name": "Crew"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+	})
+
+	Describe("NewGetHandler()", func() {
+		It("lists the tenant's groups", func() {
+			serve(groups.NewGetHandler(cfg), "GET", member, "")
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["groups"]).To(HaveLen(1))
+		})
+	})
+
+	Describe("NewGetOneHandler()", func() {
+		It("returns the group with its members", func() {
+			serve(groups.NewGetOneHandler(cfg), "GET", member, "")
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["name"]).To(Equal("Crew"))
+			Expect(result["members"]).To(HaveLen(1))
+		})
+
+		It("leaves out the emails of members the user may not see", func() {
+			cfg.Set("users.visibility", users.VisibilitySelf)
+			groups.AddMember(cfg, g, member, groups.RoleMember)
+
+			serve(groups.NewGetOneHandler(cfg), "GET", member, "")
+
+			Expect(result["members"]).To(ConsistOf(
+				map[string]interface{}{"id": owner.PublicID, "role": groups.RoleOwner},
+				map[string]interface{}{"id": member.PublicID, "email": member.Email, "role": groups.RoleMember},
+			))
+		})
+	})
+
+	Describe("NewPutHandler()", func() {
+		It("lets owners rename the group", func() {
+			serve(groups.NewPutHandler(cfg), "PUT", owner, `{"name": "Bridge"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["name"]).To(Equal("Bridge"))
+		})
+
+		It("forbids plain members", func() {
+			serve(groups.NewPutHandler(cfg), "PUT", member, `{"name": "Bridge"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("NewDeleteHandler()", func() {
+		It("deletes the group", func() {
+			serve(groups.NewDeleteHandler(cfg), "DELETE", owner, "")
+
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+
+			_, err := groups.Find(cfg, org.ID, g.PublicID)
+			Expect(err).To(Equal(groups.ErrNotFound))
+		})
+	})
+
+	Describe("NewAddMemberHandler()", func() {
+		It("adds members of the organization", func() {
+			serve(groups.NewAddMemberHandler(cfg), "POST", owner, `{"id": "`+member.PublicID+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			Expect(result["role"]).To(Equal(groups.RoleMember))
+		})
+
+		It("refuses users of another tenant", func() {
+			serve(groups.NewAddMemberHandler(cfg), "POST", owner, `{"id": "`+other.PublicID+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+
+		It("refuses existing members", func() {
+			serve(groups.NewAddMemberHandler(cfg), "POST", owner, `{"id": "`+owner.PublicID+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusConflict))
+		})
+	})
+
+	Describe("NewRemoveMemberHandler()", func() {
+		BeforeEach(func() {
+			groups.AddMember(cfg, g, member, groups.RoleMember)
+			vars["userId"] = member.PublicID
+		})
+
+		It("lets members leave", func() {
+			serve(groups.NewRemoveMemberHandler(cfg), "DELETE", member, "")
+
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+		})
+
+		It("forbids members removing others", func() {
+			vars["userId"] = owner.PublicID
+			serve(groups.NewRemoveMemberHandler(cfg), "DELETE", member, "")
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("NewGetCurrentUserGroupsHandler()", func() {
+		It("lists the user's groups with their role", func() {
+			serve(groups.NewGetCurrentUserGroupsHandler(cfg), "GET", owner, "")
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["groups"]).To(HaveLen(1))
+
+			gp := result["groups"].([]interface{})[0].(map[string]interface{})
+			Expect(gp["role"]).To(Equal(groups.RoleOwner))
+		})
+	})
+})
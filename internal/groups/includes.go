@@ -0,0 +1,42 @@
+package groups
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+)
+
+func init() {
+	users.RegisterInclude("groups", nil, includeGroups)
+}
+
+// includeGroups loads the groups of the tenant each user belongs to, with
+// their role in each.
+func includeGroups(cfg *config.Config, tenant uint, us []users.User) (map[uint]interface{}, error) {
+	ids := make([]uint, 0, len(us))
+
+	for _, u := range us {
+		ids = append(ids, u.ID)
+	}
+
+	byUser, err := ForUsers(cfg, tenant, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	gs := make(map[uint]interface{}, len(us))
+
+	for _, u := range us {
+		gps := []GroupPayload{}
+
+		for _, m := range byUser[u.ID] {
+			gp := NewGroupPayload(&m.Group)
+			gp.Role = m.Role
+			gps = append(gps, gp)
+		}
+
+		gs[u.ID] = gps
+	}
+
+	return gs, nil
+}
@@ -0,0 +1,50 @@
+package groups_test
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("groups/includes.go", func() {
+	var (
+		cfg        *config.Config
+		u          *users.User
+		org, other *organizations.Organization
+		g          *groups.Group
+		p          users.Projection
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		org, _ = organizations.Create(cfg, &organizations.Organization{Name: "Heart of Gold"}, u)
+		other, _ = organizations.Create(cfg, &organizations.Organization{Name: "Vogon Constructor Fleet"}, u)
+
+		g, _ = groups.Create(cfg, org.ID, &groups.Group{Name: "Crew"}, u)
+		groups.Create(cfg, other.ID, &groups.Group{Name: "Poets"}, u)
+
+		p = users.Projection{Fields: []string{"id"}, Include: []string{"groups"}}
+	})
+
+	It("includes only the tenant's groups, with the user's role", func() {
+		out, err := p.Render(cfg, org.ID, []users.User{*u})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out[0]).To(HaveKeyWithValue("groups", ConsistOf(
+			And(HaveField("ID", g.PublicID), HaveField("Role", groups.RoleOwner)),
+		)))
+	})
+
+	It("includes nothing outside a tenant", func() {
+		out, err := p.Render(cfg, 0, []users.User{*u})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out[0]).To(HaveKeyWithValue("groups", BeEmpty()))
+	})
+})
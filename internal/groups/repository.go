@@ -0,0 +1,336 @@
+package groups
+
+import (
+	"errors"
+	"log"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleMember = "member"
+)
+
+var (
+	ErrNotFound      = errors.New("Group not found")
+	ErrNameTaken     = errors.New("Group name is already in use")
+	ErrNotMember     = errors.New("User is not a member of the group")
+	ErrAlreadyMember = errors.New("User is already a member of the group")
+)
+
+// Group gathers users of one tenant, the organization with OrganizationID or,
+// when it is zero, the users who belong to no organization.
+type Group struct {
+	gorm.Model
+	PublicID       string `gorm:"uniqueIndex"`
+	OrganizationID uint   `gorm:"index"`
+	Name           string
+	Description    string
+	Memberships    []GroupMembership
+}
+
+// GroupMembership gives a user a role within a group.
+type GroupMembership struct {
+	gorm.Model
+	GroupID uint `gorm:"uniqueIndex:idx_group_memberships_group_user"`
+	UserID  uint `gorm:"uniqueIndex:idx_group_memberships_group_user;index"`
+	Role    string
+	Group   Group
+	User    users.User
+}
+
+func isNameTaken(tx *gorm.DB, tenant uint, name string, except uint) (bool, error) {
+	var count int64
+
+	result := tx.Model(&Group{}).
+		Where("organization_id = ? AND name = ? AND id <> ?", tenant, name, except).
+		Count(&count)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// Create records a new group in the tenant with the user, if any, as its
+// owner.
+func Create(cfg *config.Config, tenant uint, g *Group, owner *users.User) (*Group, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	g.PublicID = users.NewPublicID()
+	g.OrganizationID = tenant
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		taken, err := isNameTaken(tx, tenant, g.Name, 0)
+
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return ErrNameTaken
+		}
+
+		if err := tx.Create(g).Error; err != nil {
+			return err
+		}
+
+		if owner == nil {
+			return nil
+		}
+
+		return tx.Create(&GroupMembership{
+			GroupID: g.ID,
+			UserID:  owner.ID,
+			Role:    RoleOwner,
+		}).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to create Group record: %e", err)
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Find returns the tenant's group with the given public ID, with its members.
+func Find(cfg *config.Config, tenant uint, id string) (*Group, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var g Group
+
+	result := db.Preload("Memberships", func(db *gorm.DB) *gorm.DB {
+		return db.Order("group_memberships.id ASC")
+	}).
+		Preload("Memberships.User").
+		Where("public_id = ? AND organization_id = ?", id, tenant).
+		Limit(1).
+		Find(&g)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &g, nil
+}
+
+// All returns the groups of the tenant, by name.
+func All(cfg *config.Config, tenant uint) (*[]Group, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var gs []Group
+
+	result := db.Where("organization_id = ?", tenant).Order("name ASC, id ASC").Find(&gs)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &gs, nil
+}
+
+// Update renames or redescribes a group.
+func Update(cfg *config.Config, g *Group) (*Group, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		taken, err := isNameTaken(tx, g.OrganizationID, g.Name, g.ID)
+
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return ErrNameTaken
+		}
+
+		return tx.Model(g).Select("name", "description").Updates(g).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to update Group record: %e", err)
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Delete removes a group along with its memberships.
+func Delete(cfg *config.Config, g *Group) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", g.ID).Delete(&GroupMembership{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(g).Error
+	})
+}
+
+// AddMember puts the user in the group.
+func AddMember(cfg *config.Config, g *Group, u *users.User, role string) (*GroupMembership, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var count int64
+
+	result := db.Model(&GroupMembership{}).
+		Where("group_id = ? AND user_id = ?", g.ID, u.ID).
+		Count(&count)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if count > 0 {
+		return nil, ErrAlreadyMember
+	}
+
+	m := &GroupMembership{
+		GroupID: g.ID,
+		UserID:  u.ID,
+		Role:    role,
+		User:    *u,
+	}
+
+	result = db.Omit("User", "Group").Create(m)
+
+	if result.Error != nil {
+		log.Printf("Unable to create GroupMembership record: %e", result.Error)
+		return nil, result.Error
+	}
+
+	return m, nil
+}
+
+// RemoveMember takes the user out of the group.
+func RemoveMember(cfg *config.Config, g *Group, userID uint) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	result := db.Where("group_id = ? AND user_id = ?", g.ID, userID).Delete(&GroupMembership{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotMember
+	}
+
+	return nil
+}
+
+// ForUser returns the user's memberships of the tenant's groups, by group
+// name.
+func ForUser(cfg *config.Config, tenant, userID uint) (*[]GroupMembership, error) {
+	byUser, err := ForUsers(cfg, tenant, []uint{userID})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ms := byUser[userID]
+
+	if ms == nil {
+		ms = []GroupMembership{}
+	}
+
+	return &ms, nil
+}
+
+// ForUsers returns the memberships of the tenant's groups of each of the users
+// with the given IDs, by group name.  Users in no group are left out.
+func ForUsers(cfg *config.Config, tenant uint, userIDs []uint) (map[uint][]GroupMembership, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var ms []GroupMembership
+
+	result := db.Joins("Group").
+		Where(`group_memberships.user_id IN ? AND "Group".organization_id = ?`, userIDs, tenant).
+		Order(`"Group".name ASC, "Group".id ASC`).
+		Find(&ms)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	byUser := make(map[uint][]GroupMembership)
+
+	for _, m := range ms {
+		byUser[m.UserID] = append(byUser[m.UserID], m)
+	}
+
+	return byUser, nil
+}
+
+// Claims returns the public IDs of the tenant's groups the user belongs to,
+// for embedding in their tokens, or nil when group claims are turned off.
+func Claims(cfg *config.Config, tenant, userID uint) ([]string, error) {
+	if !cfg.GetBool("tokens.groupClaims") {
+		return nil, nil
+	}
+
+	ms, err := ForUser(cfg, tenant, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(*ms))
+
+	for _, m := range *ms {
+		ids = append(ids, m.Group.PublicID)
+	}
+
+	return ids, nil
+}
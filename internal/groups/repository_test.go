@@ -0,0 +1,108 @@
+package groups
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("groups/repository.go", func() {
+	var (
+		cfg          *config.Config
+		owner, other *users.User
+		tenant       uint
+		g            *Group
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+
+		owner, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		other, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+
+		// a tenant of its own, so that names never clash with other specs
+		tenant = 1_000_000 + owner.ID
+
+		var err error
+		g, err = Create(cfg, tenant, &Group{Name: "Crew"}, owner)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Create()", func() {
+		It("makes the creator its owner", func() {
+			found, err := Find(cfg, tenant, g.PublicID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.Memberships).To(HaveLen(1))
+			Expect(found.Memberships[0].Role).To(Equal(RoleOwner))
+			Expect(found.Memberships[0].User.Email).To(Equal(owner.Email))
+		})
+
+		It("refuses names already used in the tenant", func() {
+			_, err := Create(cfg, tenant, &Group{Name: "Crew"}, owner)
+			Expect(err).To(Equal(ErrNameTaken))
+		})
+
+		It("allows the name in another tenant", func() {
+			_, err := Create(cfg, tenant+1, &Group{Name: "Crew"}, owner)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Find()", func() {
+		It("does not find groups of another tenant", func() {
+			_, err := Find(cfg, tenant+1, g.PublicID)
+			Expect(err).To(Equal(ErrNotFound))
+		})
+	})
+
+	Describe("AddMember()", func() {
+		It("refuses existing members", func() {
+			_, err := AddMember(cfg, g, owner, RoleMember)
+			Expect(err).To(Equal(ErrAlreadyMember))
+		})
+	})
+
+	Describe("RemoveMember()", func() {
+		It("removes the member", func() {
+			AddMember(cfg, g, other, RoleMember)
+
+			Expect(RemoveMember(cfg, g, other.ID)).To(Succeed())
+			Expect(RemoveMember(cfg, g, other.ID)).To(Equal(ErrNotMember))
+		})
+	})
+
+	Describe("Delete()", func() {
+		It("removes the group and its memberships", func() {
+			Expect(Delete(cfg, g)).To(Succeed())
+
+			ms, _ := ForUser(cfg, tenant, owner.ID)
+			Expect(*ms).To(BeEmpty())
+		})
+	})
+
+	Describe("Claims()", func() {
+		It("is nil when group claims are off", func() {
+			ids, _ := Claims(cfg, tenant, owner.ID)
+			Expect(ids).To(BeNil())
+		})
+
+		It("lists the user's groups when they are on", func() {
+			cfg.Set("tokens.groupClaims", true)
+
+			ids, _ := Claims(cfg, tenant, owner.ID)
+			Expect(ids).To(ConsistOf(g.PublicID))
+		})
+	})
+
+	Describe("ForUsers()", func() {
+		It("returns the memberships of each user, leaving out those in no group", func() {
+			byUser, err := ForUsers(cfg, tenant, []uint{owner.ID, other.ID})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(byUser).To(HaveLen(1))
+			Expect(byUser[owner.ID]).To(HaveLen(1))
+			Expect(byUser[owner.ID][0].Group.PublicID).To(Equal(g.PublicID))
+		})
+	})
+})
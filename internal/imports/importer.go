@@ -0,0 +1,462 @@
+package imports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/notifications"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+// What to do with a row whose email belongs to an existing user.
+const (
+	DuplicateSkip   = "skip"
+	DuplicateUpdate = "update"
+	DuplicateFail   = "fail"
+)
+
+// Which email, if any, to send the users an import creates or updates.
+const (
+	NotifyNone       = "none"
+	NotifyInvitation = "invitation"
+	NotifyReset      = "reset"
+)
+
+const (
+	CodeDuplicate  = "duplicate"
+	CodeRolledBack = "rolled_back"
+)
+
+var (
+	ErrDuplicate = errors.New("Import stopped at a duplicate email")
+
+	// errDryRun rolls back a batch once it has been written.
+	errDryRun = errors.New("Dry run")
+)
+
+type Options struct {
+	Format      string
+	DryRun      bool
+	OnDuplicate string
+	Notify      string
+	BatchSize   int
+
+	// Report receives a JSON line for each rejected row, when set.
+	Report io.Writer
+}
+
+// Summary counts what an import did with its rows.  In a dry run it counts
+// what the import would have done.
+type Summary struct {
+	Rows    int
+	Created int
+	Updated int
+	Skipped int
+	Failed  int
+}
+
+// ReportEntry describes a rejected row.
+type ReportEntry struct {
+	Line   int               `json:"line"`
+	Email  string            `json:"email"`
+	Errors validation.Errors `json:"errors"`
+}
+
+type outcome struct {
+	row      *Row
+	created  bool
+	skipped  bool
+	password string
+}
+
+type importer struct {
+	cfg     *config.Config
+	db      *gorm.DB
+	opts    Options
+	summary Summary
+	report  *json.Encoder
+}
+
+// Run reads users from r and writes them in transactions of BatchSize rows.
+// Invalid rows are reported and left out; the import stops at the first
+// error writing a batch, which is then rolled back in full.
+func Run(cfg *config.Config, r io.Reader, opts Options) (*Summary, error) {
+	if opts.OnDuplicate == "" {
+		opts.OnDuplicate = DuplicateFail
+	}
+
+	if opts.Notify == "" {
+		opts.Notify = NotifyNone
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = cfg.GetInt("imports.batchSize")
+	}
+
+	switch opts.OnDuplicate {
+	case DuplicateSkip, DuplicateUpdate, DuplicateFail:
+	default:
+		return nil, fmt.Errorf("Unknown duplicate strategy '%s'", opts.OnDuplicate)
+	}
+
+	switch opts.Notify {
+	case NotifyNone, NotifyInvitation, NotifyReset:
+	default:
+		return nil, fmt.Errorf("Unknown notification '%s'", opts.Notify)
+	}
+
+	rd, err := newReader(opts.Format, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	im := &importer{cfg: cfg, db: db.DB, opts: opts}
+
+	if opts.Report != nil {
+		im.report = json.NewEncoder(opts.Report)
+	}
+
+	var batch []*Row
+
+	for {
+		row, errs, err := rd.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return &im.summary, err
+		}
+
+		im.summary.Rows++
+
+		if errs == nil {
+			errs, err = im.check(row)
+
+			if err != nil {
+				return &im.summary, err
+			}
+		}
+
+		if errs != nil {
+			im.reject(row, errs)
+			continue
+		}
+
+		batch = append(batch, row)
+
+		if len(batch) >= opts.BatchSize {
+			if err := im.flush(batch); err != nil {
+				return &im.summary, err
+			}
+
+			batch = batch[:0]
+		}
+	}
+
+	if err := im.flush(batch); err != nil {
+		return &im.summary, err
+	}
+
+	return &im.summary, nil
+}
+
+// check validates a row as the API would validate the same user.
+func (im *importer) check(row *Row) (validation.Errors, error) {
+	errs := validation.Validate(row)
+
+	if row.Role != "" && row.Role != users.RoleUser && row.Role != users.RoleAdmin {
+		errs = append(errs, validation.FieldError{
+			Field:   "role",
+			Code:    validation.CodeInvalid,
+			Message: fmt.Sprintf("role must be %s or %s", users.RoleUser, users.RoleAdmin),
+		})
+	}
+
+	attrs, aerrs, err := users.ValidateAttributes(im.cfg, row.Attributes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if attrs != "" {
+		row.Attributes = json.RawMessage(attrs)
+	}
+
+	return append(errs, aerrs...), nil
+}
+
+func (im *importer) reject(row *Row, errs validation.Errors) {
+	im.summary.Failed++
+
+	if im.report == nil {
+		return
+	}
+
+	err := im.report.Encode(&ReportEntry{
+		Line:   row.Line,
+		Email:  row.Email,
+		Errors: errs,
+	})
+
+	if err != nil {
+		log.Printf("Unable to report row %d: %e", row.Line, err)
+	}
+}
+
+// flush writes a batch in one transaction, rolling it back in a dry run.
+func (im *importer) flush(batch []*Row) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var (
+		outcomes []outcome
+		failed   *Row
+		cause    validation.Errors
+	)
+
+	err := im.db.Transaction(func(tx *gorm.DB) error {
+		outcomes = outcomes[:0]
+
+		for _, row := range batch {
+			o, errs, err := im.write(tx, row)
+
+			if errs != nil {
+				failed, cause = row, errs
+				return ErrDuplicate
+			}
+
+			if err != nil {
+				failed = row
+				return err
+			}
+
+			outcomes = append(outcomes, o)
+		}
+
+		if im.opts.DryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+
+	if err != nil && err != errDryRun {
+		for _, row := range batch {
+			switch {
+			case row != failed:
+				im.reject(row, validation.Errors{{
+					Code:    CodeRolledBack,
+					Message: "not imported because another row of its batch failed",
+				}})
+			case cause != nil:
+				im.reject(row, cause)
+			default:
+				im.reject(row, validation.Errors{{Code: validation.CodeInvalid, Message: err.Error()}})
+			}
+		}
+
+		return err
+	}
+
+	for _, o := range outcomes {
+		switch {
+		case o.skipped:
+			im.summary.Skipped++
+		case o.created:
+			im.summary.Created++
+		default:
+			im.summary.Updated++
+		}
+
+		if !im.opts.DryRun {
+			im.notify(&o)
+		}
+	}
+
+	return nil
+}
+
+// write creates the row's user, or deals with an existing one according to
+// the duplicate strategy.  It returns errors, rather than failing, when the
+// row is a duplicate that stops the import.
+func (im *importer) write(tx *gorm.DB, row *Row) (outcome, validation.Errors, error) {
+	o := outcome{row: row}
+
+	var existing users.User
+
+	result := tx.Unscoped().Where("email = ?", row.Email).Limit(1).Find(&existing)
+
+	if result.Error != nil {
+		return o, nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return im.create(tx, row)
+	}
+
+	switch {
+	case im.opts.OnDuplicate == DuplicateSkip:
+		o.skipped = true
+		return o, nil, nil
+	case im.opts.OnDuplicate == DuplicateUpdate && !existing.DeletedAt.Valid:
+		return im.update(tx, row, &existing)
+	default:
+		return o, validation.Errors{{
+			Field:   "email",
+			Code:    CodeDuplicate,
+			Message: fmt.Sprintf("a user with email '%s' already exists", row.Email),
+		}}, nil
+	}
+}
+
+// temporaryPassword keeps users imported without a password from signing in
+// with an empty one.
+func temporaryPassword(o *outcome) (string, error) {
+	p, err := security.RandomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	o.password = p
+
+	return p, nil
+}
+
+func (im *importer) create(tx *gorm.DB, row *Row) (outcome, validation.Errors, error) {
+	o := outcome{row: row, created: true}
+
+	u := &users.User{
+		Email:               row.Email,
+		UnencryptedPassword: row.Password,
+		FirstName:           row.FirstName,
+		LastName:            row.LastName,
+		Role:                row.Role,
+		Attributes:          string(row.Attributes),
+	}
+
+	if u.UnencryptedPassword == "" {
+		p, err := temporaryPassword(&o)
+
+		if err != nil {
+			return o, nil, err
+		}
+
+		u.UnencryptedPassword = p
+	}
+
+	if err := tx.Create(u).Error; err != nil {
+		return o, nil, err
+	}
+
+	if err := users.RecordRevision(tx, u.ID, users.ActorImport); err != nil {
+		return o, nil, err
+	}
+
+	return o, nil, nil
+}
+
+// update overwrites the fields the row gives a value for.  Reset emails come
+// with a new temporary password unless the row sets one.
+func (im *importer) update(tx *gorm.DB, row *Row, u *users.User) (outcome, validation.Errors, error) {
+	o := outcome{row: row}
+
+	columns := users.Changed(map[string]interface{}{})
+
+	if row.FirstName != "" {
+		columns["first_name"] = row.FirstName
+	}
+
+	if row.LastName != "" {
+		columns["last_name"] = row.LastName
+	}
+
+	if row.Role != "" {
+		columns["role"] = row.Role
+	}
+
+	if row.Attributes != nil {
+		columns["attributes"] = string(row.Attributes)
+	}
+
+	if row.Password != "" {
+		columns["unencrypted_password"] = row.Password
+	} else if im.opts.Notify == NotifyReset {
+		p, err := temporaryPassword(&o)
+
+		if err != nil {
+			return o, nil, err
+		}
+
+		columns["unencrypted_password"] = p
+	}
+
+	if err := tx.Model(&users.User{}).Where("id = ?", u.ID).UpdateColumns(columns).Error; err != nil {
+		return o, nil, err
+	}
+
+	// a new password ends the sessions signed in with the old one
+	if _, ok := columns["unencrypted_password"]; ok {
+		for _, subj := range []string{u.PublicID, u.Email} {
+			if err := security.RevokeSubjectTx(tx, subj); err != nil {
+				return o, nil, err
+			}
+		}
+	}
+
+	if err := users.RecordRevision(tx, u.ID, users.ActorImport); err != nil {
+		return o, nil, err
+	}
+
+	return o, nil, nil
+}
+
+// notify emails a created or updated user; invitations only go to users the
+// import created.
+func (im *importer) notify(o *outcome) {
+	if o.skipped || im.opts.Notify == NotifyNone {
+		return
+	}
+
+	if im.opts.Notify == NotifyInvitation && !o.created {
+		return
+	}
+
+	m := notifications.Message{To: o.row.Email}
+
+	switch im.opts.Notify {
+	case NotifyInvitation:
+		m.Subject = "An account has been created for you"
+		m.Body = fmt.Sprintf("An account has been created for '%s'.", o.row.Email)
+	case NotifyReset:
+		m.Subject = "Your password has been reset"
+		m.Body = fmt.Sprintf("The password of the account for '%s' has been reset.", o.row.Email)
+	}
+
+	if o.password != "" {
+		m.Body += fmt.Sprintf("  Log in with the temporary password below.\n\n%s", o.password)
+	}
+
+	if err := notifications.Send(im.cfg, m); err != nil {
+		log.Printf("Unable to notify '%s' of their import: %e", o.row.Email, err)
+	}
+}
@@ -0,0 +1,184 @@
+package imports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/notifications"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("imports/importer.go", func() {
+	var (
+		cfg          *config.Config
+		ford, arthur string
+		opts         Options
+		report       bytes.Buffer
+		input        string
+		summary      *Summary
+		err          error
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("mailer", "memory")
+
+		ford = faker.Email()
+		arthur = faker.Email()
+
+		report.Reset()
+		opts = Options{Format: FormatCSV, Report: &report}
+
+		input = fmt.Sprintf("email,firstName,lastName\n%s,Ford,Prefect\nnot-an-email,Zaphod,\n%s,Arthur,Dent\n", ford, arthur)
+	})
+
+	JustBeforeEach(func() {
+		summary, err = Run(cfg, strings.NewReader(input), opts)
+	})
+
+	It("creates the valid rows", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*summary).To(Equal(Summary{Rows: 3, Created: 2, Failed: 1}))
+
+		u, _ := users.FindByEmail(cfg, arthur)
+		Expect(u.LastName).To(Equal("Dent"))
+	})
+
+	It("gives users without a password a random one", func() {
+		u, _ := users.FindByEmail(cfg, ford)
+		Expect(u.UnencryptedPassword).NotTo(BeEmpty())
+	})
+
+	It("reports the invalid rows", func() {
+		var entry ReportEntry
+
+		Expect(json.Unmarshal(report.Bytes(), &entry)).To(Succeed())
+		Expect(entry.Line).To(Equal(3))
+		Expect(entry.Errors[0].Field).To(Equal("email"))
+	})
+
+	When("it is a dry run", func() {
+		BeforeEach(func() {
+			opts.DryRun = true
+		})
+
+		It("counts what it would do", func() {
+			Expect(summary.Created).To(Equal(2))
+		})
+
+		It("writes nothing", func() {
+			_, err := users.FindByEmail(cfg, ford)
+			Expect(err).To(Equal(users.ErrNotFound))
+		})
+	})
+
+	When("a user exists", func() {
+		var token string
+
+		BeforeEach(func() {
+			u, _ := users.Create(cfg, &users.User{Email: ford, FirstName: "Zaphod", UnencryptedPassword: "p@ssw0rd"})
+			token, _ = security.NewTokenForSubject(cfg, u.PublicID)
+		})
+
+		When("duplicates are skipped", func() {
+			BeforeEach(func() {
+				opts.OnDuplicate = DuplicateSkip
+			})
+
+			It("leaves them unchanged", func() {
+				u, _ := users.FindByEmail(cfg, ford)
+
+				Expect(summary.Skipped).To(Equal(1))
+				Expect(u.FirstName).To(Equal("Zaphod"))
+			})
+		})
+
+		When("duplicates are updated", func() {
+			BeforeEach(func() {
+				opts.OnDuplicate = DuplicateUpdate
+			})
+
+			It("overwrites the given fields", func() {
+				u, _ := users.FindByEmail(cfg, ford)
+
+				Expect(summary.Updated).To(Equal(1))
+				Expect(u.FirstName).To(Equal("Ford"))
+				Expect(u.UnencryptedPassword).To(Equal("p@ssw0rd"))
+				Expect(u.Version).To(BeNumerically("==", 2))
+			})
+
+			It("keeps the user's sessions", func() {
+				ok, _ := security.IsValidToken(cfg, token)
+				Expect(ok).To(BeTrue())
+			})
+
+			When("passwords are reset", func() {
+				BeforeEach(func() {
+					opts.Notify = NotifyReset
+				})
+
+				It("ends the user's sessions", func() {
+					ok, _ := security.IsValidToken(cfg, token)
+					Expect(ok).To(BeFalse())
+				})
+			})
+		})
+
+		When("duplicates fail the import", func() {
+			BeforeEach(func() {
+				opts.OnDuplicate = DuplicateFail
+			})
+
+			It("stops", func() {
+				Expect(err).To(Equal(ErrDuplicate))
+			})
+
+			It("rolls back the batch", func() {
+				_, err := users.FindByEmail(cfg, arthur)
+				Expect(err).To(Equal(users.ErrNotFound))
+			})
+
+			It("reports the duplicate", func() {
+				Expect(report.String()).To(ContainSubstring(`"code":"duplicate"`))
+				Expect(report.String()).To(ContainSubstring(`"code":"rolled_back"`))
+			})
+		})
+	})
+
+	When("invitations are sent", func() {
+		BeforeEach(func() {
+			opts.Notify = NotifyInvitation
+		})
+
+		It("emails each created user their temporary password", func() {
+			u, _ := users.FindByEmail(cfg, arthur)
+			ms := notifications.Outbox.To(arthur)
+
+			Expect(ms).To(HaveLen(1))
+			Expect(ms[0].Body).To(ContainSubstring(u.UnencryptedPassword))
+		})
+	})
+
+	When("the import is split into batches", func() {
+		BeforeEach(func() {
+			opts.BatchSize = 1
+			opts.OnDuplicate = DuplicateFail
+			input = fmt.Sprintf("email\n%s\n%s\n", ford, ford)
+		})
+
+		It("keeps the batches written before a failure", func() {
+			Expect(err).To(Equal(ErrDuplicate))
+			Expect(summary.Created).To(Equal(1))
+
+			_, err := users.FindByEmail(cfg, ford)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
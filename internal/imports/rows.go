@@ -0,0 +1,196 @@
+package imports
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/validation"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+var (
+	ErrUnknownFormat = errors.New("Unknown import format")
+	ErrUnknownColumn = errors.New("Unknown import column")
+	ErrMissingEmail  = errors.New("Import has no email column")
+)
+
+// Row is one user read from an import, with the line it started on.
+type Row struct {
+	Line       int             `json:"-"`
+	Email      string          `json:"email" validate:"required,email,max=254"`
+	Password   string          `json:"password" validate:"raw,min=8,max=72"`
+	FirstName  string          `json:"firstName" validate:"max=100"`
+	LastName   string          `json:"lastName" validate:"max=100"`
+	Role       string          `json:"role"`
+	Attributes json.RawMessage `json:"attributes"`
+}
+
+// reader yields the rows of an import in order.  A row that cannot be parsed
+// comes back with errors describing why; any other error ends the import.
+type reader interface {
+	Next() (*Row, validation.Errors, error)
+}
+
+// FormatFor guesses the format of an import from its file name.
+func FormatFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	default:
+		return ""
+	}
+}
+
+func newReader(format string, r io.Reader) (reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL, "ndjson":
+		return &jsonlReader{r: bufio.NewReader(r)}, nil
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownFormat, format)
+	}
+}
+
+func parseError(line int, err error) validation.Errors {
+	return validation.Errors{{
+		Field:   "",
+		Code:    validation.CodeInvalid,
+		Message: fmt.Sprintf("line %d could not be parsed: %v", line, err),
+	}}
+}
+
+// columnName normalizes a CSV header so that "first_name", "First Name" and
+// "firstName" name the same column.
+func columnName(h string) string {
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(h)))
+}
+
+// columnSetters fill in a Row field from a CSV cell, by normalized header.
+var columnSetters = map[string]func(row *Row, v string){
+	"email":     func(row *Row, v string) { row.Email = v },
+	"password":  func(row *Row, v string) { row.Password = v },
+	"firstname": func(row *Row, v string) { row.FirstName = v },
+	"lastname":  func(row *Row, v string) { row.LastName = v },
+	"role":      func(row *Row, v string) { row.Role = v },
+	"attributes": func(row *Row, v string) {
+		if v != "" {
+			row.Attributes = json.RawMessage(v)
+		}
+	},
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	setters []func(row *Row, v string)
+}
+
+// newCSVReader reads the header row, which must name every column.
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	setters := make([]func(row *Row, v string), len(header))
+	hasEmail := false
+
+	for i, h := range header {
+		name := columnName(strings.TrimPrefix(h, "\ufeff"))
+		set, ok := columnSetters[name]
+
+		if !ok {
+			return nil, fmt.Errorf("%w '%s'", ErrUnknownColumn, h)
+		}
+
+		setters[i] = set
+		hasEmail = hasEmail || name == "email"
+	}
+
+	if !hasEmail {
+		return nil, ErrMissingEmail
+	}
+
+	cr.FieldsPerRecord = len(header)
+	cr.ReuseRecord = true
+
+	return &csvReader{r: cr, setters: setters}, nil
+}
+
+func (cr *csvReader) Next() (*Row, validation.Errors, error) {
+	record, err := cr.r.Read()
+
+	var pe *csv.ParseError
+
+	if errors.As(err, &pe) {
+		return &Row{Line: pe.StartLine}, parseError(pe.StartLine, pe.Err), nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	line, _ := cr.r.FieldPos(0)
+	row := &Row{Line: line}
+
+	for i, v := range record {
+		cr.setters[i](row, v)
+	}
+
+	return row, nil, nil
+}
+
+type jsonlReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (jr *jsonlReader) Next() (*Row, validation.Errors, error) {
+	for {
+		data, err := jr.r.ReadBytes('\n')
+
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+
+		if len(data) == 0 && err == io.EOF {
+			return nil, nil, io.EOF
+		}
+
+		jr.line++
+		data = bytes.TrimSpace(data)
+
+		if len(data) == 0 {
+			continue
+		}
+
+		row := &Row{Line: jr.line}
+
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(row); err != nil {
+			return row, parseError(jr.line, err), nil
+		}
+
+		row.Line = jr.line
+
+		return row, nil, nil
+	}
+}
@@ -0,0 +1,91 @@
+package imports
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("imports/rows.go", func() {
+	Describe("FormatFor()", func() {
+		It("recognizes CSV and JSONL files", func() {
+			Expect(FormatFor("users.CSV")).To(Equal(FormatCSV))
+			Expect(FormatFor("users.ndjson")).To(Equal(FormatJSONL))
+			Expect(FormatFor("users.txt")).To(BeEmpty())
+		})
+	})
+
+	Describe("csvReader", func() {
+		It("maps columns by header", func() {
+			rd, err := newReader(FormatCSV, strings.NewReader("Email,first_name,Last Name\nford@example.com,Ford,Prefect\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			row, errs, err := rd.Next()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errs).To(BeNil())
+			Expect(row.Line).To(Equal(2))
+			Expect(row.Email).To(Equal("ford@example.com"))
+			Expect(row.FirstName).To(Equal("Ford"))
+			Expect(row.LastName).To(Equal("Prefect"))
+
+			_, _, err = rd.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("reports rows with the wrong number of cells", func() {
+			rd, _ := newReader(FormatCSV, strings.NewReader("email,firstName\nford@example.com\narthur@example.com,Arthur\n"))
+
+			row, errs, err := rd.Next()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errs).To(HaveLen(1))
+			Expect(row.Line).To(Equal(2))
+
+			row, errs, _ = rd.Next()
+
+			Expect(errs).To(BeNil())
+			Expect(row.FirstName).To(Equal("Arthur"))
+		})
+
+		It("refuses unknown columns", func() {
+			_, err := newReader(FormatCSV, strings.NewReader("email,shoeSize\n"))
+			Expect(errors.Is(err, ErrUnknownColumn)).To(BeTrue())
+		})
+
+		It("requires an email column", func() {
+			_, err := newReader(FormatCSV, strings.NewReader("firstName\n"))
+			Expect(err).To(Equal(ErrMissingEmail))
+		})
+	})
+
+	Describe("jsonlReader", func() {
+		It("reads one user per line, skipping blank ones", func() {
+			rd, _ := newReader(FormatJSONL, strings.NewReader(`{"email":"ford@example.com","attributes":{"a":1}}`+"\n\n"+`{"email":"arthur@example.com"}`))
+
+			row, _, _ := rd.Next()
+			Expect(row.Email).To(Equal("ford@example.com"))
+			Expect(string(row.Attributes)).To(Equal(`{"a":1}`))
+
+			row, _, _ = rd.Next()
+			Expect(row.Line).To(Equal(3))
+
+			_, _, err := rd.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("reports lines that are not users", func() {
+			rd, _ := newReader(FormatJSONL, strings.NewReader(`{"email":"ford@example.com","role":`+"\n"+`{"mail":"x"}`+"\n"))
+
+			_, errs, err := rd.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errs).To(HaveLen(1))
+
+			_, errs, _ = rd.Next()
+			Expect(errs).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,205 @@
+package invitations
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+)
+
+type requestPayload struct {
+	Email     string `json:"email"`
+	MaxUses   int    `json:"maxUses"`
+	ExpiresIn string `json:"expiresIn"`
+}
+
+type RedemptionPayload struct {
+	Email      string    `json:"email"`
+	RedeemedAt time.Time `json:"redeemedAt"`
+}
+
+type InvitationPayload struct {
+	Code        string              `json:"code"`
+	Email       string              `json:"email,omitempty"`
+	MaxUses     int                 `json:"maxUses"`
+	Uses        int                 `json:"uses"`
+	ExpiresAt   time.Time           `json:"expiresAt"`
+	Redemptions []RedemptionPayload `json:"redemptions"`
+}
+
+type invitationsPayload struct {
+	Invitations []InvitationPayload `json:"invitations"`
+}
+
+func NewInvitationPayload(inv *Invitation) InvitationPayload {
+	rps := make([]RedemptionPayload, 0)
+
+	for _, r := range inv.Redemptions {
+		rps = append(rps, RedemptionPayload{
+			Email:      r.Email,
+			RedeemedAt: r.CreatedAt,
+		})
+	}
+
+	return InvitationPayload{
+		Code:        inv.Code,
+		Email:       inv.Email,
+		MaxUses:     inv.MaxUses,
+		Uses:        inv.Uses,
+		ExpiresAt:   inv.ExpiresAt,
+		Redemptions: rps,
+	}
+}
+
+// mayInvite reports whether the user may create another invitation: admins
+// always may, other users only while under the configured quota.
+func mayInvite(cfg *config.Config, u *users.User) (bool, error) {
+	if u.IsAdmin() {
+		return true, nil
+	}
+
+	quota := cfg.GetInt64("invitations.userQuota")
+
+	if quota <= 0 {
+		return false, nil
+	}
+
+	count, err := CountByCreator(cfg, u)
+
+	if err != nil {
+		return false, err
+	}
+
+	return count < quota, nil
+}
+
+func NewPostHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			data bytes.Buffer
+			qp   requestPayload
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewDecoder(r.Body).Decode(&qp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		ok, err := mayInvite(cfg, user)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !ok {
+			log.Printf("User '%s' may not create invitations", user.Email)
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		inv := &Invitation{
+			CreatedByID: user.ID,
+			Email:       qp.Email,
+			MaxUses:     qp.MaxUses,
+		}
+
+		if qp.ExpiresIn != "" {
+			d, err := time.ParseDuration(qp.ExpiresIn)
+
+			if err != nil || d <= 0 {
+				http.Error(w, "Invalid expiresIn", http.StatusBadRequest)
+				return
+			}
+
+			inv.ExpiresAt = time.Now().Add(d)
+		}
+
+		inv, err = Create(cfg, inv)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ip := NewInvitationPayload(inv)
+
+		err = json.NewEncoder(&data).Encode(&ip)
+
+		if err != nil {
+			log.Printf("Unable to generate payload: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+
+		_, err = w.Write(data.Bytes())
+
+		if err != nil {
+			log.Printf("Unable to write body: %e", err)
+		}
+	}
+}
+
+func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data bytes.Buffer
+
+		w.Header().Set("Content-Type", "application/json")
+
+		user, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		invs, err := FindByCreator(cfg, user)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ips := make([]InvitationPayload, 0)
+
+		for i := range *invs {
+			ips = append(ips, NewInvitationPayload(&(*invs)[i]))
+		}
+
+		err = json.NewEncoder(&data).Encode(&invitationsPayload{
+			Invitations: ips,
+		})
+
+		if err != nil {
+			log.Printf("Unable to generate payload: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = w.Write(data.Bytes())
+
+		if err != nil {
+			log.Printf("Unable to write body: %e", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package invitations_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("invitations/handlers.go", func() {
+	var (
+		rr     *httptest.ResponseRecorder
+		cfg    *config.Config
+		email  string
+		result map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		rr = httptest.NewRecorder()
+		email = faker.Email()
+
+		_, err := users.Create(cfg, &users.User{Email: email})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	serve := func(handler http.HandlerFunc, method, body string) {
+		req, err := http.NewRequest(method, "/invitations", bytes.NewBufferString(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		token, _ := security.NewTokenForSubject(cfg, email)
+		req.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		handler.ServeHTTP(rr, req)
+	}
+
+	Describe("NewPostHandler()", func() {
+		JustBeforeEach(func() {
+			serve(invitations.NewPostHandler(cfg), "POST", `{"maxUses": 3, "expiresIn": "1h"}`)
+		})
+
+		When("the user is an admin", func() {
+			BeforeEach(func() {
+				users.SetRole(cfg, email, users.RoleAdmin)
+			})
+
+			It("is created", func() {
+				Expect(rr.Code).To(Equal(http.StatusCreated))
+			})
+
+			It("returns the invitation", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+				Expect(result["code"]).NotTo(BeEmpty())
+				Expect(result["maxUses"]).To(BeNumerically("==", 3))
+			})
+		})
+
+		When("the user is not an admin", func() {
+			When("and users may not invite", func() {
+				It("is forbidden", func() {
+					Expect(rr.Code).To(Equal(http.StatusForbidden))
+				})
+			})
+
+			When("and the user is under quota", func() {
+				BeforeEach(func() {
+					cfg.Set("invitations.userQuota", 1)
+				})
+
+				It("is created", func() {
+					Expect(rr.Code).To(Equal(http.StatusCreated))
+				})
+			})
+
+			When("and the user has used up the quota", func() {
+				BeforeEach(func() {
+					cfg.Set("invitations.userQuota", 1)
+
+					serve(invitations.NewPostHandler(cfg), "POST", `{}`)
+					rr = httptest.NewRecorder()
+				})
+
+				It("is forbidden", func() {
+					Expect(rr.Code).To(Equal(http.StatusForbidden))
+				})
+			})
+		})
+	})
+
+	Describe("NewGetHandler()", func() {
+		BeforeEach(func() {
+			users.SetRole(cfg, email, users.RoleAdmin)
+			serve(invitations.NewPostHandler(cfg), "POST", `{}`)
+			rr = httptest.NewRecorder()
+		})
+
+		JustBeforeEach(func() {
+			serve(invitations.NewGetHandler(cfg), "GET", "")
+		})
+
+		It("is OK", func() {
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+
+		It("lists the invitations", func() {
+			json.Unmarshal(rr.Body.Bytes(), &result)
+			Expect(result["invitations"]).NotTo(BeEmpty())
+		})
+	})
+})
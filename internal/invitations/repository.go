@@ -0,0 +1,196 @@
+package invitations
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCode = errors.New("Invitation code is invalid, expired or used up")
+
+// Invitation lets people sign up, or, when it names an organization, join
+// that organization with the given role.
+type Invitation struct {
+	gorm.Model
+	Code           string `gorm:"uniqueIndex"`
+	CreatedByID    uint   `gorm:"index"`
+	Email          string
+	MaxUses        int
+	Uses           int
+	ExpiresAt      time.Time
+	OrganizationID uint `gorm:"index"`
+	Role           string
+	Redemptions    []Redemption
+}
+
+// Redemption records the user who signed up with an invitation.
+type Redemption struct {
+	gorm.Model
+	InvitationID uint `gorm:"index"`
+	UserID       uint
+	Email        string
+}
+
+func Create(cfg *config.Config, inv *Invitation) (*Invitation, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	code, err := security.RandomToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	inv.Code = code
+	inv.Email = strings.ToLower(inv.Email)
+
+	if inv.MaxUses <= 0 {
+		inv.MaxUses = cfg.GetInt("invitations.maxUses")
+	}
+
+	if inv.ExpiresAt.IsZero() {
+		inv.ExpiresAt = time.Now().Add(cfg.GetDuration("invitations.ttl"))
+	}
+
+	result := db.Create(inv)
+
+	if result.Error != nil {
+		log.Printf("Unable to create Invitation record: %e", result.Error)
+		return nil, result.Error
+	}
+
+	return inv, nil
+}
+
+// FindByCreator returns the invitations created by the user, or every
+// invitation when the user is an admin.
+func FindByCreator(cfg *config.Config, u *users.User) (*[]Invitation, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var invs []Invitation
+
+	query := db.Preload("Redemptions").Order("created_at DESC")
+
+	if !u.IsAdmin() {
+		query = query.Where("created_by_id = ?", u.ID)
+	}
+
+	result := query.Find(&invs)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &invs, nil
+}
+
+func CountByCreator(cfg *config.Config, u *users.User) (int64, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return 0, err
+	}
+
+	var count int64
+
+	result := db.Model(&Invitation{}).Where("created_by_id = ?", u.ID).Count(&count)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// Reserve uses up one of the invitation's uses on behalf of the given email,
+// failing if the code is unknown, expired, used up or bound to another email.
+// A reservation must be either recorded or released.
+func Reserve(cfg *config.Config, code, email string) (*Invitation, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	result := db.Model(&Invitation{}).
+		Where("code = ? AND uses < max_uses AND expires_at > ?", code, time.Now()).
+		Where("email = '' OR email = ?", strings.ToLower(email)).
+		Update("uses", gorm.Expr("uses + 1"))
+
+	if result.Error != nil {
+		log.Printf("Unable to reserve Invitation: %e", result.Error)
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidCode
+	}
+
+	var inv Invitation
+
+	result = db.Where("code = ?", code).Limit(1).Find(&inv)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &inv, nil
+}
+
+func Release(cfg *config.Config, inv *Invitation) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	result := db.Model(inv).Update("uses", gorm.Expr("uses - 1"))
+
+	if result.Error != nil {
+		log.Printf("Unable to release Invitation: %e", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+func Record(cfg *config.Config, inv *Invitation, u *users.User) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	result := db.Create(&Redemption{
+		InvitationID: inv.ID,
+		UserID:       u.ID,
+		Email:        u.Email,
+	})
+
+	if result.Error != nil {
+		log.Printf("Unable to record Redemption: %e", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package invitations
+
+import (
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("invitations/repository.go", func() {
+	var (
+		cfg     *config.Config
+		creator *users.User
+		inv     *Invitation
+		err     error
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+
+		creator, err = users.Create(cfg, &users.User{Email: faker.Email()})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Create()", func() {
+		BeforeEach(func() {
+			inv, err = Create(cfg, &Invitation{CreatedByID: creator.ID})
+		})
+
+		It("generates a code", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inv.Code).NotTo(BeEmpty())
+		})
+
+		It("applies the configured defaults", func() {
+			Expect(inv.MaxUses).To(Equal(cfg.GetInt("invitations.maxUses")))
+			Expect(inv.ExpiresAt).To(BeTemporally(">", time.Now()))
+		})
+	})
+
+	Describe("Reserve()", func() {
+		var email string
+
+		BeforeEach(func() {
+			email = faker.Email()
+			inv, err = Create(cfg, &Invitation{CreatedByID: creator.ID, MaxUses: 1})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("uses up the invitation", func() {
+			r, err := Reserve(cfg, inv.Code, email)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Uses).To(Equal(1))
+
+			_, err = Reserve(cfg, inv.Code, faker.Email())
+			Expect(err).To(MatchError(ErrInvalidCode))
+		})
+
+		It("can be released", func() {
+			r, _ := Reserve(cfg, inv.Code, email)
+			Expect(Release(cfg, r)).NotTo(HaveOccurred())
+
+			_, err = Reserve(cfg, inv.Code, email)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects unknown codes", func() {
+			_, err = Reserve(cfg, "nope", email)
+			Expect(err).To(MatchError(ErrInvalidCode))
+		})
+
+		When("the invitation has expired", func() {
+			BeforeEach(func() {
+				inv, _ = Create(cfg, &Invitation{
+					CreatedByID: creator.ID,
+					ExpiresAt:   time.Now().Add(-time.Minute),
+				})
+			})
+
+			It("is rejected", func() {
+				_, err = Reserve(cfg, inv.Code, email)
+				Expect(err).To(MatchError(ErrInvalidCode))
+			})
+		})
+
+		When("the invitation is bound to an email", func() {
+			BeforeEach(func() {
+				inv, _ = Create(cfg, &Invitation{CreatedByID: creator.ID, Email: email})
+			})
+
+			It("accepts that email", func() {
+				_, err = Reserve(cfg, inv.Code, email)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("rejects other emails", func() {
+				_, err = Reserve(cfg, inv.Code, faker.Email())
+				Expect(err).To(MatchError(ErrInvalidCode))
+			})
+		})
+	})
+
+	Describe("Record()", func() {
+		It("records who used the invitation", func() {
+			inv, _ = Create(cfg, &Invitation{CreatedByID: creator.ID})
+			u, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+
+			Expect(Record(cfg, inv, u)).NotTo(HaveOccurred())
+
+			invs, _ := FindByCreator(cfg, creator)
+			Expect(*invs).To(HaveLen(1))
+			Expect((*invs)[0].Redemptions).To(HaveLen(1))
+			Expect((*invs)[0].Redemptions[0].UserID).To(Equal(u.ID))
+		})
+	})
+})
@@ -0,0 +1,9 @@
+package internal
+
+import "strings"
+
+// EscapeLike escapes the wildcards in s, and the backslash that escapes them,
+// so that it matches literally in a pattern compared with LIKE ... ESCAPE '\'.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -2,12 +2,12 @@ package logins
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/adamstrickland/dapper-api/internal/config"
-	"github.com/adamstrickland/dapper-api/internal/security"
-	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
 )
 
 type requestPayload struct {
@@ -27,21 +27,27 @@ func NewPostHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
-		user, err := users.FindByEmail(cfg, qp.Email)
+		user, err := Authenticate(cfg, qp.Email, qp.Password)
+
+		if errors.Is(err, ErrInvalidPassword) {
+			http.Error(w, "UNAUTHORIZED", http.StatusUnauthorized)
+			return
+		}
 
 		if err != nil {
-			log.Printf("Unable to identify user: %e", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		if user.UnencryptedPassword != qp.Password {
-			log.Println("Unable to authenticate password!")
-			http.Error(w, "UNAUTHORIZED", http.StatusUnauthorized)
+		m, err := organizations.DefaultMembership(cfg, user.ID)
+
+		if err != nil {
+			log.Printf("Unable to find organizations: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		data, err := security.NewTokenPayload(cfg, user.Email)
+		data, err := organizations.NewTokenPayload(cfg, user, m)
 
 		if err != nil {
 			log.Printf("Unable to create session: %e", err)
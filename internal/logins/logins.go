@@ -0,0 +1,29 @@
+package logins
+
+import (
+	"errors"
+	"log"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+)
+
+var ErrInvalidPassword = errors.New("Invalid password")
+
+// Authenticate returns the user with the email when the password is theirs.
+// Emails with no user fail with users.ErrNotFound.
+func Authenticate(cfg *config.Config, email, password string) (*users.User, error) {
+	user, err := users.FindByEmail(cfg, email)
+
+	if err != nil {
+		log.Printf("Unable to identify user: %e", err)
+		return nil, err
+	}
+
+	if user.UnencryptedPassword != password {
+		log.Println("Unable to authenticate password!")
+		return nil, ErrInvalidPassword
+	}
+
+	return user, nil
+}
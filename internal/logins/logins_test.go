@@ -0,0 +1,40 @@
+package logins_test
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/logins"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logins/logins.go", func() {
+	var (
+		cfg *config.Config
+		u   *users.User
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email(), UnencryptedPassword: "p@ssw0rd"})
+	})
+
+	Describe("Authenticate()", func() {
+		It("returns the user whose password it is", func() {
+			found, err := logins.Authenticate(cfg, u.Email, "p@ssw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.ID).To(Equal(u.ID))
+		})
+
+		It("refuses other passwords", func() {
+			_, err := logins.Authenticate(cfg, u.Email, "hunter2")
+			Expect(err).To(MatchError(logins.ErrInvalidPassword))
+		})
+
+		It("refuses unknown emails", func() {
+			_, err := logins.Authenticate(cfg, faker.Email(), "p@ssw0rd")
+			Expect(err).To(MatchError(users.ErrNotFound))
+		})
+	})
+})
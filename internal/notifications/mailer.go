@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+)
+
+// Message is an email.  Its category, one of the preferences.Email*
+// categories, lets recipients opt out of it; messages without one are always
+// sent.
+type Message struct {
+	To       string
+	Subject  string
+	Body     string
+	Category string
+}
+
+type Mailer interface {
+	Send(m Message) error
+}
+
+// LogMailer writes messages to the log instead of delivering them.
+type LogMailer struct{}
+
+func (LogMailer) Send(m Message) error {
+	log.Printf("MAIL: to='%s' subject='%s'\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// MemoryMailer keeps every message it is sent; it is meant for tests.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (mm *MemoryMailer) Send(m Message) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.messages = append(mm.messages, m)
+
+	return nil
+}
+
+// To returns the messages sent to the given address, oldest first.
+func (mm *MemoryMailer) To(address string) []Message {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	ms := make([]Message, 0)
+
+	for _, m := range mm.messages {
+		if m.To == address {
+			ms = append(ms, m)
+		}
+	}
+
+	return ms
+}
+
+var Outbox = &MemoryMailer{}
+
+func NewMailer(cfg *config.Config) (Mailer, error) {
+	switch m := cfg.GetString("mailer"); m {
+	case "log":
+		return LogMailer{}, nil
+	case "memory":
+		return Outbox, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown mailer '%s'", m))
+	}
+}
+
+// Send delivers the message unless its recipient has opted out of its
+// category.
+func Send(cfg *config.Config, m Message) error {
+	if m.Category != "" {
+		ok, err := preferences.Allows(cfg, m.To, m.Category)
+
+		if err != nil {
+			log.Printf("Unable to check preferences of '%s': %e", m.To, err)
+			return err
+		}
+
+		if !ok {
+			log.Printf("Not sending %s message to '%s', who opted out", m.Category, m.To)
+			return nil
+		}
+	}
+
+	mailer, err := NewMailer(cfg)
+
+	if err != nil {
+		log.Printf("Unable to create mailer: %e", err)
+		return err
+	}
+
+	err = mailer.Send(m)
+
+	if err != nil {
+		log.Printf("Unable to send message to '%s': %e", m.To, err)
+		return err
+	}
+
+	return nil
+}
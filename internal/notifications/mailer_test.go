@@ -0,0 +1,96 @@
+package notifications
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("notifications/mailer.go", func() {
+	var (
+		cfg *config.Config
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+	})
+
+	Describe("Send()", func() {
+		var (
+			email string
+			err   error
+		)
+
+		BeforeEach(func() {
+			email = faker.Email()
+		})
+
+		JustBeforeEach(func() {
+			err = Send(cfg, Message{To: email, Subject: "Hello"})
+		})
+
+		When("the mailer is known", func() {
+			BeforeEach(func() {
+				cfg.Set("mailer", "memory")
+			})
+
+			It("does not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("delivers the message", func() {
+				Expect(Outbox.To(email)).To(HaveLen(1))
+				Expect(Outbox.To(email)[0].Subject).To(Equal("Hello"))
+			})
+		})
+
+		When("the recipient opted out of the message's category", func() {
+			var category string
+
+			BeforeEach(func() {
+				cfg.Set("mailer", "memory")
+
+				u, _ := users.Create(cfg, &users.User{Email: email})
+				preferences.Save(cfg, u.ID, `{"email": {"announcements": false}}`)
+			})
+
+			JustBeforeEach(func() {
+				err = Send(cfg, Message{To: email, Subject: "News", Category: category})
+			})
+
+			When("it is in that category", func() {
+				BeforeEach(func() {
+					category = preferences.EmailAnnouncements
+				})
+
+				It("is not delivered", func() {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(Outbox.To(email)).NotTo(ContainElement(HaveField("Subject", "News")))
+				})
+			})
+
+			When("it is in another category", func() {
+				BeforeEach(func() {
+					category = preferences.EmailInvitations
+				})
+
+				It("is delivered", func() {
+					Expect(Outbox.To(email)).To(ContainElement(HaveField("Subject", "News")))
+				})
+			})
+		})
+
+		When("the mailer is unknown", func() {
+			BeforeEach(func() {
+				cfg.Set("mailer", "carrierpigeon")
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -0,0 +1,334 @@
+package organizations
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/notifications"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/gorilla/mux"
+)
+
+type createPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type invitePayload struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+	Role  string `json:"role"`
+}
+
+type acceptPayload struct {
+	Code string `json:"code" validate:"required,max=64"`
+}
+
+type OrganizationPayload struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type organizationsPayload struct {
+	Organizations []OrganizationPayload `json:"organizations"`
+}
+
+func NewOrganizationPayload(m *Membership) OrganizationPayload {
+	return OrganizationPayload{
+		ID:        m.Organization.PublicID,
+		Name:      m.Organization.Name,
+		Role:      m.Role,
+		CreatedAt: m.Organization.CreatedAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var data bytes.Buffer
+
+	err := json.NewEncoder(&data).Encode(v)
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+
+	_, err = w.Write(data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+	}
+}
+
+// currentMembership returns the current user and their membership of the
+// organization named in the route; users who are not members are told it
+// does not exist.
+func currentMembership(cfg *config.Config, w http.ResponseWriter, r *http.Request) (*users.User, *Membership, bool) {
+	cu, err := users.CurrentUser(cfg, r)
+
+	if err != nil {
+		log.Printf("Unable to identify user: %e", err)
+		http.Error(w, "", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	m, err := FindMembership(cfg, mux.Vars(r)["id"], cu.ID)
+
+	if errors.Is(err, ErrNotMember) {
+		http.Error(w, "", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return cu, m, true
+}
+
+func NewPostHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var qp createPayload
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewDecoder(r.Body).Decode(&qp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if errs := validation.Validate(&qp); errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		cu, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		org, err := Create(cfg, &Organization{Name: qp.Name}, cu)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, NewOrganizationPayload(&Membership{
+			Role:         RoleOwner,
+			Organization: *org,
+		}))
+	}
+}
+
+// NewGetHandler lists the organizations the current user belongs to.
+func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		cu, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		ms, err := ForUser(cfg, cu.ID)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ops := make([]OrganizationPayload, 0, len(*ms))
+
+		for i := range *ms {
+			ops = append(ops, NewOrganizationPayload(&(*ms)[i]))
+		}
+
+		writeJSON(w, http.StatusOK, &organizationsPayload{Organizations: ops})
+	}
+}
+
+// NewInviteHandler lets owners and admins of an organization invite someone,
+// by email, to join it.
+func NewInviteHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var qp invitePayload
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewDecoder(r.Body).Decode(&qp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if qp.Role == "" {
+			qp.Role = RoleMember
+		}
+
+		errs := validation.Validate(&qp)
+
+		if qp.Role != RoleMember && qp.Role != RoleAdmin {
+			errs = append(errs, validation.FieldError{
+				Field:   "role",
+				Code:    validation.CodeInvalid,
+				Message: fmt.Sprintf("role must be %s or %s", RoleMember, RoleAdmin),
+			})
+		}
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		cu, m, ok := currentMembership(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		if !m.CanInvite() {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		inv, err := invitations.Create(cfg, &invitations.Invitation{
+			CreatedByID:    cu.ID,
+			Email:          qp.Email,
+			MaxUses:        1,
+			OrganizationID: m.OrganizationID,
+			Role:           qp.Role,
+		})
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = notifications.Send(cfg, notifications.Message{
+			To:       qp.Email,
+			Category: preferences.EmailInvitations,
+			Subject:  fmt.Sprintf("You have been invited to join %s", m.Organization.Name),
+			Body: fmt.Sprintf(
+				"Join %s by sending the code below to /organizations/invitations/accept before %s.\n\n%s",
+				m.Organization.Name,
+				inv.ExpiresAt.Format(time.RFC1123),
+				inv.Code,
+			),
+		})
+
+		if err != nil {
+			log.Printf("Unable to send invitation to '%s': %e", inv.Email, err)
+		}
+
+		writeJSON(w, http.StatusCreated, invitations.NewInvitationPayload(inv))
+	}
+}
+
+// NewAcceptHandler makes the current user a member of the organization they
+// were invited to.
+func NewAcceptHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var qp acceptPayload
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewDecoder(r.Body).Decode(&qp)
+
+		if err != nil {
+			log.Printf("Unable to unmarshal payload: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if errs := validation.Validate(&qp); errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		cu, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		inv, err := invitations.Reserve(cfg, qp.Code, cu.Email)
+
+		if err == nil && inv.OrganizationID == 0 {
+			invitations.Release(cfg, inv)
+			err = invitations.ErrInvalidCode
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		m, err := Join(cfg, inv, cu)
+
+		if errors.Is(err, ErrAlreadyMember) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, NewOrganizationPayload(m))
+	}
+}
+
+// NewSwitchHandler issues a token acting in the organization, which the
+// current user must belong to.
+func NewSwitchHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		cu, m, ok := currentMembership(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		data, err := NewTokenPayload(cfg, cu, m)
+
+		if err != nil {
+			log.Printf("Unable to create session: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = w.Write(data)
+
+		if err != nil {
+			log.Printf("Unable to write body: %e", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package organizations_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/notifications"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("organizations/handlers.go", func() {
+	var (
+		rr           *httptest.ResponseRecorder
+		cfg          *config.Config
+		owner, other *users.User
+		org          *organizations.Organization
+		result       map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("mailer", "memory")
+		rr = httptest.NewRecorder()
+		result = nil
+
+		owner, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		other, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		org, _ = organizations.Create(cfg, &organizations.Organization{Name: "Heart of Gold"}, owner)
+	})
+
+	serve := func(handler http.HandlerFunc, u *users.User, body string) {
+		req, err := http.NewRequest("POST", "/organizations", bytes.NewBufferString(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		req = mux.SetURLVars(req, map[string]string{"id": org.PublicID})
+
+		token, _ := security.NewTokenForSubject(cfg, u.PublicID)
+		req.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		handler.ServeHTTP(rr, req)
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	Describe("NewPostHandler()", func() {
+		It("creates an organization owned by the user", func() {
+			serve(organizations.NewPostHandler(cfg), other, `{"name": "Bistromath"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			Expect(result["name"]).To(Equal("Bistromath"))
+			Expect(result["role"]).To(Equal(organizations.RoleOwner))
+		})
+
+		It("requires a name", func() {
+			serve(organizations.NewPostHandler(cfg), other, `{}`)
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+	})
+
+	Describe("NewGetHandler()", func() {
+		It("lists the user's organizations", func() {
+			serve(organizations.NewGetHandler(cfg), owner, "")
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["organizations"]).To(HaveLen(1))
+		})
+	})
+
+	Describe("NewInviteHandler()", func() {
+		var invitee string
+
+		BeforeEach(func() {
+			invitee = faker.Email()
+		})
+
+		It("emails an invitation", func() {
+			serve(organizations.NewInviteHandler(cfg), owner, `{"email": "`+invitee+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			Expect(notifications.Outbox.To(invitee)).To(HaveLen(1))
+		})
+
+		It("refuses unknown roles", func() {
+			serve(organizations.NewInviteHandler(cfg), owner, `{"email": "`+invitee+`", "role": "owner"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+
+		It("forbids plain members", func() {
+			organizations.AddMember(cfg, org.ID, other.ID, organizations.RoleMember)
+			serve(organizations.NewInviteHandler(cfg), other, `{"email": "`+invitee+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("hides the organization from non-members", func() {
+			serve(organizations.NewInviteHandler(cfg), other, `{"email": "`+invitee+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("NewAcceptHandler()", func() {
+		var inv *invitations.Invitation
+
+		BeforeEach(func() {
+			inv, _ = invitations.Create(cfg, &invitations.Invitation{
+				CreatedByID:    owner.ID,
+				Email:          other.Email,
+				MaxUses:        1,
+				OrganizationID: org.ID,
+				Role:           organizations.RoleMember,
+			})
+		})
+
+		It("makes the user a member", func() {
+			serve(organizations.NewAcceptHandler(cfg), other, `{"code": "`+inv.Code+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["id"]).To(Equal(org.PublicID))
+
+			_, err := organizations.FindMembership(cfg, org.PublicID, other.ID)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("refuses invitations for someone else", func() {
+			serve(organizations.NewAcceptHandler(cfg), owner, `{"code": "`+inv.Code+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("NewSwitchHandler()", func() {
+		It("issues a token acting in the organization", func() {
+			serve(organizations.NewSwitchHandler(cfg), owner, "")
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set(cfg.GetString("tokenHeader"), result["token"].(string))
+
+			claimed, _ := security.RequestOrganization(cfg, req)
+			Expect(claimed).To(Equal(org.PublicID))
+		})
+
+		It("claims the user's groups there when group claims are on", func() {
+			cfg.Set("tokens.groupClaims", true)
+			g, _ := groups.Create(cfg, org.ID, &groups.Group{Name: "Crew"}, owner)
+
+			serve(organizations.NewSwitchHandler(cfg), owner, "")
+
+			claims := jwt.MapClaims{}
+			jwt.ParseWithClaims(result["token"].(string), claims, func(_ *jwt.Token) (interface{}, error) {
+				return []byte(cfg.GetString("secret")), nil
+			})
+
+			Expect(claims["groups"]).To(ConsistOf(g.PublicID))
+		})
+
+		It("hides the organization from non-members", func() {
+			serve(organizations.NewSwitchHandler(cfg), other, "")
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package organizations
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+)
+
+func init() {
+	users.RegisterInclude("organizations", nil, includeOrganizations)
+}
+
+// includeOrganizations loads the organizations each user belongs to, of which
+// a request may only see the tenant it acts in.
+func includeOrganizations(cfg *config.Config, tenant uint, us []users.User) (map[uint]interface{}, error) {
+	ids := make([]uint, 0, len(us))
+
+	for _, u := range us {
+		ids = append(ids, u.ID)
+	}
+
+	byUser, err := ForUsers(cfg, tenant, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := make(map[uint]interface{}, len(us))
+
+	for _, u := range us {
+		ops := []OrganizationPayload{}
+
+		for i := range byUser[u.ID] {
+			ops = append(ops, NewOrganizationPayload(&byUser[u.ID][i]))
+		}
+
+		orgs[u.ID] = ops
+	}
+
+	return orgs, nil
+}
@@ -0,0 +1,44 @@
+package organizations
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("organizations/includes.go", func() {
+	var (
+		cfg *config.Config
+		u   *users.User
+		org *Organization
+		p   users.Projection
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		org, _ = Create(cfg, &Organization{Name: "Heart of Gold"}, u)
+		Create(cfg, &Organization{Name: "Vogon Constructor Fleet"}, u)
+
+		p = users.Projection{Fields: []string{"id"}, Include: []string{"organizations"}}
+	})
+
+	It("includes only the tenant's organization", func() {
+		out, err := p.Render(cfg, org.ID, []users.User{*u})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out[0]).To(HaveKeyWithValue("organizations", ConsistOf(
+			And(HaveField("ID", org.PublicID), HaveField("Role", RoleOwner)),
+		)))
+	})
+
+	It("includes nothing outside a tenant", func() {
+		out, err := p.Render(cfg, 0, []users.User{*u})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out[0]).To(HaveKeyWithValue("organizations", BeEmpty()))
+	})
+})
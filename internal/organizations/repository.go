@@ -0,0 +1,276 @@
+package organizations
+
+import (
+	"errors"
+	"log"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+var (
+	ErrNotFound      = errors.New("Organization not found")
+	ErrNotMember     = errors.New("User is not a member of the organization")
+	ErrAlreadyMember = errors.New("User is already a member of the organization")
+)
+
+// Organization is a tenant: its members only see each other.
+type Organization struct {
+	gorm.Model
+	PublicID    string `gorm:"uniqueIndex"`
+	Name        string
+	Memberships []Membership
+}
+
+// Membership gives a user a role within an organization.
+type Membership struct {
+	gorm.Model
+	OrganizationID uint `gorm:"uniqueIndex:idx_memberships_organization_user"`
+	UserID         uint `gorm:"uniqueIndex:idx_memberships_organization_user;index"`
+	Role           string
+	Organization   Organization
+}
+
+// CanInvite reports whether the member may invite others to the organization.
+func (m *Membership) CanInvite() bool {
+	return m.Role == RoleOwner || m.Role == RoleAdmin
+}
+
+// Create records a new organization with the user as its owner.
+func Create(cfg *config.Config, org *Organization, owner *users.User) (*Organization, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	org.PublicID = users.NewPublicID()
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&Membership{
+			OrganizationID: org.ID,
+			UserID:         owner.ID,
+			Role:           RoleOwner,
+		}).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to create Organization record: %e", err)
+		return nil, err
+	}
+
+	return org, nil
+}
+
+func FindByPublicID(cfg *config.Config, id string) (*Organization, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var org Organization
+
+	result := db.Where("public_id = ?", id).Limit(1).Find(&org)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &org, nil
+}
+
+// AddMember makes the user a member of the organization.
+func AddMember(cfg *config.Config, orgID, userID uint, role string) (*Membership, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var count int64
+
+	result := db.Model(&Membership{}).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Count(&count)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if count > 0 {
+		return nil, ErrAlreadyMember
+	}
+
+	m := &Membership{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Role:           role,
+	}
+
+	result = db.Create(m)
+
+	if result.Error != nil {
+		log.Printf("Unable to create Membership record: %e", result.Error)
+		return nil, result.Error
+	}
+
+	return m, nil
+}
+
+// Join makes the user a member of the organization a reserved invitation is
+// for, recording the redemption or, failing that, releasing the reservation.
+func Join(cfg *config.Config, inv *invitations.Invitation, u *users.User) (*Membership, error) {
+	m, err := AddMember(cfg, inv.OrganizationID, u.ID, inv.Role)
+
+	if err != nil {
+		invitations.Release(cfg, inv)
+		return nil, err
+	}
+
+	if err := invitations.Record(cfg, inv, u); err != nil {
+		return nil, err
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	if err := db.First(&m.Organization, inv.OrganizationID).Error; err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// FindMembership returns the user's membership of the organization with the
+// given public ID, failing with ErrNotMember when there is none.
+func FindMembership(cfg *config.Config, orgID string, userID uint) (*Membership, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var m Membership
+
+	result := db.Joins("Organization").
+		Where("Organization.public_id = ? AND memberships.user_id = ?", orgID, userID).
+		Limit(1).
+		Find(&m)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrNotMember
+	}
+
+	return &m, nil
+}
+
+// ForUser returns the user's memberships, oldest first.
+func ForUser(cfg *config.Config, userID uint) (*[]Membership, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var ms []Membership
+
+	result := db.Joins("Organization").
+		Where("memberships.user_id = ?", userID).
+		Order("memberships.created_at ASC, memberships.id ASC").
+		Find(&ms)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &ms, nil
+}
+
+// ForUsers returns the tenant's memberships of each of the users with the
+// given IDs.  Users who are not members are left out.
+func ForUsers(cfg *config.Config, tenant uint, userIDs []uint) (map[uint][]Membership, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var ms []Membership
+
+	result := db.Joins("Organization").
+		Where("memberships.user_id IN ? AND memberships.organization_id = ?", userIDs, tenant).
+		Find(&ms)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	byUser := make(map[uint][]Membership)
+
+	for _, m := range ms {
+		byUser[m.UserID] = append(byUser[m.UserID], m)
+	}
+
+	return byUser, nil
+}
+
+// DefaultMembership returns the membership a user acts in when they log in:
+// that of the first organization they joined, or nil.
+func DefaultMembership(cfg *config.Config, userID uint) (*Membership, error) {
+	ms, err := ForUser(cfg, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*ms) == 0 {
+		return nil, nil
+	}
+
+	return &(*ms)[0], nil
+}
+
+// ResolveTenant returns the membership a token claiming the organization with
+// the given public ID acts in for the user, failing with ErrNotMember when
+// they do not belong to it.  A token claiming none acts in the user's default
+// membership, so that members cannot leave their organization's scope by
+// dropping the claim; it is nil only for users who belong to none.
+func ResolveTenant(cfg *config.Config, org string, userID uint) (*Membership, error) {
+	if org == "" {
+		return DefaultMembership(cfg, userID)
+	}
+
+	return FindMembership(cfg, org, userID)
+}
@@ -0,0 +1,123 @@
+package organizations
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("organizations/repository.go", func() {
+	var (
+		cfg          *config.Config
+		owner, other *users.User
+		org          *Organization
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+
+		owner, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		other, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+
+		var err error
+		org, err = Create(cfg, &Organization{Name: "Heart of Gold"}, owner)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Create()", func() {
+		It("assigns a public ID", func() {
+			Expect(users.IsPublicID(org.PublicID)).To(BeTrue())
+		})
+
+		It("makes the creator its owner", func() {
+			m, err := FindMembership(cfg, org.PublicID, owner.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.Role).To(Equal(RoleOwner))
+			Expect(m.Organization.Name).To(Equal("Heart of Gold"))
+		})
+	})
+
+	Describe("AddMember()", func() {
+		It("adds the user", func() {
+			_, err := AddMember(cfg, org.ID, other.ID, RoleMember)
+			Expect(err).NotTo(HaveOccurred())
+
+			ms, _ := ForUser(cfg, other.ID)
+			Expect(*ms).To(HaveLen(1))
+		})
+
+		It("refuses existing members", func() {
+			_, err := AddMember(cfg, org.ID, owner.ID, RoleMember)
+			Expect(err).To(Equal(ErrAlreadyMember))
+		})
+	})
+
+	Describe("FindMembership()", func() {
+		It("fails for non-members", func() {
+			_, err := FindMembership(cfg, org.PublicID, other.ID)
+			Expect(err).To(Equal(ErrNotMember))
+		})
+	})
+
+	Describe("DefaultMembership()", func() {
+		It("is of the first organization joined", func() {
+			Create(cfg, &Organization{Name: "Bistromath"}, owner)
+
+			m, _ := DefaultMembership(cfg, owner.ID)
+			Expect(m.Organization.PublicID).To(Equal(org.PublicID))
+		})
+
+		It("is nil for users in no organization", func() {
+			m, _ := DefaultMembership(cfg, other.ID)
+			Expect(m).To(BeNil())
+		})
+	})
+
+	Describe("ResolveTenant()", func() {
+		It("is the membership of the claimed organization", func() {
+			m, err := ResolveTenant(cfg, org.PublicID, owner.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.OrganizationID).To(Equal(org.ID))
+		})
+
+		It("refuses organizations the user does not belong to", func() {
+			_, err := ResolveTenant(cfg, org.PublicID, other.ID)
+			Expect(err).To(Equal(ErrNotMember))
+		})
+
+		It("falls back to the default membership when none is claimed", func() {
+			m, _ := ResolveTenant(cfg, "", owner.ID)
+			Expect(m.OrganizationID).To(Equal(org.ID))
+		})
+
+		It("is nil when none is claimed by a user in no organization", func() {
+			m, err := ResolveTenant(cfg, "", other.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m).To(BeNil())
+		})
+	})
+
+	Describe("Join()", func() {
+		var inv *invitations.Invitation
+
+		BeforeEach(func() {
+			inv, _ = invitations.Create(cfg, &invitations.Invitation{
+				CreatedByID:    owner.ID,
+				Email:          other.Email,
+				OrganizationID: org.ID,
+				Role:           RoleAdmin,
+			})
+			inv, _ = invitations.Reserve(cfg, inv.Code, other.Email)
+		})
+
+		It("adds the user with the invitation's role", func() {
+			m, err := Join(cfg, inv, other)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.Role).To(Equal(RoleAdmin))
+			Expect(m.Organization.PublicID).To(Equal(org.PublicID))
+		})
+	})
+})
@@ -0,0 +1,50 @@
+package organizations
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+)
+
+// claims returns the organization the membership acts in, or none when it is
+// nil, and the user's groups there when group claims are turned on.
+func claims(cfg *config.Config, u *users.User, m *Membership) (string, []string, error) {
+	org, tenant := "", uint(0)
+
+	if m != nil {
+		org, tenant = m.Organization.PublicID, m.OrganizationID
+	}
+
+	gs, err := groups.Claims(cfg, tenant, u.ID)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return org, gs, nil
+}
+
+// NewToken issues the user a token acting in the organization of the
+// membership, or in none when it is nil, claiming their groups there when
+// group claims are turned on.
+func NewToken(cfg *config.Config, u *users.User, m *Membership) (string, error) {
+	org, gs, err := claims(cfg, u, m)
+
+	if err != nil {
+		return "", err
+	}
+
+	return security.NewTokenForSubjectInOrganization(cfg, u.PublicID, org, gs...)
+}
+
+// NewTokenPayload is NewToken, encoded as a token payload.
+func NewTokenPayload(cfg *config.Config, u *users.User, m *Membership) ([]byte, error) {
+	org, gs, err := claims(cfg, u, m)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return security.NewTokenPayloadInOrganization(cfg, u.PublicID, org, gs...)
+}
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"log"
+	"sync"
 
 	"github.com/adamstrickland/dapper-api/internal/config"
 	"github.com/xo/dburl"
@@ -13,6 +14,18 @@ type Conn struct {
 	*gorm.DB
 }
 
+var (
+	preparers []func(db *gorm.DB) error
+	prepared  sync.Once
+)
+
+// OnConnect registers a function bringing the database in line with this
+// build of the application, such as its search index with the SQLite linked
+// in.  The functions run once per process, on the first connection opened.
+func OnConnect(fn func(db *gorm.DB) error) {
+	preparers = append(preparers, fn)
+}
+
 func NewConnection(cfg *config.Config) (*Conn, error) {
 	url, err := dburl.Parse(cfg.GetString("databaseUrl"))
 
@@ -30,6 +43,14 @@ func NewConnection(cfg *config.Config) (*Conn, error) {
 		return nil, err
 	}
 
+	prepared.Do(func() {
+		for _, fn := range preparers {
+			if err := fn(db); err != nil {
+				log.Printf("Unable to prepare database: %e", err)
+			}
+		}
+	})
+
 	conn := &Conn{
 		DB: db,
 	}
@@ -0,0 +1,93 @@
+package preferences
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/adamstrickland/dapper-api/internal/caching"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+)
+
+func writePreferences(cfg *config.Config, w http.ResponseWriter, r *http.Request, u *users.User) {
+	var data bytes.Buffer
+
+	p, updatedAt, err := For(cfg, u.ID)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(&data).Encode(p)
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, err = caching.Write(w, r, caching.WeakETag(data.Bytes()), updatedAt, data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+	}
+}
+
+func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		cu, err := users.CurrentUser(cfg, r, "id")
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		writePreferences(cfg, w, r, cu)
+	}
+}
+
+// NewPutHandler replaces the preferences the current user has set; those
+// left out of the document return to their defaults.
+func NewPutHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		body, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			log.Printf("Unable to read preferences: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cu, err := users.CurrentUser(cfg, r, "id")
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		doc, errs := Parse(cfg, body)
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		if err := Save(cfg, cu.ID, doc); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writePreferences(cfg, w, r, cu)
+	}
+}
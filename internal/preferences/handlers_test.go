@@ -0,0 +1,71 @@
+package preferences_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("preferences/handlers.go", func() {
+	var (
+		rr     *httptest.ResponseRecorder
+		cfg    *config.Config
+		u      *users.User
+		result map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		rr = httptest.NewRecorder()
+		result = nil
+
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+	})
+
+	serve := func(handler http.HandlerFunc, method, body string) {
+		req, err := http.NewRequest(method, "/users/me/preferences", bytes.NewBufferString(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		token, _ := security.NewTokenForSubject(cfg, u.PublicID)
+		req.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		handler.ServeHTTP(rr, req)
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	Describe("NewGetHandler()", func() {
+		It("returns the defaults", func() {
+			serve(preferences.NewGetHandler(cfg), "GET", "")
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["theme"]).To(Equal("system"))
+			Expect(result["email"]).To(HaveKeyWithValue("invitations", true))
+		})
+	})
+
+	Describe("NewPutHandler()", func() {
+		It("saves the preferences", func() {
+			serve(preferences.NewPutHandler(cfg), "PUT", `{"theme": "dark", "email": {"announcements": false}}`)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["theme"]).To(Equal("dark"))
+			Expect(result["email"]).To(HaveKeyWithValue("announcements", false))
+			Expect(result["email"]).To(HaveKeyWithValue("invitations", true))
+		})
+
+		It("rejects invalid preferences", func() {
+			serve(preferences.NewPutHandler(cfg), "PUT", `{"timezone": "Nowhere"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+	})
+})
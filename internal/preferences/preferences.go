@@ -0,0 +1,150 @@
+package preferences
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"golang.org/x/text/language"
+)
+
+// Categories of email a user may opt out of.  Messages without a category,
+// such as those about the security of an account, are always sent.
+const (
+	EmailInvitations   = "invitations"
+	EmailAnnouncements = "announcements"
+)
+
+// Preferences are a user's settings, shared by every client.
+type Preferences struct {
+	Theme    string           `json:"theme"`
+	Locale   string           `json:"locale"`
+	Timezone string           `json:"timezone"`
+	Email    EmailPreferences `json:"email"`
+}
+
+// EmailPreferences say which categories of email the user wants.
+type EmailPreferences struct {
+	Invitations   bool `json:"invitations"`
+	Announcements bool `json:"announcements"`
+}
+
+// Allows reports whether the user wants email of the given category.
+func (ep EmailPreferences) Allows(category string) bool {
+	switch category {
+	case EmailInvitations:
+		return ep.Invitations
+	case EmailAnnouncements:
+		return ep.Announcements
+	default:
+		return true
+	}
+}
+
+// Defaults returns the preferences of users who have set none.
+func Defaults(cfg *config.Config) Preferences {
+	return Preferences{
+		Theme:    cfg.GetString("preferences.defaults.theme"),
+		Locale:   cfg.GetString("preferences.defaults.locale"),
+		Timezone: cfg.GetString("preferences.defaults.timezone"),
+		Email: EmailPreferences{
+			Invitations:   cfg.GetBool("preferences.defaults.email.invitations"),
+			Announcements: cfg.GetBool("preferences.defaults.email.announcements"),
+		},
+	}
+}
+
+// overlay applies a stored document of preferences onto others; the fields
+// it leaves out keep their value.
+func overlay(p *Preferences, doc string) error {
+	if doc == "" {
+		return nil
+	}
+
+	return json.Unmarshal([]byte(doc), p)
+}
+
+func invalid(field, message string) validation.FieldError {
+	return validation.FieldError{Field: field, Code: validation.CodeInvalid, Message: message}
+}
+
+// Parse checks a document of preferences written by a client, returning it
+// compacted for storage.  Fields it leaves out fall back to the defaults.
+func Parse(cfg *config.Config, doc []byte) (string, validation.Errors) {
+	doc = bytes.TrimSpace(doc)
+
+	if len(doc) == 0 || doc[0] != '{' {
+		return "", validation.Errors{invalid("", "preferences must be an object")}
+	}
+
+	p := Defaults(cfg)
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&p); err != nil {
+		return "", validation.Errors{invalid(decodeField(err), err.Error())}
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return "", validation.Errors{invalid("", "preferences must be a single object")}
+	}
+
+	if errs := check(cfg, &p); errs != nil {
+		return "", errs
+	}
+
+	var buf bytes.Buffer
+
+	if err := json.Compact(&buf, doc); err != nil {
+		return "", validation.Errors{invalid("", err.Error())}
+	}
+
+	return buf.String(), nil
+}
+
+// decodeField names the field a decoding error is about, where it can.
+func decodeField(err error) string {
+	var te *json.UnmarshalTypeError
+
+	if errors.As(err, &te) {
+		return te.Field
+	}
+
+	if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+		return strings.Trim(strings.TrimPrefix(msg, "json: unknown field "), `"`)
+	}
+
+	return ""
+}
+
+func check(cfg *config.Config, p *Preferences) validation.Errors {
+	var errs validation.Errors
+
+	themes := cfg.GetStringSlice("preferences.themes")
+	known := false
+
+	for _, t := range themes {
+		known = known || t == p.Theme
+	}
+
+	if !known {
+		errs = append(errs, invalid("theme", fmt.Sprintf("theme must be one of %s", strings.Join(themes, ", "))))
+	}
+
+	if _, err := language.Parse(p.Locale); err != nil {
+		errs = append(errs, invalid("locale", "locale must be a BCP 47 language tag"))
+	}
+
+	if _, err := time.LoadLocation(p.Timezone); err != nil || p.Timezone == "" || p.Timezone == "Local" {
+		errs = append(errs, invalid("timezone", "timezone must be an IANA time zone name"))
+	}
+
+	return errs
+}
@@ -0,0 +1,68 @@
+package preferences
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("preferences/preferences.go", func() {
+	var cfg *config.Config
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+	})
+
+	Describe("Defaults()", func() {
+		It("reads the configured defaults", func() {
+			cfg.Set("preferences.defaults.theme", "dark")
+
+			p := Defaults(cfg)
+			Expect(p.Theme).To(Equal("dark"))
+			Expect(p.Email.Invitations).To(BeTrue())
+		})
+	})
+
+	Describe("Parse()", func() {
+		It("compacts valid documents", func() {
+			doc, errs := Parse(cfg, []byte(`{ "theme": "light", "timezone": "Europe/London" }`))
+			Expect(errs).To(BeNil())
+			Expect(doc).To(Equal(`{"theme":"light","timezone":"Europe/London"}`))
+		})
+
+		It("rejects unknown fields", func() {
+			_, errs := Parse(cfg, []byte(`{"colour": "red"}`))
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("colour"))
+		})
+
+		It("rejects values of the wrong type", func() {
+			_, errs := Parse(cfg, []byte(`{"email": {"invitations": "no"}}`))
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("email.invitations"))
+		})
+
+		It("reports every invalid setting", func() {
+			_, errs := Parse(cfg, []byte(`{"theme": "neon", "locale": "not a locale", "timezone": "Mars/Olympus_Mons"}`))
+			Expect(errs).To(HaveLen(3))
+		})
+
+		It("rejects anything but an object", func() {
+			_, errs := Parse(cfg, []byte(`["dark"]`))
+			Expect(errs).To(HaveLen(1))
+		})
+	})
+
+	Describe("EmailPreferences.Allows()", func() {
+		It("follows the setting of the category", func() {
+			ep := EmailPreferences{Invitations: false, Announcements: true}
+
+			Expect(ep.Allows(EmailInvitations)).To(BeFalse())
+			Expect(ep.Allows(EmailAnnouncements)).To(BeTrue())
+		})
+
+		It("allows messages of no category", func() {
+			Expect(EmailPreferences{}.Allows("")).To(BeTrue())
+		})
+	})
+})
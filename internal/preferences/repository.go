@@ -0,0 +1,141 @@
+package preferences
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"gorm.io/gorm"
+)
+
+// Preference stores the preferences a user has set, as a JSON object of the
+// fields they gave a value for.
+type Preference struct {
+	gorm.Model
+	UserID   uint   `gorm:"uniqueIndex"`
+	Document string `gorm:"type:text;not null;default:'{}'"`
+}
+
+// For returns the user's preferences, the defaults overlaid with whatever they
+// have set, and when they last set them.
+func For(cfg *config.Config, userID uint) (*Preferences, time.Time, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, time.Time{}, err
+	}
+
+	var stored Preference
+
+	result := db.Where("user_id = ?", userID).Limit(1).Find(&stored)
+
+	if result.Error != nil {
+		return nil, time.Time{}, result.Error
+	}
+
+	p := Defaults(cfg)
+
+	if err := overlay(&p, stored.Document); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return &p, stored.UpdatedAt, nil
+}
+
+// ForUsers returns the preferences of each of the users with the given IDs.
+func ForUsers(cfg *config.Config, userIDs []uint) (map[uint]*Preferences, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var stored []Preference
+
+	result := db.Where("user_id IN ?", userIDs).Find(&stored)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	docs := make(map[uint]string, len(stored))
+
+	for _, s := range stored {
+		docs[s.UserID] = s.Document
+	}
+
+	byUser := make(map[uint]*Preferences, len(userIDs))
+
+	for _, id := range userIDs {
+		p := Defaults(cfg)
+
+		if err := overlay(&p, docs[id]); err != nil {
+			return nil, err
+		}
+
+		byUser[id] = &p
+	}
+
+	return byUser, nil
+}
+
+// Save replaces the preferences the user has set with a document checked by
+// Parse.
+func Save(cfg *config.Config, userID uint, doc string) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var stored Preference
+
+		query := tx.Where("user_id = ?", userID).Limit(1).Find(&stored)
+
+		if query.Error != nil {
+			return query.Error
+		}
+
+		stored.UserID = userID
+		stored.Document = doc
+
+		return tx.Save(&stored).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to save Preference record: %e", err)
+		return err
+	}
+
+	return nil
+}
+
+// Allows reports whether the user with the given email wants email of the
+// category.  Addresses with no user, such as those of people invited to sign
+// up, get the defaults.
+func Allows(cfg *config.Config, email, category string) (bool, error) {
+	u, err := users.FindByEmail(cfg, email, "id")
+
+	if errors.Is(err, users.ErrNotFound) {
+		return Defaults(cfg).Email.Allows(category), nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	p, _, err := For(cfg, u.ID)
+
+	if err != nil {
+		return false, err
+	}
+
+	return p.Email.Allows(category), nil
+}
@@ -0,0 +1,78 @@
+package preferences
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("preferences/repository.go", func() {
+	var (
+		cfg *config.Config
+		u   *users.User
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+	})
+
+	Describe("For()", func() {
+		It("returns the defaults for users who set none", func() {
+			p, _, err := For(cfg, u.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*p).To(Equal(Defaults(cfg)))
+		})
+
+		It("overlays what the user set on the defaults", func() {
+			Expect(Save(cfg, u.ID, `{"email":{"invitations":false}}`)).To(Succeed())
+
+			p, _, _ := For(cfg, u.ID)
+			Expect(p.Email.Invitations).To(BeFalse())
+			Expect(p.Email.Announcements).To(BeTrue())
+			Expect(p.Theme).To(Equal(Defaults(cfg).Theme))
+		})
+	})
+
+	Describe("Save()", func() {
+		It("replaces what was set before", func() {
+			Save(cfg, u.ID, `{"theme":"dark"}`)
+			Save(cfg, u.ID, `{"locale":"fr"}`)
+
+			p, _, _ := For(cfg, u.ID)
+			Expect(p.Theme).To(Equal(Defaults(cfg).Theme))
+			Expect(p.Locale).To(Equal("fr"))
+		})
+	})
+
+	Describe("Allows()", func() {
+		It("follows the user's preferences", func() {
+			Save(cfg, u.ID, `{"email":{"announcements":false}}`)
+
+			ok, err := Allows(cfg, u.Email, EmailAnnouncements)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("uses the defaults for unknown addresses", func() {
+			cfg.Set("preferences.defaults.email.invitations", false)
+
+			ok, _ := Allows(cfg, faker.Email(), EmailInvitations)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("ForUsers()", func() {
+		It("returns each user's preferences, the defaults for those who set none", func() {
+			other, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+			Save(cfg, u.ID, `{"theme":"dark"}`)
+
+			byUser, err := ForUsers(cfg, []uint{u.ID, other.ID})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(byUser[u.ID].Theme).To(Equal("dark"))
+			Expect(*byUser[other.ID]).To(Equal(Defaults(cfg)))
+		})
+	})
+})
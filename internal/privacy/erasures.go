@@ -0,0 +1,306 @@
+package privacy
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/audit"
+	"github.com/adamstrickland/dapper-api/internal/avatars"
+	"github.com/adamstrickland/dapper-api/internal/blobs"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/emailchanges"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/ratelimit"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"gorm.io/gorm"
+)
+
+// Erased replaces the identifiers of erased users that are kept, such as the
+// subjects and actors of audit entries.
+const Erased = "erased"
+
+var ErrNotClosed = errors.New("No closed account found")
+
+// Erasure schedules the removal of everything kept about a user who closed
+// their account, once the cooling-off period has passed.
+type Erasure struct {
+	gorm.Model
+	UserID   uint      `gorm:"index"`
+	DueAt    time.Time `gorm:"index"`
+	ErasedAt *time.Time
+}
+
+// Close deactivates the user's account and revokes their tokens.  When erase
+// is set, everything kept about them is also scheduled for erasure after the
+// cooling-off period; until then the account can be restored.
+func Close(cfg *config.Config, u *users.User, erase bool) (*Erasure, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var e *Erasure
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&users.User{}, u.ID).Error; err != nil {
+			return err
+		}
+
+		if err := security.RevokeSubjectTx(tx, u.PublicID); err != nil {
+			return err
+		}
+
+		if !erase {
+			return nil
+		}
+
+		e = &Erasure{
+			UserID: u.ID,
+			DueAt:  time.Now().Add(cfg.GetDuration("privacy.erasureCoolingOff")),
+		}
+
+		return tx.Create(e).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to close account of User %d: %e", u.ID, err)
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Restore reopens the closed account with the given email, cancelling its
+// erasure, provided it has not been erased yet.
+func Restore(cfg *config.Config, email string) (*users.User, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var u users.User
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().
+			Where("email = ? AND deleted_at IS NOT NULL", email).
+			Limit(1).
+			Find(&u)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrNotClosed
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", u.ID).Delete(&Erasure{}).Error; err != nil {
+			return err
+		}
+
+		result = tx.Unscoped().
+			Model(&users.User{}).
+			Where("id = ?", u.ID).
+			UpdateColumns(users.Changed(map[string]interface{}{"deleted_at": nil}))
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Where("id = ?", u.ID).Take(&u).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to restore account '%s': %e", email, err)
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// Erase removes every record of the user, and anonymizes those that have to
+// be kept: invitations they sent or were sent and audit entries about them.  Their
+// avatar and data exports are deleted from the blob store.
+func Erase(cfg *config.Config, e *Erasure) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	var (
+		u    users.User
+		keys []string
+	)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("id = ?", e.UserID).Limit(1).Find(&u).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Export{}).Unscoped().Where("user_id = ? AND blob_key <> ''", u.ID).Pluck("blob_key", &keys).Error; err != nil {
+			return err
+		}
+
+		for _, m := range []interface{}{
+			&Export{},
+			&users.Revision{},
+			&preferences.Preference{},
+			&organizations.Membership{},
+			&groups.GroupMembership{},
+			&emailchanges.EmailChange{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", u.ID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+
+		ids := subjects(&u)
+
+		if err := tx.Where("subject IN ?", ids).Delete(&security.Revocation{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("`key` LIKE ?", "%:subject:"+u.PublicID).Delete(&ratelimit.Bucket{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&invitations.Invitation{}).Unscoped().Where("LOWER(email) = LOWER(?)", u.Email).Update("email", "").Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&invitations.Invitation{}).Unscoped().Where("created_by_id = ?", u.ID).Update("created_by_id", 0).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&invitations.Redemption{}).
+			Unscoped().
+			Where("user_id = ? OR LOWER(email) = LOWER(?)", u.ID, u.Email).
+			UpdateColumns(map[string]interface{}{"user_id": 0, "email": ""})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, column := range []string{"subject", "actor"} {
+			result = tx.Model(&audit.Entry{}).
+				Unscoped().
+				Where(column+" IN ?", ids).
+				UpdateColumns(map[string]interface{}{column: Erased, "remote_addr": ""})
+
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		if err := tx.Unscoped().Delete(&users.User{}, u.ID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		e.ErasedAt = &now
+
+		return tx.Save(e).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to erase User %d: %e", e.UserID, err)
+		return err
+	}
+
+	store, err := blobs.NewStore(cfg)
+
+	if err != nil {
+		log.Printf("Unable to open blob store: %e", err)
+		return err
+	}
+
+	if u.Avatar != "" {
+		avatars.Remove(cfg, store, u.Avatar)
+	}
+
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			log.Printf("Unable to remove data export '%s': %e", key, err)
+		}
+	}
+
+	return nil
+}
+
+// EraseDue erases the users whose cooling-off period has passed, returning
+// how many were erased.
+func EraseDue(cfg *config.Config) (int, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return 0, err
+	}
+
+	var es []Erasure
+
+	result := db.Where("erased_at IS NULL AND due_at <= ?", time.Now()).Find(&es)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	for i := range es {
+		if err := Erase(cfg, &es[i]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(es), nil
+}
+
+// PurgeExports removes the exports that have expired, returning how many were
+// removed.
+func PurgeExports(cfg *config.Config) (int, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return 0, err
+	}
+
+	store, err := blobs.NewStore(cfg)
+
+	if err != nil {
+		log.Printf("Unable to open blob store: %e", err)
+		return 0, err
+	}
+
+	var exs []Export
+
+	result := db.Where("expires_at <= ?", time.Now()).Find(&exs)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	for _, ex := range exs {
+		if err := store.Delete(ex.BlobKey); err != nil {
+			log.Printf("Unable to remove data export '%s': %e", ex.BlobKey, err)
+			return 0, err
+		}
+
+		if err := db.Unscoped().Delete(&ex).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	return len(exs), nil
+}
@@ -0,0 +1,156 @@
+package privacy
+
+import (
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/audit"
+	"github.com/adamstrickland/dapper-api/internal/blobs"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("privacy/erasures.go", func() {
+	var (
+		cfg *config.Config
+		db  *internal.Conn
+		u   *users.User
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("blobs.store", "memory")
+		db, _ = internal.NewConnection(cfg)
+
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: "Arthur"})
+	})
+
+	count := func(model interface{}, query string, args ...interface{}) int64 {
+		var n int64
+
+		db.Model(model).Unscoped().Where(query, args...).Count(&n)
+
+		return n
+	}
+
+	Describe("Close()", func() {
+		It("deactivates the account", func() {
+			e, err := Close(cfg, u, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(e).To(BeNil())
+
+			_, err = users.FindByEmail(cfg, u.Email)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("schedules erasure after the cooling-off period", func() {
+			cfg.Set("privacy.erasureCoolingOff", "48h")
+
+			e, err := Close(cfg, u, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(e.DueAt).To(BeTemporally("~", time.Now().Add(48*time.Hour), time.Minute))
+		})
+	})
+
+	Describe("Restore()", func() {
+		It("reopens the account and cancels its erasure", func() {
+			Close(cfg, u, true)
+
+			_, err := Restore(cfg, u.Email)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = users.FindByEmail(cfg, u.Email)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count(&Erasure{}, "user_id = ?", u.ID)).To(BeZero())
+		})
+
+		It("only reopens closed accounts", func() {
+			_, err := Restore(cfg, u.Email)
+			Expect(err).To(Equal(ErrNotClosed))
+		})
+	})
+
+	Describe("Erase()", func() {
+		var e *Erasure
+
+		BeforeEach(func() {
+			owner, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+			org, _ := organizations.Create(cfg, &organizations.Organization{Name: "Heart of Gold"}, owner)
+			organizations.AddMember(cfg, org.ID, u.ID, organizations.RoleMember)
+
+			invitations.Create(cfg, &invitations.Invitation{CreatedByID: owner.ID, Email: u.Email, MaxUses: 1})
+			invitations.Create(cfg, &invitations.Invitation{CreatedByID: u.ID, MaxUses: 1})
+			preferences.Save(cfg, u.ID, `{"theme": "dark"}`)
+			audit.Record(cfg, &audit.Entry{Action: "test.erased", Subject: u.Email, RemoteAddr: "127.0.0.1"})
+
+			blobs.Memory.Put("avatars/erasable/64.png", []byte("png"))
+			users.SetAvatar(cfg, u.ID, "erasable/256.png")
+
+			e, _ = Close(cfg, u, true)
+			Expect(Erase(cfg, e)).To(Succeed())
+		})
+
+		It("removes the user and their records", func() {
+			Expect(count(&users.User{}, "id = ?", u.ID)).To(BeZero())
+			Expect(count(&users.Revision{}, "user_id = ?", u.ID)).To(BeZero())
+			Expect(count(&preferences.Preference{}, "user_id = ?", u.ID)).To(BeZero())
+			Expect(count(&organizations.Membership{}, "user_id = ?", u.ID)).To(BeZero())
+		})
+
+		It("anonymizes the records that are kept", func() {
+			Expect(count(&invitations.Invitation{}, "email = ?", u.Email)).To(BeZero())
+			Expect(count(&invitations.Invitation{}, "created_by_id = ?", u.ID)).To(BeZero())
+			Expect(count(&audit.Entry{}, "subject = ?", u.Email)).To(BeZero())
+			Expect(count(&audit.Entry{}, "action = ? AND subject = ? AND remote_addr = ''", "test.erased", Erased)).To(BeNumerically(">", 0))
+		})
+
+		It("removes their avatar", func() {
+			_, err := blobs.Memory.Get("avatars/erasable/64.png")
+			Expect(err).To(Equal(blobs.ErrNotFound))
+		})
+
+		It("marks the erasure done", func() {
+			Expect(e.ErasedAt).NotTo(BeNil())
+		})
+	})
+
+	Describe("EraseDue()", func() {
+		It("only erases users whose cooling-off period has passed", func() {
+			cfg.Set("privacy.erasureCoolingOff", "-1s")
+			Close(cfg, u, true)
+
+			later, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+			cfg.Set("privacy.erasureCoolingOff", "48h")
+			Close(cfg, later, true)
+
+			_, err := EraseDue(cfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(count(&users.User{}, "id = ?", u.ID)).To(BeZero())
+			Expect(count(&users.User{}, "id = ?", later.ID)).To(BeNumerically("==", 1))
+		})
+	})
+
+	Describe("PurgeExports()", func() {
+		It("removes expired exports", func() {
+			cfg.Set("privacy.exportTtl", "-1s")
+
+			ex, _ := RequestExport(cfg, u)
+			Build(cfg, u, ex)
+
+			_, err := PurgeExports(cfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(count(&Export{}, "id = ?", ex.ID)).To(BeZero())
+
+			_, err = blobs.Memory.Get(ex.BlobKey)
+			Expect(err).To(Equal(blobs.ErrNotFound))
+		})
+	})
+})
@@ -0,0 +1,364 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/audit"
+	"github.com/adamstrickland/dapper-api/internal/blobs"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/notifications"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+var (
+	ErrNotFound = errors.New("Data export not found")
+	ErrNotReady = errors.New("Data export is not ready")
+	ErrExpired  = errors.New("Data export has expired")
+)
+
+// Export is an archive of everything kept about a user, built in the
+// background and available for download until it expires.
+type Export struct {
+	gorm.Model
+	PublicID  string `gorm:"uniqueIndex"`
+	UserID    uint   `gorm:"index"`
+	Status    string `gorm:"not null;default:pending"`
+	BlobKey   string
+	ExpiresAt *time.Time `gorm:"index"`
+}
+
+type auditPayload struct {
+	Action     string    `json:"action"`
+	Subject    string    `json:"subject,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Reason     string    `json:"reason,omitempty"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// revocationPayload is when the tokens issued to one of the user's subjects
+// were last revoked.  Tokens themselves are not stored, so this is all that
+// is kept about the user's sessions.
+type revocationPayload struct {
+	Subject   string    `json:"subject"`
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+// subjects are the identifiers the user has been known by in tokens and the
+// audit trail.
+func subjects(u *users.User) []string {
+	return []string{u.PublicID, u.Email}
+}
+
+// RequestExport records a pending export of the user's data, to be built by
+// Build.
+func RequestExport(cfg *config.Config, u *users.User) (*Export, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	ex := &Export{
+		PublicID: users.NewPublicID(),
+		UserID:   u.ID,
+		Status:   StatusPending,
+	}
+
+	result := db.Create(ex)
+
+	if result.Error != nil {
+		log.Printf("Unable to create Export record: %e", result.Error)
+		return nil, result.Error
+	}
+
+	return ex, nil
+}
+
+// FindExport returns the user's export with the given public ID.
+func FindExport(cfg *config.Config, userID uint, id string) (*Export, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var ex Export
+
+	result := db.Where("public_id = ? AND user_id = ?", id, userID).Limit(1).Find(&ex)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &ex, nil
+}
+
+// Archive returns the export's archive, once it is ready and until it
+// expires.
+func Archive(cfg *config.Config, ex *Export) ([]byte, error) {
+	switch {
+	case ex.Status != StatusReady:
+		return nil, ErrNotReady
+	case ex.ExpiresAt == nil || !time.Now().Before(*ex.ExpiresAt):
+		return nil, ErrExpired
+	}
+
+	store, err := blobs.NewStore(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := store.Get(ex.BlobKey)
+
+	if errors.Is(err, blobs.ErrNotFound) {
+		return nil, ErrExpired
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Data, nil
+}
+
+func addFile(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// buildArchive gathers the user's profile, its history, their preferences,
+// token revocations and audit entries into a zip of JSON documents.
+func buildArchive(cfg *config.Config, u *users.User) ([]byte, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		revs        []users.Revision
+		revocations []security.Revocation
+	)
+
+	if err := db.Where("user_id = ?", u.ID).Order("number ASC").Find(&revs).Error; err != nil {
+		return nil, err
+	}
+
+	history := make([]users.RevisionPayload, 0, len(revs))
+
+	for i := range revs {
+		history = append(history, users.NewRevisionPayload(&revs[i]))
+	}
+
+	prefs, _, err := preferences.For(cfg, u.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("subject IN ?", subjects(u)).Find(&revocations).Error; err != nil {
+		return nil, err
+	}
+
+	revoked := make([]revocationPayload, 0, len(revocations))
+
+	for _, rev := range revocations {
+		revoked = append(revoked, revocationPayload{Subject: rev.Subject, RevokedAt: rev.RevokedAt})
+	}
+
+	es, err := audit.Involving(cfg, subjects(u)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]auditPayload, 0, len(*es))
+
+	for _, e := range *es {
+		entries = append(entries, auditPayload{
+			Action:     e.Action,
+			Subject:    e.Subject,
+			Actor:      e.Actor,
+			Outcome:    e.Outcome,
+			Reason:     e.Reason,
+			RemoteAddr: e.RemoteAddr,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range []struct {
+		name string
+		v    interface{}
+	}{
+		{"profile.json", users.NewSnapshot(u)},
+		{"history.json", history},
+		{"preferences.json", prefs},
+		{"revocations.json", revoked},
+		{"audit.json", entries},
+	} {
+		if err := addFile(zw, file.name, file.v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Build assembles and stores the archive of the user's export, marking it
+// ready or, when that fails, failed, and lets them know when it can be
+// downloaded.
+func Build(cfg *config.Config, u *users.User, ex *Export) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	err = storeArchive(cfg, u, ex)
+
+	if err != nil {
+		log.Printf("Unable to build data export %s: %e", ex.PublicID, err)
+		ex.Status = StatusFailed
+	}
+
+	if result := db.Save(ex); result.Error != nil {
+		log.Printf("Unable to update Export record: %e", result.Error)
+		return result.Error
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return notifications.Send(cfg, notifications.Message{
+		To:      u.Email,
+		Subject: "Your data export is ready",
+		Body: fmt.Sprintf(
+			"The export of your data you requested can be downloaded from /users/me/data-export/%s/archive until %s.",
+			ex.PublicID,
+			ex.ExpiresAt.Format(time.RFC1123),
+		),
+	})
+}
+
+// BuildStalled builds the exports left pending for longer than
+// "privacy.exportStallAfter", whose build was lost when the process serving
+// their request stopped, returning how many were built.
+func BuildStalled(cfg *config.Config) (int, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return 0, err
+	}
+
+	var exs []Export
+
+	result := db.Where("status = ? AND updated_at <= ?", StatusPending, time.Now().Add(-cfg.GetDuration("privacy.exportStallAfter"))).
+		Find(&exs)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	built := 0
+
+	for i := range exs {
+		var u users.User
+
+		result := db.Unscoped().Where("id = ?", exs[i].UserID).Limit(1).Find(&u)
+
+		if result.Error != nil {
+			return built, result.Error
+		}
+
+		// the user is gone, and the export with them
+		if result.RowsAffected == 0 {
+			exs[i].Status = StatusFailed
+
+			if err := db.Save(&exs[i]).Error; err != nil {
+				return built, err
+			}
+
+			continue
+		}
+
+		if err := Build(cfg, &u, &exs[i]); err != nil {
+			return built, err
+		}
+
+		built++
+	}
+
+	return built, nil
+}
+
+func storeArchive(cfg *config.Config, u *users.User, ex *Export) error {
+	data, err := buildArchive(cfg, u)
+
+	if err != nil {
+		return err
+	}
+
+	bs, err := blobs.NewStore(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("exports/%s/data-export.zip", ex.PublicID)
+
+	if err := bs.Put(key, data); err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(cfg.GetDuration("privacy.exportTtl"))
+
+	ex.Status = StatusReady
+	ex.BlobKey = key
+	ex.ExpiresAt = &expires
+
+	return nil
+}
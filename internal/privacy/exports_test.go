@@ -0,0 +1,126 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/audit"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/notifications"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("privacy/exports.go", func() {
+	var (
+		cfg *config.Config
+		u   *users.User
+		ex  *Export
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("mailer", "memory")
+		cfg.Set("blobs.store", "memory")
+
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: "Arthur"})
+		preferences.Save(cfg, u.ID, `{"theme": "dark"}`)
+		audit.Record(cfg, &audit.Entry{Action: "test.exported", Actor: u.PublicID})
+
+		ex, _ = RequestExport(cfg, u)
+	})
+
+	files := func(data []byte) map[string]string {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		Expect(err).NotTo(HaveOccurred())
+
+		contents := map[string]string{}
+
+		for _, f := range zr.File {
+			rc, _ := f.Open()
+			b, _ := io.ReadAll(rc)
+			rc.Close()
+
+			contents[f.Name] = string(b)
+		}
+
+		return contents
+	}
+
+	Describe("RequestExport()", func() {
+		It("records a pending export", func() {
+			Expect(ex.Status).To(Equal(StatusPending))
+
+			_, err := Archive(cfg, ex)
+			Expect(err).To(Equal(ErrNotReady))
+		})
+	})
+
+	Describe("Build()", func() {
+		BeforeEach(func() {
+			Expect(Build(cfg, u, ex)).To(Succeed())
+		})
+
+		It("archives the user's data", func() {
+			found, _ := FindExport(cfg, u.ID, ex.PublicID)
+			Expect(found.Status).To(Equal(StatusReady))
+
+			data, err := Archive(cfg, found)
+			Expect(err).NotTo(HaveOccurred())
+
+			contents := files(data)
+			Expect(contents).To(HaveKey("history.json"))
+			Expect(contents).To(HaveKey("revocations.json"))
+			Expect(contents["profile.json"]).To(ContainSubstring(u.Email))
+			Expect(contents["preferences.json"]).To(ContainSubstring("dark"))
+			Expect(contents["audit.json"]).To(ContainSubstring("test.exported"))
+		})
+
+		It("lets the user know", func() {
+			Expect(notifications.Outbox.To(u.Email)).To(HaveLen(1))
+		})
+
+		It("is not available once it expires", func() {
+			expired := time.Now().Add(-time.Minute)
+			ex.ExpiresAt = &expired
+
+			_, err := Archive(cfg, ex)
+			Expect(err).To(Equal(ErrExpired))
+		})
+	})
+
+	Describe("BuildStalled()", func() {
+		It("builds exports left pending", func() {
+			cfg.Set("privacy.exportStallAfter", "0s")
+
+			_, err := BuildStalled(cfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			found, _ := FindExport(cfg, u.ID, ex.PublicID)
+			Expect(found.Status).To(Equal(StatusReady))
+		})
+
+		It("leaves exports that may still be building", func() {
+			cfg.Set("privacy.exportStallAfter", "1h")
+
+			BuildStalled(cfg)
+
+			found, _ := FindExport(cfg, u.ID, ex.PublicID)
+			Expect(found.Status).To(Equal(StatusPending))
+		})
+	})
+
+	Describe("FindExport()", func() {
+		It("does not find the exports of other users", func() {
+			other, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+
+			_, err := FindExport(cfg, other.ID, ex.PublicID)
+			Expect(err).To(Equal(ErrNotFound))
+		})
+	})
+})
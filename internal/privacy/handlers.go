@@ -0,0 +1,214 @@
+package privacy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/gorilla/mux"
+)
+
+type ExportPayload struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	ArchiveURL string     `json:"archiveUrl,omitempty"`
+}
+
+type closePayload struct {
+	ErasureDueAt time.Time `json:"erasureDueAt"`
+}
+
+func exportURL(ex *Export) string {
+	return "/users/me/data-export/" + ex.PublicID
+}
+
+func NewExportPayload(ex *Export) ExportPayload {
+	ep := ExportPayload{
+		ID:        ex.PublicID,
+		Status:    ex.Status,
+		CreatedAt: ex.CreatedAt,
+		ExpiresAt: ex.ExpiresAt,
+	}
+
+	if ex.Status == StatusReady {
+		ep.ArchiveURL = exportURL(ex) + "/archive"
+	}
+
+	return ep
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var data bytes.Buffer
+
+	err := json.NewEncoder(&data).Encode(v)
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+
+	_, err = w.Write(data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+	}
+}
+
+// currentExport returns the current user's export named in the route.
+func currentExport(cfg *config.Config, w http.ResponseWriter, r *http.Request) (*Export, bool) {
+	cu, err := users.CurrentUser(cfg, r)
+
+	if err != nil {
+		log.Printf("Unable to identify user: %e", err)
+		http.Error(w, "", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	ex, err := FindExport(cfg, cu.ID, mux.Vars(r)["id"])
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "", http.StatusNotFound)
+		return nil, false
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return ex, true
+}
+
+// NewPostExportHandler starts building an export of the current user's data
+// in the background, responding with where to follow its progress.
+func NewPostExportHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		cu, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		ex, err := RequestExport(cfg, cu)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		go Build(cfg, cu, ex)
+
+		w.Header().Set("Location", exportURL(ex))
+		writeJSON(w, http.StatusAccepted, NewExportPayload(ex))
+	}
+}
+
+func NewGetExportHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		ex, ok := currentExport(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, NewExportPayload(ex))
+	}
+}
+
+// NewDownloadExportHandler serves the archive of a ready export.
+func NewDownloadExportHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ex, ok := currentExport(cfg, w, r)
+
+		if !ok {
+			return
+		}
+
+		data, err := Archive(cfg, ex)
+
+		switch {
+		case errors.Is(err, ErrNotReady):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, ErrExpired):
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		case err != nil:
+			log.Printf("Unable to read data export %s: %e", ex.PublicID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="data-export.zip"`)
+
+		if _, err := w.Write(data); err != nil {
+			log.Printf("Unable to write body: %e", err)
+		}
+	}
+}
+
+// NewDeleteCurrentHandler closes the current user's account and, when the
+// erase parameter is true, schedules the erasure of their data.
+func NewDeleteCurrentHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		erase := false
+
+		if v := r.URL.Query().Get("erase"); v != "" {
+			b, err := strconv.ParseBool(v)
+
+			if err != nil {
+				validation.WriteErrors(w, validation.Errors{{
+					Field:   "erase",
+					Code:    validation.CodeInvalid,
+					Message: "erase must be true or false",
+				}})
+				return
+			}
+
+			erase = b
+		}
+
+		cu, err := users.CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		e, err := Close(cfg, cu, erase)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if e == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, &closePayload{ErasureDueAt: e.DueAt})
+	}
+}
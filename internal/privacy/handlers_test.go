@@ -0,0 +1,116 @@
+package privacy_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/privacy"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("privacy/handlers.go", func() {
+	var (
+		rr     *httptest.ResponseRecorder
+		cfg    *config.Config
+		u      *users.User
+		vars   map[string]string
+		result map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("mailer", "memory")
+		cfg.Set("blobs.store", "memory")
+
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		vars = map[string]string{}
+		result = nil
+	})
+
+	serve := func(handler http.HandlerFunc, method, path string, as *users.User) {
+		rr = httptest.NewRecorder()
+
+		r, err := http.NewRequest(method, path, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		token, _ := security.NewTokenForSubject(cfg, as.PublicID)
+		r.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		handler.ServeHTTP(rr, mux.SetURLVars(r, vars))
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	Describe("NewPostExportHandler()", func() {
+		It("builds the export in the background", func() {
+			serve(privacy.NewPostExportHandler(cfg), "POST", "/users/me/data-export", u)
+
+			Expect(rr.Code).To(Equal(http.StatusAccepted))
+			Expect(rr.Header().Get("Location")).To(Equal("/users/me/data-export/" + result["id"].(string)))
+
+			vars["id"] = result["id"].(string)
+
+			Eventually(func() interface{} {
+				serve(privacy.NewGetExportHandler(cfg), "GET", rr.Header().Get("Location"), u)
+				return result["status"]
+			}).Should(Equal(privacy.StatusReady))
+
+			serve(privacy.NewDownloadExportHandler(cfg), "GET", "/users/me/data-export/"+vars["id"]+"/archive", u)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Header().Get("Content-Type")).To(Equal("application/zip"))
+		})
+	})
+
+	Describe("NewDownloadExportHandler()", func() {
+		var ex *privacy.Export
+
+		BeforeEach(func() {
+			ex, _ = privacy.RequestExport(cfg, u)
+			vars["id"] = ex.PublicID
+		})
+
+		It("waits for the export to be ready", func() {
+			serve(privacy.NewDownloadExportHandler(cfg), "GET", "/users/me/data-export/"+ex.PublicID+"/archive", u)
+
+			Expect(rr.Code).To(Equal(http.StatusConflict))
+		})
+
+		It("hides the exports of other users", func() {
+			other, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+			serve(privacy.NewDownloadExportHandler(cfg), "GET", "/users/me/data-export/"+ex.PublicID+"/archive", other)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("NewDeleteCurrentHandler()", func() {
+		It("closes the account", func() {
+			serve(privacy.NewDeleteCurrentHandler(cfg), "DELETE", "/users/me", u)
+
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+
+			_, err := users.FindByEmail(cfg, u.Email)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("schedules erasure when asked to", func() {
+			serve(privacy.NewDeleteCurrentHandler(cfg), "DELETE", "/users/me?erase=true", u)
+
+			Expect(rr.Code).To(Equal(http.StatusAccepted))
+			Expect(result).To(HaveKey("erasureDueAt"))
+		})
+
+		It("refuses unclear requests", func() {
+			serve(privacy.NewDeleteCurrentHandler(cfg), "DELETE", "/users/me?erase=perhaps", u)
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+	})
+})
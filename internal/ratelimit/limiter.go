@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+// Limit describes a token bucket: Burst tokens at most, refilled at a rate of
+// Requests per Period.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store holds the state of every bucket.  Implementations must be safe for
+// concurrent use.
+type Store interface {
+	Take(key string, l Limit) (*Result, error)
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+
+	return float64(l.Requests)
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+func (l Limit) validate() error {
+	if l.Requests <= 0 || l.Period <= 0 {
+		return errors.New(fmt.Sprintf("Invalid rate limit '%d/%s'", l.Requests, l.Period))
+	}
+
+	return nil
+}
+
+// take refills a bucket holding the given tokens (last updated at the given
+// time) and attempts to remove one, returning the tokens left and the result.
+func take(l Limit, tokens float64, updated time.Time, now time.Time) (float64, *Result) {
+	capacity := l.capacity()
+	rate := l.rate()
+
+	if updated.IsZero() {
+		tokens = capacity
+	} else if elapsed := now.Sub(updated).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	res := &Result{
+		Limit: int(capacity),
+	}
+
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = seconds((capacity - tokens) / rate)
+
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+// NewStore returns the Store selected by the "rateLimit.store" setting.
+func NewStore(cfg *config.Config) (Store, error) {
+	switch s := cfg.GetString("rateLimit.store"); s {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "sqlite":
+		return NewSQLStore(cfg), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown rate limit store '%s'", s))
+	}
+}
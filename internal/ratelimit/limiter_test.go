@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ratelimit/limiter.go", func() {
+	var (
+		l   Limit
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		l = Limit{Requests: 2, Period: time.Minute}
+		now = time.Now()
+	})
+
+	Describe("take()", func() {
+		When("the bucket is new", func() {
+			It("starts full", func() {
+				tokens, res := take(l, 0, time.Time{}, now)
+				Expect(res.Allowed).To(BeTrue())
+				Expect(res.Limit).To(Equal(2))
+				Expect(res.Remaining).To(Equal(1))
+				Expect(tokens).To(BeNumerically("==", 1))
+			})
+		})
+
+		When("the bucket is empty", func() {
+			It("is not allowed", func() {
+				_, res := take(l, 0, now, now)
+				Expect(res.Allowed).To(BeFalse())
+				Expect(res.Remaining).To(Equal(0))
+			})
+
+			It("reports when a token will be available", func() {
+				_, res := take(l, 0, now, now)
+				Expect(res.RetryAfter).To(Equal(30 * time.Second))
+			})
+		})
+
+		When("time has passed", func() {
+			It("refills the bucket", func() {
+				_, res := take(l, 0, now.Add(-30*time.Second), now)
+				Expect(res.Allowed).To(BeTrue())
+			})
+
+			It("does not refill beyond the burst", func() {
+				tokens, _ := take(l, 0, now.Add(-time.Hour), now)
+				Expect(tokens).To(BeNumerically("==", 1))
+			})
+		})
+
+		When("a burst is configured", func() {
+			BeforeEach(func() {
+				l.Burst = 5
+			})
+
+			It("uses the burst as the capacity", func() {
+				_, res := take(l, 0, time.Time{}, now)
+				Expect(res.Limit).To(Equal(5))
+				Expect(res.Remaining).To(Equal(4))
+			})
+		})
+	})
+
+	Describe("NewStore()", func() {
+		var cfg *config.Config
+
+		BeforeEach(func() {
+			cfg = config.Configuration()
+		})
+
+		It("returns the configured store", func() {
+			cfg.Set("rateLimit.store", "sqlite")
+			s, err := NewStore(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s).To(BeAssignableToTypeOf(&SQLStore{}))
+		})
+
+		It("rejects unknown stores", func() {
+			cfg.Set("rateLimit.store", "redis")
+			_, err := NewStore(cfg)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryStore keeps buckets in process memory; state is lost on restart and
+// is not shared between instances.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(key string, l Limit) (*Result, error) {
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+
+	if !ok {
+		b = &memoryBucket{}
+		s.buckets[key] = b
+	}
+
+	now := s.now()
+	tokens, res := take(l, b.tokens, b.updated, now)
+
+	b.tokens = tokens
+	b.updated = now
+
+	return res, nil
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ratelimit/memory.go", func() {
+	var (
+		s   *MemoryStore
+		l   Limit
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		s = NewMemoryStore()
+		s.now = func() time.Time { return now }
+		l = Limit{Requests: 2, Period: time.Minute}
+	})
+
+	Describe("Take()", func() {
+		It("allows requests up to the limit", func() {
+			for i := 0; i < 2; i++ {
+				res, err := s.Take("foo", l)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.Allowed).To(BeTrue())
+			}
+
+			res, err := s.Take("foo", l)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Allowed).To(BeFalse())
+		})
+
+		It("tracks each key separately", func() {
+			s.Take("foo", l)
+			s.Take("foo", l)
+
+			res, _ := s.Take("bar", l)
+			Expect(res.Allowed).To(BeTrue())
+		})
+
+		It("rejects invalid limits", func() {
+			_, err := s.Take("foo", Limit{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+// Policy is the limit applied to the requests of a route, and what they are
+// counted by: the client's IP, the subject of its token or its API key.
+// FailOpen lets requests through when the store cannot be reached.
+type Policy struct {
+	Name     string
+	Enabled  bool
+	Key      string
+	Limit    Limit
+	FailOpen bool
+}
+
+// storeRetryAfter is when a client refused because the store failed is told
+// to try again.
+const storeRetryAfter = time.Second
+
+// setting returns the config key holding a setting for the named route,
+// falling back to the default policy when the route does not override it.
+func setting(cfg *config.Config, name, s string) string {
+	if rk := fmt.Sprintf("rateLimit.routes.%s.%s", name, s); cfg.IsSet(rk) {
+		return rk
+	}
+
+	return fmt.Sprintf("rateLimit.default.%s", s)
+}
+
+// NewPolicy returns the policy configured for the named route.
+func NewPolicy(cfg *config.Config, name string) Policy {
+	enabled := cfg.GetBool("rateLimit.enabled")
+
+	if rk := fmt.Sprintf("rateLimit.routes.%s.enabled", name); cfg.IsSet(rk) {
+		enabled = cfg.GetBool(rk)
+	}
+
+	return Policy{
+		Name:    name,
+		Enabled: enabled,
+		Key:     cfg.GetString(setting(cfg, name, "key")),
+		Limit: Limit{
+			Requests: cfg.GetInt(setting(cfg, name, "requests")),
+			Period:   cfg.GetDuration(setting(cfg, name, "period")),
+			Burst:    cfg.GetInt(setting(cfg, name, "burst")),
+		},
+		FailOpen: cfg.GetBool(setting(cfg, name, "failOpen")),
+	}
+}
+
+// Take takes a token from the policy's bucket with the given key.  When the
+// store fails it returns nil, letting the request through, if the policy fails
+// open, and otherwise refuses the request as though the bucket were empty.
+func (p Policy) Take(store Store, key string) *Result {
+	res, err := store.Take(key, p.Limit)
+
+	if err == nil {
+		return res
+	}
+
+	log.Printf("Unable to apply rate limit: %e", err)
+
+	if p.FailOpen {
+		return nil
+	}
+
+	return &Result{
+		Limit:      int(p.Limit.capacity()),
+		Reset:      storeRetryAfter,
+		RetryAfter: storeRetryAfter,
+	}
+}
+
+// Bucket is the key of the bucket counting the policy's requests by the
+// client with the given kind of identity, such as "ip" or "subject".
+func (p Policy) Bucket(kind, id string) string {
+	return fmt.Sprintf("%s:%s:%s", p.Name, kind, id)
+}
+
+// Host is the IP of a client address, without its port.
+func Host(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
+
+var stores sync.Map
+
+// SharedStore returns the Store shared by everything serving the application
+// configured by cfg, so that a client's requests count against the same
+// buckets whether they come over REST, GraphQL or gRPC.
+func SharedStore(cfg *config.Config) Store {
+	if s, ok := stores.Load(cfg); ok {
+		return s.(Store)
+	}
+
+	s, err := NewStore(cfg)
+
+	if err != nil {
+		log.Printf("Unable to create rate limit store, using memory: %e", err)
+		s = NewMemoryStore()
+	}
+
+	actual, _ := stores.LoadOrStore(cfg, s)
+
+	return actual.(Store)
+}
+
+// TakeForAddr takes a token from the named route's bucket for the client
+// address.  It returns nil when the policy is disabled, or fails open and the
+// store fails, in which case the request proceeds, as it does through the
+// middleware.
+func TakeForAddr(cfg *config.Config, name, addr string) *Result {
+	p := NewPolicy(cfg, name)
+
+	if !p.Enabled {
+		return nil
+	}
+
+	return p.Take(SharedStore(cfg), p.Bucket("ip", Host(addr)))
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(key string, l Limit) (*Result, error) {
+	return nil, errors.New("database is locked")
+}
+
+var _ = Describe("ratelimit/policy.go", func() {
+	var cfg *config.Config
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+	})
+
+	Describe("Policy.Take()", func() {
+		It("takes from the store", func() {
+			p := NewPolicy(cfg, "login")
+
+			res := p.Take(NewMemoryStore(), p.Bucket("ip", "127.0.0.1"))
+			Expect(res.Allowed).To(BeTrue())
+		})
+
+		It("refuses requests when the store fails", func() {
+			res := NewPolicy(cfg, "login").Take(failingStore{}, "login:ip:127.0.0.1")
+
+			Expect(res).NotTo(BeNil())
+			Expect(res.Allowed).To(BeFalse())
+			Expect(res.RetryAfter).To(Equal(time.Second))
+		})
+
+		It("lets requests through when the store fails and the policy fails open", func() {
+			cfg.Set("rateLimit.routes.login.failOpen", true)
+
+			Expect(NewPolicy(cfg, "login").Take(failingStore{}, "login:ip:127.0.0.1")).To(BeNil())
+		})
+	})
+})
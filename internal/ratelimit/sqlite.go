@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"log"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"gorm.io/gorm"
+)
+
+type Bucket struct {
+	Key       string `gorm:"primaryKey"`
+	Tokens    float64
+	UpdatedAt time.Time `gorm:"autoUpdateTime:false"`
+}
+
+// SQLStore keeps buckets in the application database so that limits are
+// shared by every instance using it.
+type SQLStore struct {
+	cfg *config.Config
+	now func() time.Time
+}
+
+func NewSQLStore(cfg *config.Config) *SQLStore {
+	return &SQLStore{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+func (s *SQLStore) Take(key string, l Limit) (*Result, error) {
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+
+	db, err := internal.NewConnection(s.cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var res *Result
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// writing first takes the database's write lock before the bucket is
+		// read, so that concurrent takes queue up behind it instead of all
+		// reading the same tokens
+		result := tx.Exec("INSERT INTO buckets (key, tokens, updated_at) VALUES (?, 0, ?) ON CONFLICT (key) DO NOTHING", key, time.Time{})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var b Bucket
+
+		query := tx.Where("key = ?", key).Limit(1).Find(&b)
+
+		if query.Error != nil {
+			return query.Error
+		}
+
+		now := s.now()
+
+		b.Key = key
+		b.Tokens, res = take(l, b.Tokens, b.UpdatedAt, now)
+		b.UpdatedAt = now
+
+		return tx.Save(&b).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to update rate limit bucket '%s': %e", key, err)
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ratelimit/sqlite.go", func() {
+	var (
+		s   *SQLStore
+		l   Limit
+		key string
+	)
+
+	BeforeEach(func() {
+		s = NewSQLStore(config.Configuration())
+		l = Limit{Requests: 2, Period: time.Minute}
+		key = faker.UUIDHyphenated()
+	})
+
+	Describe("Take()", func() {
+		It("allows requests up to the limit", func() {
+			for i := 0; i < 2; i++ {
+				res, err := s.Take(key, l)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.Allowed).To(BeTrue())
+			}
+
+			res, err := s.Take(key, l)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Allowed).To(BeFalse())
+		})
+
+		It("persists the bucket", func() {
+			s.Take(key, l)
+
+			res, _ := NewSQLStore(config.Configuration()).Take(key, l)
+			Expect(res.Remaining).To(Equal(0))
+		})
+
+		It("allows no more than the limit to concurrent requests", func() {
+			l = Limit{Requests: 5, Period: time.Hour}
+
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				allowed int
+			)
+
+			start := make(chan struct{})
+
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					<-start
+
+					res, err := s.Take(key, l)
+					Expect(err).NotTo(HaveOccurred())
+
+					if res.Allowed {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}()
+			}
+
+			close(start)
+			wg.Wait()
+
+			Expect(allowed).To(Equal(5))
+		})
+	})
+})
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+// cacheControl returns the Cache-Control directives for the named route,
+// falling back to the default when the route does not override them.
+func cacheControl(cfg *config.Config, name string) string {
+	if rk := fmt.Sprintf("cacheControl.routes.%s", name); cfg.IsSet(rk) {
+		return cfg.GetString(rk)
+	}
+
+	return cfg.GetString("cacheControl.default")
+}
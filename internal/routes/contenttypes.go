@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"fmt"
+	"mime"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+// allowedContentTypes returns the media types the named route accepts,
+// falling back to the default list when the route does not override it.
+func allowedContentTypes(cfg *config.Config, name string) []string {
+	if rk := fmt.Sprintf("contentTypes.routes.%s", name); cfg.IsSet(rk) {
+		return cfg.GetStringSlice(rk)
+	}
+
+	return cfg.GetStringSlice("contentTypes.default")
+}
+
+// acceptsContentType reports whether the named route accepts the given
+// Content-Type header; parameters such as charset are ignored.  Routes that
+// list no types, such as those serving files, accept any request.
+func acceptsContentType(cfg *config.Config, name, header string) bool {
+	allowed := allowedContentTypes(cfg, name)
+
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mt, _, err := mime.ParseMediaType(header)
+
+	if err != nil {
+		return false
+	}
+
+	for _, t := range allowed {
+		if t == mt {
+			return true
+		}
+	}
+
+	return false
+}
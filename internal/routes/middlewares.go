@@ -1,11 +1,16 @@
 package routes
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/ratelimit"
+	"github.com/adamstrickland/dapper-api/internal/scim"
 	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
 )
 
 func LoggingMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
@@ -17,10 +22,52 @@ func LoggingMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
 	}
 }
 
+func RateLimitMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	store := ratelimit.SharedStore(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := ratelimit.NewPolicy(cfg, routeName(r))
+
+			if !p.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res := p.Take(store, rateLimitKey(cfg, p, r))
+
+			if res == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w, res)
+
+			if res.Allowed {
+				next.ServeHTTP(w, r)
+			} else {
+				http.Error(w, "", http.StatusTooManyRequests)
+			}
+		})
+	}
+}
+
+func CacheControlMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cc := cacheControl(cfg, routeName(r)); cc != "" {
+				w.Header().Set("Cache-Control", cc)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func ContentTypeMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Header.Get("Content-Type") != "application/json" {
+			if !acceptsContentType(cfg, routeName(r), r.Header.Get("Content-Type")) {
 				http.Error(w, "", http.StatusNotFound)
 			} else {
 				next.ServeHTTP(w, r)
@@ -44,3 +91,114 @@ func AuthnMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// OptionalAuthnMiddleware admits requests without a token, leaving it to the
+// handler to refuse what needs one, but refuses invalid tokens as
+// AuthnMiddleware does.
+func OptionalAuthnMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(cfg.GetString("tokenHeader"))
+
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if ok, err := security.IsValidToken(cfg, token); ok && err == nil {
+				next.ServeHTTP(w, r)
+			} else {
+				http.Error(w, "", http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+// TenantMiddleware resolves the organization an authenticated request acts
+// in, refusing tokens that claim one their subject does not belong to.  A
+// token claiming none acts in its subject's default organization, if any;
+// only anonymous requests and users in no organization act in none.
+func TenantMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(cfg.GetString("tokenHeader"))
+
+			if token == "" {
+				next.ServeHTTP(w, security.WithTenant(r, 0, ""))
+				return
+			}
+
+			org, err := security.TokenOrganization(cfg, token)
+
+			if err != nil {
+				http.Error(w, "", http.StatusUnauthorized)
+				return
+			}
+
+			cu, err := users.CurrentUser(cfg, r, "id")
+
+			if err != nil {
+				http.Error(w, "", http.StatusUnauthorized)
+				return
+			}
+
+			m, err := organizations.ResolveTenant(cfg, org, cu.ID)
+
+			if errors.Is(err, organizations.ErrNotMember) {
+				log.Printf("Unable to resolve organization '%s': %e", org, err)
+				http.Error(w, "", http.StatusForbidden)
+				return
+			}
+
+			if err != nil {
+				log.Printf("Unable to resolve organization '%s': %e", org, err)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+
+			if m == nil {
+				next.ServeHTTP(w, security.WithTenant(r, 0, ""))
+				return
+			}
+
+			next.ServeHTTP(w, security.WithTenant(r, m.OrganizationID, m.Role))
+		})
+	}
+}
+
+// SCIMAuthMiddleware admits the provisioning client, which acts as an admin
+// of the organization SCIM is configured for, or of the users who belong to
+// none.  The endpoints are hidden until a client is configured.
+func SCIMAuthMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !scim.Enabled(cfg) {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+
+			if err := scim.Authorize(cfg, r); err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+				scim.WriteError(w, &scim.Error{Status: http.StatusUnauthorized, Detail: err.Error()})
+				return
+			}
+
+			id := cfg.GetString("scim.organization")
+
+			if id == "" {
+				next.ServeHTTP(w, security.WithTenant(r, 0, organizations.RoleAdmin))
+				return
+			}
+
+			org, err := organizations.FindByPublicID(cfg, id)
+
+			if err != nil {
+				log.Printf("Unable to resolve SCIM organization '%s': %e", id, err)
+				scim.WriteError(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, security.WithTenant(r, org.ID, organizations.RoleAdmin))
+		})
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"net/http/httptest"
 
 	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
 	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -56,6 +59,76 @@ var _ = Describe("routes/middlewares.go", func() {
 				Expect(rr.Code).To(Equal(http.StatusOK))
 			})
 		})
+
+		When("the content type has parameters", func() {
+			BeforeEach(func() {
+				req.Header.Add("Content-Type", "application/json; charset=utf-8")
+			})
+
+			It("should be accepted", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		When("the route accepts any content type", func() {
+			BeforeEach(func() {
+				cfg.Set("contentTypes.routes.default", []string{})
+			})
+
+			It("should be accepted", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		When("the route accepts other content types", func() {
+			BeforeEach(func() {
+				cfg.Set("contentTypes.routes.default", []string{"application/merge-patch+json"})
+			})
+
+			When("the request uses one of them", func() {
+				BeforeEach(func() {
+					req.Header.Add("Content-Type", "application/merge-patch+json")
+				})
+
+				It("should be accepted", func() {
+					Expect(rr.Code).To(Equal(http.StatusOK))
+				})
+			})
+
+			When("the request uses the default", func() {
+				BeforeEach(func() {
+					req.Header.Add("Content-Type", "application/json")
+				})
+
+				It("should be rejected", func() {
+					Expect(rr.Code).NotTo(Equal(http.StatusOK))
+				})
+			})
+		})
+	})
+
+	Describe("CacheControlMiddleware()", func() {
+		BeforeEach(func() {
+			middleware = CacheControlMiddleware(cfg)
+		})
+
+		JustBeforeEach(func() {
+			middleware(handler()).ServeHTTP(rr, req)
+		})
+
+		It("sends the default directives", func() {
+			Expect(rr.Header().Get("Cache-Control")).To(Equal("private, no-cache"))
+		})
+
+		When("the route overrides them", func() {
+			BeforeEach(func() {
+				cfg.Set("cacheControl.routes.default", "private, max-age=5")
+			})
+
+			It("sends the route's directives", func() {
+				Expect(rr.Header().Get("Cache-Control")).To(Equal("private, max-age=5"))
+			})
+		})
 	})
 
 	Describe("AuthnMiddleware()", func() {
@@ -100,4 +173,267 @@ var _ = Describe("routes/middlewares.go", func() {
 			})
 		})
 	})
+
+	Describe("OptionalAuthnMiddleware()", func() {
+		BeforeEach(func() {
+			middleware = OptionalAuthnMiddleware(cfg)
+		})
+
+		JustBeforeEach(func() {
+			middleware(handler()).ServeHTTP(rr, req)
+		})
+
+		When("the request does not have an auth header", func() {
+			It("should be accepted", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		When("the token isn't valid", func() {
+			BeforeEach(func() {
+				req.Header.Add(cfg.GetString("tokenHeader"), "someinvalidstring")
+			})
+
+			It("should be rejected", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		When("the token is valid", func() {
+			BeforeEach(func() {
+				t, _ := security.NewTokenForSubject(cfg, "foo@bar.com")
+				req.Header.Add(cfg.GetString("tokenHeader"), t)
+			})
+
+			It("should be accepted", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+	})
+
+	Describe("RateLimitMiddleware()", func() {
+		BeforeEach(func() {
+			cfg.Set("rateLimit.default.requests", 1)
+			cfg.Set("rateLimit.default.period", "1m")
+			req.RemoteAddr = "10.0.0.1:1234"
+
+			middleware = RateLimitMiddleware(cfg)
+
+			middleware(handler()).ServeHTTP(httptest.NewRecorder(), req)
+		})
+
+		JustBeforeEach(func() {
+			middleware(handler()).ServeHTTP(rr, req)
+		})
+
+		When("the client has exhausted its limit", func() {
+			It("should be rejected", func() {
+				Expect(rr.Code).To(Equal(http.StatusTooManyRequests))
+			})
+
+			It("says when to retry", func() {
+				Expect(rr.Header().Get("Retry-After")).To(Equal("60"))
+			})
+
+			It("reports the limit", func() {
+				Expect(rr.Header().Get("RateLimit-Limit")).To(Equal("1"))
+				Expect(rr.Header().Get("RateLimit-Remaining")).To(Equal("0"))
+			})
+		})
+
+		When("another client makes a request", func() {
+			BeforeEach(func() {
+				req.RemoteAddr = "10.0.0.2:1234"
+			})
+
+			It("should be accepted", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		When("the limit is keyed by subject", func() {
+			BeforeEach(func() {
+				cfg.Set("rateLimit.default.key", "subject")
+
+				t, e := security.NewTokenForSubject(cfg, "foo@bar.com")
+				Expect(e).NotTo(HaveOccurred())
+
+				req.Header.Add(cfg.GetString("tokenHeader"), t)
+			})
+
+			It("should be accepted", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		When("rate limiting is disabled", func() {
+			BeforeEach(func() {
+				cfg.Set("rateLimit.enabled", false)
+			})
+
+			It("should be accepted", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+	})
+
+	Describe("TenantMiddleware()", func() {
+		var (
+			user   *users.User
+			tenant uint
+		)
+
+		BeforeEach(func() {
+			middleware = TenantMiddleware(cfg)
+			tenant = 42
+
+			user, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+
+			handler = func() http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					tenant = security.RequestTenant(r)
+				})
+			}
+		})
+
+		JustBeforeEach(func() {
+			middleware(handler()).ServeHTTP(rr, req)
+		})
+
+		When("the token claims no organization", func() {
+			BeforeEach(func() {
+				t, _ := security.NewTokenForSubject(cfg, user.PublicID)
+				req.Header.Add(cfg.GetString("tokenHeader"), t)
+			})
+
+			It("acts in no organization", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(tenant).To(BeZero())
+			})
+
+			When("the subject is a member of one", func() {
+				var org *organizations.Organization
+
+				BeforeEach(func() {
+					org, _ = organizations.Create(cfg, &organizations.Organization{Name: faker.Word()}, user)
+				})
+
+				It("acts in their default organization", func() {
+					Expect(rr.Code).To(Equal(http.StatusOK))
+					Expect(tenant).To(Equal(org.ID))
+				})
+			})
+		})
+
+		When("the token is malformed", func() {
+			BeforeEach(func() {
+				req.Header.Add(cfg.GetString("tokenHeader"), "not-a-token")
+			})
+
+			It("should be rejected", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+				Expect(tenant).To(BeEquivalentTo(42))
+			})
+		})
+
+		When("the token claims an organization", func() {
+			var org *organizations.Organization
+
+			BeforeEach(func() {
+				owner, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+				org, _ = organizations.Create(cfg, &organizations.Organization{Name: faker.Word()}, owner)
+
+				t, _ := security.NewTokenForSubjectInOrganization(cfg, user.PublicID, org.PublicID)
+				req.Header.Add(cfg.GetString("tokenHeader"), t)
+			})
+
+			When("the subject is a member", func() {
+				BeforeEach(func() {
+					organizations.AddMember(cfg, org.ID, user.ID, organizations.RoleMember)
+				})
+
+				It("acts in that organization", func() {
+					Expect(rr.Code).To(Equal(http.StatusOK))
+					Expect(tenant).To(Equal(org.ID))
+				})
+			})
+
+			When("the subject is not a member", func() {
+				It("should be rejected", func() {
+					Expect(rr.Code).To(Equal(http.StatusForbidden))
+				})
+			})
+		})
+	})
+
+	Describe("SCIMAuthMiddleware()", func() {
+		var tenant uint
+
+		BeforeEach(func() {
+			middleware = SCIMAuthMiddleware(cfg)
+			tenant = 42
+
+			cfg.Set("scim.token", "provisioning-secret")
+			cfg.Set("scim.organization", "")
+
+			handler = func() http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					tenant = security.RequestTenant(r)
+				})
+			}
+		})
+
+		JustBeforeEach(func() {
+			middleware(handler()).ServeHTTP(rr, req)
+		})
+
+		When("SCIM is not configured", func() {
+			BeforeEach(func() {
+				cfg.Set("scim.token", "")
+				req.Header.Add("Authorization", "Bearer ")
+			})
+
+			It("should be hidden", func() {
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		When("the bearer token is wrong", func() {
+			BeforeEach(func() {
+				req.Header.Add("Authorization", "Bearer guessed")
+			})
+
+			It("should be rejected", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+				Expect(rr.Header().Get("WWW-Authenticate")).To(HavePrefix("Bearer"))
+			})
+		})
+
+		When("the bearer token is right", func() {
+			BeforeEach(func() {
+				req.Header.Add("Authorization", "Bearer provisioning-secret")
+			})
+
+			It("acts in no organization", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(tenant).To(BeZero())
+			})
+
+			When("SCIM provisions an organization", func() {
+				var org *organizations.Organization
+
+				BeforeEach(func() {
+					owner, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+					org, _ = organizations.Create(cfg, &organizations.Organization{Name: faker.Word()}, owner)
+
+					cfg.Set("scim.organization", org.PublicID)
+				})
+
+				It("acts in that organization", func() {
+					Expect(rr.Code).To(Equal(http.StatusOK))
+					Expect(tenant).To(Equal(org.ID))
+				})
+			})
+		})
+	})
 })
@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/ratelimit"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/gorilla/mux"
+)
+
+func routeName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+		return route.GetName()
+	}
+
+	return "default"
+}
+
+// rateLimitKey identifies the client a request is counted against.  Subject
+// and API key policies fall back to the client IP when the request carries
+// neither.
+func rateLimitKey(cfg *config.Config, p ratelimit.Policy, r *http.Request) string {
+	switch p.Key {
+	case "subject":
+		if token := r.Header.Get(cfg.GetString("tokenHeader")); token != "" {
+			if subj, err := security.TokenSubject(cfg, token); err == nil {
+				return p.Bucket("subject", *subj)
+			}
+		}
+	case "apiKey":
+		if key := r.Header.Get(cfg.GetString("rateLimit.apiKeyHeader")); key != "" {
+			return p.Bucket("apiKey", key)
+		}
+	}
+
+	return p.Bucket("ip", ratelimit.Host(r.RemoteAddr))
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, res *ratelimit.Result) {
+	w.Header().Set("RateLimit-Limit", fmt.Sprintf("%d", res.Limit))
+	w.Header().Set("RateLimit-Remaining", fmt.Sprintf("%d", res.Remaining))
+	w.Header().Set("RateLimit-Reset", fmt.Sprintf("%d", int(res.Reset.Seconds())))
+
+	if !res.Allowed {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(res.RetryAfter.Seconds())))
+	}
+}
@@ -3,8 +3,17 @@ package routes
 import (
 	"net/http"
 
+	"github.com/adamstrickland/dapper-api/internal/avatars"
 	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/emailchanges"
+	"github.com/adamstrickland/dapper-api/internal/graph"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
 	"github.com/adamstrickland/dapper-api/internal/logins"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/preferences"
+	"github.com/adamstrickland/dapper-api/internal/privacy"
+	"github.com/adamstrickland/dapper-api/internal/scim"
 	"github.com/adamstrickland/dapper-api/internal/signups"
 	"github.com/adamstrickland/dapper-api/internal/users"
 	"github.com/gorilla/mux"
@@ -14,26 +23,259 @@ func NewRouter(cfg *config.Config) *mux.Router {
 	router := mux.NewRouter()
 
 	router.HandleFunc("/signup", signups.NewPostHandler(cfg)).
-		Methods(http.MethodPost)
+		Methods(http.MethodPost).
+		Name("signup")
 
 	router.HandleFunc("/login", logins.NewPostHandler(cfg)).
-		Methods(http.MethodPost)
+		Methods(http.MethodPost).
+		Name("login")
+
+	router.HandleFunc("/users/me/email/confirm", emailchanges.NewConfirmHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("confirmEmailChange")
+
+	router.HandleFunc("/users/me/email/cancel", emailchanges.NewCancelHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("cancelEmailChange")
+
+	router.HandleFunc("/avatars/{token:[A-Za-z0-9_-]+}/{file:[0-9]+\\.(?:png|jpg)}", avatars.NewGetHandler(cfg)).
+		Methods(http.MethodGet, http.MethodHead).
+		Name("getAvatar")
 
 	router.Use(LoggingMiddleware(cfg))
 
+	router.Use(RateLimitMiddleware(cfg))
+
+	router.Use(CacheControlMiddleware(cfg))
+
 	router.Use(ContentTypeMiddleware(cfg))
 
+	scimRouter := router.
+		PathPrefix(scim.BasePath).
+		Name("scim").
+		Subrouter()
+
+	scimRouter.HandleFunc("/ServiceProviderConfig", scim.NewGetServiceProviderConfigHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("scimGetServiceProviderConfig")
+
+	scimRouter.HandleFunc("/ResourceTypes", scim.NewGetResourceTypesHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("scimGetResourceTypes")
+
+	scimRouter.HandleFunc("/Schemas", scim.NewGetSchemasHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("scimGetSchemas")
+
+	scimRouter.HandleFunc("/Schemas/{id}", scim.NewGetSchemaHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("scimGetSchema")
+
+	scimRouter.HandleFunc("/Users", scim.NewGetUsersHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("scimGetUsers")
+
+	scimRouter.HandleFunc("/Users", scim.NewPostUserHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("scimCreateUser")
+
+	scimRouter.HandleFunc("/Users/{id:[0-9A-HJKMNP-TV-Z]{26}}", scim.NewGetUserHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("scimGetUser")
+
+	scimRouter.HandleFunc("/Users/{id:[0-9A-HJKMNP-TV-Z]{26}}", scim.NewPutUserHandler(cfg)).
+		Methods(http.MethodPut).
+		Name("scimPutUser")
+
+	scimRouter.HandleFunc("/Users/{id:[0-9A-HJKMNP-TV-Z]{26}}", scim.NewPatchUserHandler(cfg)).
+		Methods(http.MethodPatch).
+		Name("scimPatchUser")
+
+	scimRouter.HandleFunc("/Users/{id:[0-9A-HJKMNP-TV-Z]{26}}", scim.NewDeleteUserHandler(cfg)).
+		Methods(http.MethodDelete).
+		Name("scimDeleteUser")
+
+	scimRouter.HandleFunc("/Groups", scim.NewGetGroupsHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("scimGetGroups")
+
+	scimRouter.HandleFunc("/Groups", scim.NewPostGroupHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("scimCreateGroup")
+
+	scimRouter.HandleFunc("/Groups/{id:[0-9A-HJKMNP-TV-Z]{26}}", scim.NewGetGroupHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("scimGetGroup")
+
+	scimRouter.HandleFunc("/Groups/{id:[0-9A-HJKMNP-TV-Z]{26}}", scim.NewPutGroupHandler(cfg)).
+		Methods(http.MethodPut).
+		Name("scimPutGroup")
+
+	scimRouter.HandleFunc("/Groups/{id:[0-9A-HJKMNP-TV-Z]{26}}", scim.NewPatchGroupHandler(cfg)).
+		Methods(http.MethodPatch).
+		Name("scimPatchGroup")
+
+	scimRouter.HandleFunc("/Groups/{id:[0-9A-HJKMNP-TV-Z]{26}}", scim.NewDeleteGroupHandler(cfg)).
+		Methods(http.MethodDelete).
+		Name("scimDeleteGroup")
+
+	scimRouter.Use(SCIMAuthMiddleware(cfg))
+
+	graphRouter := router.
+		Name("graph").
+		Subrouter()
+
+	graphRouter.HandleFunc(graph.Path, graph.NewPostHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("graphql")
+
+	graphRouter.Use(OptionalAuthnMiddleware(cfg))
+
+	graphRouter.Use(TenantMiddleware(cfg))
+
 	srouter := router.
 		Name("secured").
 		Subrouter()
 
 	srouter.HandleFunc("/users", users.NewGetHandler(cfg)).
-		Methods(http.MethodGet)
+		Methods(http.MethodGet).
+		Name("getUsers")
 
 	srouter.HandleFunc("/users", users.NewPutHandler(cfg)).
-		Methods(http.MethodPut)
+		Methods(http.MethodPut).
+		Name("putUsers")
+
+	srouter.HandleFunc("/users/search", users.NewSearchHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("searchUsers")
+
+	srouter.HandleFunc("/users/attributes/schema", users.NewGetAttributeSchemaHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getAttributeSchema")
+
+	srouter.HandleFunc("/users/attributes/schema", users.NewPutAttributeSchemaHandler(cfg)).
+		Methods(http.MethodPut).
+		Name("putAttributeSchema")
+
+	srouter.HandleFunc("/users/me", users.NewGetCurrentHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getCurrentUser")
+
+	srouter.HandleFunc("/users/me", users.NewPatchHandler(cfg)).
+		Methods(http.MethodPatch).
+		Name("patchCurrentUser")
+
+	srouter.HandleFunc("/users/me", privacy.NewDeleteCurrentHandler(cfg)).
+		Methods(http.MethodDelete).
+		Name("deleteCurrentUser")
+
+	srouter.HandleFunc("/users/me/data-export", privacy.NewPostExportHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("requestDataExport")
+
+	srouter.HandleFunc("/users/me/data-export/{id:[0-9A-HJKMNP-TV-Z]{26}}", privacy.NewGetExportHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getDataExport")
+
+	srouter.HandleFunc("/users/me/data-export/{id:[0-9A-HJKMNP-TV-Z]{26}}/archive", privacy.NewDownloadExportHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("downloadDataExport")
+
+	srouter.HandleFunc("/users/me/preferences", preferences.NewGetHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getPreferences")
+
+	srouter.HandleFunc("/users/me/preferences", preferences.NewPutHandler(cfg)).
+		Methods(http.MethodPut).
+		Name("putPreferences")
+
+	srouter.HandleFunc("/users/me/groups", groups.NewGetCurrentUserGroupsHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getCurrentUserGroups")
+
+	srouter.HandleFunc("/users/me/history", users.NewGetCurrentHistoryHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getCurrentUserHistory")
+
+	srouter.HandleFunc("/users/{id:[0-9A-HJKMNP-TV-Z]{26}}", users.NewGetOneHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getUser")
+
+	srouter.HandleFunc("/users/me/avatar", avatars.NewPutHandler(cfg)).
+		Methods(http.MethodPut).
+		Name("putAvatar")
+
+	srouter.HandleFunc("/users/me/email", emailchanges.NewPostHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("requestEmailChange")
+
+	srouter.HandleFunc("/admin/users/export", users.NewExportHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("exportUsers")
+
+	srouter.HandleFunc("/admin/users/{id:[0-9A-HJKMNP-TV-Z]{26}}/history", users.NewGetHistoryHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getUserHistory")
+
+	srouter.HandleFunc("/invitations", invitations.NewGetHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getInvitations")
+
+	srouter.HandleFunc("/invitations", invitations.NewPostHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("createInvitation")
+
+	srouter.HandleFunc("/groups", groups.NewGetHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getGroups")
+
+	srouter.HandleFunc("/groups", groups.NewPostHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("createGroup")
+
+	srouter.HandleFunc("/groups/{id:[0-9A-HJKMNP-TV-Z]{26}}", groups.NewGetOneHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getGroup")
+
+	srouter.HandleFunc("/groups/{id:[0-9A-HJKMNP-TV-Z]{26}}", groups.NewPutHandler(cfg)).
+		Methods(http.MethodPut).
+		Name("putGroup")
+
+	srouter.HandleFunc("/groups/{id:[0-9A-HJKMNP-TV-Z]{26}}", groups.NewDeleteHandler(cfg)).
+		Methods(http.MethodDelete).
+		Name("deleteGroup")
+
+	srouter.HandleFunc("/groups/{id:[0-9A-HJKMNP-TV-Z]{26}}/members", groups.NewAddMemberHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("addGroupMember")
+
+	srouter.HandleFunc("/groups/{id:[0-9A-HJKMNP-TV-Z]{26}}/members/{userId:[0-9A-HJKMNP-TV-Z]{26}}", groups.NewRemoveMemberHandler(cfg)).
+		Methods(http.MethodDelete).
+		Name("removeGroupMember")
+
+	srouter.HandleFunc("/organizations", organizations.NewGetHandler(cfg)).
+		Methods(http.MethodGet).
+		Name("getOrganizations")
+
+	srouter.HandleFunc("/organizations", organizations.NewPostHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("createOrganization")
+
+	srouter.HandleFunc("/organizations/invitations/accept", organizations.NewAcceptHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("acceptOrganizationInvitation")
+
+	srouter.HandleFunc("/organizations/{id:[0-9A-HJKMNP-TV-Z]{26}}/invitations", organizations.NewInviteHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("inviteToOrganization")
+
+	srouter.HandleFunc("/organizations/{id:[0-9A-HJKMNP-TV-Z]{26}}/switch", organizations.NewSwitchHandler(cfg)).
+		Methods(http.MethodPost).
+		Name("switchOrganization")
 
 	srouter.Use(AuthnMiddleware(cfg))
 
+	srouter.Use(TenantMiddleware(cfg))
+
 	return router
 }
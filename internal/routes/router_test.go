@@ -68,5 +68,489 @@ var _ = Describe("router.go", func() {
 				Expect(result).To(BeTrue())
 			})
 		})
+
+		Describe("POST /users/me/email", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/users/me/email"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /users/me/email/confirm", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/users/me/email/confirm"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /users/me/email/cancel", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/users/me/email/cancel"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /invitations", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/invitations"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /invitations", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/invitations"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/me", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/me"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/{id}", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("PATCH /users/me", func() {
+			BeforeEach(func() {
+				method = "PATCH"
+				path = "/users/me"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/search", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/search"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("PUT /users/me/avatar", func() {
+			BeforeEach(func() {
+				method = "PUT"
+				path = "/users/me/avatar"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /avatars/{token}/{file}", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/avatars/abc_DEF-123/128.png"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/attributes/schema", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/attributes/schema"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("PUT /users/attributes/schema", func() {
+			BeforeEach(func() {
+				method = "PUT"
+				path = "/users/attributes/schema"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /admin/users/export", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/admin/users/export"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /organizations", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/organizations"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /organizations", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/organizations"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /organizations/invitations/accept", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/organizations/invitations/accept"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /organizations/01ARZ3NDEKTSV4RRFFQ69G5FAV/invitations", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/organizations/01ARZ3NDEKTSV4RRFFQ69G5FAV/invitations"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /organizations/01ARZ3NDEKTSV4RRFFQ69G5FAV/switch", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/organizations/01ARZ3NDEKTSV4RRFFQ69G5FAV/switch"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /groups", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/groups"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /groups", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/groups"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /groups/01ARZ3NDEKTSV4RRFFQ69G5FAV", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/groups/01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("PUT /groups/01ARZ3NDEKTSV4RRFFQ69G5FAV", func() {
+			BeforeEach(func() {
+				method = "PUT"
+				path = "/groups/01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("DELETE /groups/01ARZ3NDEKTSV4RRFFQ69G5FAV", func() {
+			BeforeEach(func() {
+				method = "DELETE"
+				path = "/groups/01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /groups/01ARZ3NDEKTSV4RRFFQ69G5FAV/members", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/groups/01ARZ3NDEKTSV4RRFFQ69G5FAV/members"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("DELETE /groups/01ARZ3NDEKTSV4RRFFQ69G5FAV/members/01BX5ZZKBKACTAV9WEVGEMMVRZ", func() {
+			BeforeEach(func() {
+				method = "DELETE"
+				path = "/groups/01ARZ3NDEKTSV4RRFFQ69G5FAV/members/01BX5ZZKBKACTAV9WEVGEMMVRZ"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/me/groups", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/me/groups"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/me/preferences", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/me/preferences"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("PUT /users/me/preferences", func() {
+			BeforeEach(func() {
+				method = "PUT"
+				path = "/users/me/preferences"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/me/history", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/me/history"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /admin/users/01ARZ3NDEKTSV4RRFFQ69G5FAV/history", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/admin/users/01ARZ3NDEKTSV4RRFFQ69G5FAV/history"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("DELETE /users/me", func() {
+			BeforeEach(func() {
+				method = "DELETE"
+				path = "/users/me"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /users/me/data-export", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/users/me/data-export"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/me/data-export/01ARZ3NDEKTSV4RRFFQ69G5FAV", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/me/data-export/01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /users/me/data-export/01ARZ3NDEKTSV4RRFFQ69G5FAV/archive", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/users/me/data-export/01ARZ3NDEKTSV4RRFFQ69G5FAV/archive"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /scim/v2/ServiceProviderConfig", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/scim/v2/ServiceProviderConfig"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /scim/v2/Schemas", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/scim/v2/Schemas"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /scim/v2/Users", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/scim/v2/Users"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /scim/v2/Users", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/scim/v2/Users"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("PATCH /scim/v2/Users/01ARZ3NDEKTSV4RRFFQ69G5FAV", func() {
+			BeforeEach(func() {
+				method = "PATCH"
+				path = "/scim/v2/Users/01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("DELETE /scim/v2/Users/01ARZ3NDEKTSV4RRFFQ69G5FAV", func() {
+			BeforeEach(func() {
+				method = "DELETE"
+				path = "/scim/v2/Users/01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("GET /scim/v2/Groups", func() {
+			BeforeEach(func() {
+				method = "GET"
+				path = "/scim/v2/Groups"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("PATCH /scim/v2/Groups/01ARZ3NDEKTSV4RRFFQ69G5FAV", func() {
+			BeforeEach(func() {
+				method = "PATCH"
+				path = "/scim/v2/Groups/01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Describe("POST /graphql", func() {
+			BeforeEach(func() {
+				method = "POST"
+				path = "/graphql"
+			})
+
+			It("is registered", func() {
+				Expect(result).To(BeTrue())
+			})
+		})
 	})
 })
@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/logins"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/signups"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	dapperv1 "github.com/adamstrickland/dapper-api/pkg/api/dapper/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type authServer struct {
+	dapperv1.UnimplementedAuthServiceServer
+	cfg *config.Config
+}
+
+// remoteAddr is the address the call came from, recorded with refused
+// signups.
+func remoteAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+
+	return ""
+}
+
+func (s *authServer) SignUp(ctx context.Context, req *dapperv1.SignUpRequest) (*dapperv1.SignUpResponse, error) {
+	u, m, err := signups.SignUp(s.cfg, remoteAddr(ctx), &signups.Request{
+		Email:      req.Email,
+		Password:   req.Password,
+		FirstName:  req.FirstName,
+		LastName:   req.LastName,
+		InviteCode: req.InviteCode,
+	})
+
+	var (
+		errs validation.Errors
+		rej  *signups.Rejection
+	)
+
+	switch {
+	case errors.As(err, &errs), errors.As(err, &rej):
+		return nil, err
+	case err != nil:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	token, err := organizations.NewToken(s.cfg, u, m)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dapperv1.SignUpResponse{Token: token, User: newUser(u)}, nil
+}
+
+func (s *authServer) Login(ctx context.Context, req *dapperv1.LoginRequest) (*dapperv1.LoginResponse, error) {
+	u, err := logins.Authenticate(s.cfg, req.Email, req.Password)
+
+	if errors.Is(err, logins.ErrInvalidPassword) || errors.Is(err, users.ErrNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "Invalid email or password")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := organizations.DefaultMembership(s.cfg, u.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := organizations.NewToken(s.cfg, u, m)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dapperv1.LoginResponse{Token: token, User: newUser(u)}, nil
+}
+
+// Refresh issues the caller a token acting in the organization their current
+// one does, with their groups there as they stand now.
+func (s *authServer) Refresh(ctx context.Context, req *dapperv1.RefreshRequest) (*dapperv1.RefreshResponse, error) {
+	c := callerOf(ctx)
+
+	u, err := users.FindBySubject(s.cfg, c.subject)
+
+	if errors.Is(err, users.ErrNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "The token's user no longer exists")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := organizations.NewToken(s.cfg, u, c.membership)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dapperv1.RefreshResponse{Token: token}, nil
+}
+
+// Validate checks a token as the REST API and AuthInterceptor do; a token
+// they would refuse, such as one whose user has since left the organization
+// it claims, is reported as invalid rather than failing the call.  The
+// organization reported is the one the token acts in, the user's default
+// when it claims none.
+func (s *authServer) Validate(ctx context.Context, req *dapperv1.ValidateRequest) (*dapperv1.ValidateResponse, error) {
+	if ok, err := security.IsValidToken(s.cfg, req.Token); !ok || err != nil {
+		return &dapperv1.ValidateResponse{}, nil
+	}
+
+	subj, err := security.TokenSubject(s.cfg, req.Token)
+
+	if err != nil {
+		return &dapperv1.ValidateResponse{}, nil
+	}
+
+	org, err := security.TokenOrganization(s.cfg, req.Token)
+
+	if err != nil {
+		return &dapperv1.ValidateResponse{}, nil
+	}
+
+	u, err := users.FindBySubject(s.cfg, *subj)
+
+	if errors.Is(err, users.ErrNotFound) {
+		return &dapperv1.ValidateResponse{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := organizations.ResolveTenant(s.cfg, org, u.ID)
+
+	if errors.Is(err, organizations.ErrNotMember) {
+		return &dapperv1.ValidateResponse{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dapperv1.ValidateResponse{Valid: true, Subject: *subj}
+
+	if m != nil {
+		resp.Organization = m.Organization.PublicID
+	}
+
+	return resp, nil
+}
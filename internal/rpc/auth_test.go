@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/signups"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	dapperv1 "github.com/adamstrickland/dapper-api/pkg/api/dapper/v1"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("rpc/auth.go", func() {
+	var (
+		cfg    *config.Config
+		client dapperv1.AuthServiceClient
+		stop   func()
+		u      *users.User
+		org    *organizations.Organization
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		cfg.Set("signupMode", signups.ModeOpen)
+
+		conn, s := serve(cfg)
+		client, stop = dapperv1.NewAuthServiceClient(conn), s
+
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email(), UnencryptedPassword: "p@ssw0rd", FirstName: "Arthur"})
+		org, _ = organizations.Create(cfg, &organizations.Organization{Name: "Magrathea"}, u)
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	Describe("SignUp", func() {
+		It("creates the user and issues them a token", func() {
+			email := faker.Email()
+
+			resp, err := client.SignUp(context.Background(), &dapperv1.SignUpRequest{Email: email, Password: "p@ssw0rd", FirstName: "Trillian"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.User.Email).To(Equal(email))
+			Expect(resp.User.FirstName).To(Equal("Trillian"))
+
+			subj, err := security.TokenSubject(cfg, resp.Token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*subj).To(Equal(resp.User.Id))
+		})
+
+		It("reports the fields that failed validation", func() {
+			_, err := client.SignUp(context.Background(), &dapperv1.SignUpRequest{Email: faker.Email(), Password: "short"})
+
+			st := status.Convert(err)
+			Expect(st.Code()).To(Equal(codes.InvalidArgument))
+			Expect(st.Details()).To(HaveLen(1))
+
+			br := st.Details()[0].(*errdetails.BadRequest)
+			Expect(br.FieldViolations[0].Field).To(Equal("password"))
+		})
+
+		It("gives the reason a signup was refused", func() {
+			cfg.Set("signupMode", signups.ModeClosed)
+
+			_, err := client.SignUp(context.Background(), &dapperv1.SignUpRequest{Email: faker.Email(), Password: "p@ssw0rd"})
+
+			st := status.Convert(err)
+			Expect(st.Code()).To(Equal(codes.PermissionDenied))
+			Expect(st.Details()).To(HaveLen(1))
+			Expect(st.Details()[0].(*errdetails.ErrorInfo).Reason).To(Equal(signups.CodeSignupsClosed))
+		})
+	})
+
+	Describe("Login", func() {
+		It("issues a token acting in the user's default organization", func() {
+			resp, err := client.Login(context.Background(), &dapperv1.LoginRequest{Email: u.Email, Password: "p@ssw0rd"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.User.Id).To(Equal(u.PublicID))
+
+			o, err := security.TokenOrganization(cfg, resp.Token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(o).To(Equal(org.PublicID))
+		})
+
+		It("refuses a wrong password", func() {
+			_, err := client.Login(context.Background(), &dapperv1.LoginRequest{Email: u.Email, Password: "wrong"})
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		})
+
+		It("refuses an unknown email alike", func() {
+			_, err := client.Login(context.Background(), &dapperv1.LoginRequest{Email: faker.Email(), Password: "p@ssw0rd"})
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		})
+	})
+
+	Describe("Refresh", func() {
+		It("issues a new token acting in the same organization", func() {
+			token, _ := security.NewTokenForSubjectInOrganization(cfg, u.PublicID, org.PublicID)
+
+			resp, err := client.Refresh(withToken(cfg, token), &dapperv1.RefreshRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			subj, _ := security.TokenSubject(cfg, resp.Token)
+			Expect(*subj).To(Equal(u.PublicID))
+
+			o, _ := security.TokenOrganization(cfg, resp.Token)
+			Expect(o).To(Equal(org.PublicID))
+		})
+
+		It("needs a token", func() {
+			_, err := client.Refresh(context.Background(), &dapperv1.RefreshRequest{})
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("reports whose a valid token is", func() {
+			token, _ := security.NewTokenForSubjectInOrganization(cfg, u.PublicID, org.PublicID)
+
+			resp, err := client.Validate(context.Background(), &dapperv1.ValidateRequest{Token: token})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Valid).To(BeTrue())
+			Expect(resp.Subject).To(Equal(u.PublicID))
+			Expect(resp.Organization).To(Equal(org.PublicID))
+		})
+
+		It("reports the default organization of a token claiming none", func() {
+			token, _ := security.NewTokenForSubject(cfg, u.PublicID)
+
+			resp, err := client.Validate(context.Background(), &dapperv1.ValidateRequest{Token: token})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Valid).To(BeTrue())
+			Expect(resp.Organization).To(Equal(org.PublicID))
+		})
+
+		It("reports a token claiming an organization its user is not a member of as invalid", func() {
+			other, _ := users.Create(cfg, &users.User{Email: faker.Email(), UnencryptedPassword: "p@ssw0rd"})
+			token, _ := security.NewTokenForSubjectInOrganization(cfg, other.PublicID, org.PublicID)
+
+			resp, err := client.Validate(context.Background(), &dapperv1.ValidateRequest{Token: token})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Valid).To(BeFalse())
+			Expect(resp.Organization).To(BeEmpty())
+		})
+
+		It("reports the token of an unknown user as invalid", func() {
+			token, _ := security.NewTokenForSubject(cfg, users.NewPublicID())
+
+			resp, err := client.Validate(context.Background(), &dapperv1.ValidateRequest{Token: token})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Valid).To(BeFalse())
+		})
+
+		It("reports a revoked token as invalid", func() {
+			token, _ := security.NewTokenForSubject(cfg, u.PublicID)
+			security.RevokeSubject(cfg, u.PublicID)
+
+			resp, err := client.Validate(context.Background(), &dapperv1.ValidateRequest{Token: token})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Valid).To(BeFalse())
+			Expect(resp.Subject).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"errors"
+	"log"
+
+	"github.com/adamstrickland/dapper-api/internal/ratelimit"
+	"github.com/adamstrickland/dapper-api/internal/signups"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain qualifies the reasons given in error details.
+const Domain = "dapper-api"
+
+// detailed returns a status error carrying the detail, or a bare one when it
+// cannot be attached.
+func detailed(code codes.Code, msg string, detail protoiface.MessageV1) error {
+	st, err := status.New(code, msg).WithDetails(detail)
+
+	if err != nil {
+		log.Printf("Unable to attach error details: %e", err)
+		return status.Error(code, msg)
+	}
+
+	return st.Err()
+}
+
+// invalid reports the fields that failed validation as a bad request.
+func invalid(errs validation.Errors) error {
+	br := &errdetails.BadRequest{}
+
+	for _, e := range errs {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       e.Field,
+			Description: e.Message,
+		})
+	}
+
+	return detailed(codes.InvalidArgument, errs.Error(), br)
+}
+
+// rejected reports why a signup was refused, with its code as the reason.
+func rejected(rej *signups.Rejection) error {
+	return detailed(codes.PermissionDenied, rej.Message, &errdetails.ErrorInfo{
+		Reason: rej.Code,
+		Domain: Domain,
+	})
+}
+
+// exhausted reports that a call was refused by its rate limit, with when it
+// may be retried.
+func exhausted(res *ratelimit.Result) error {
+	return detailed(codes.ResourceExhausted, "Too many attempts", &errdetails.RetryInfo{
+		RetryDelay: durationpb.New(res.RetryAfter),
+	})
+}
+
+// statusOf maps the errors of the packages the services share with the REST
+// API to the status a client sees, much as the handlers pick a response
+// status.  Errors that already carry a status are left as they are, and
+// others are not shown to the client.
+func statusOf(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var (
+		errs validation.Errors
+		rej  *signups.Rejection
+	)
+
+	switch {
+	case errors.As(err, &errs):
+		return invalid(errs)
+	case errors.As(err, &rej):
+		return rejected(rej)
+	case errors.Is(err, users.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, users.ErrVersionMismatch):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, users.ErrPreconditionRequired):
+		return status.Error(codes.FailedPrecondition, "A version is required")
+	case errors.Is(err, users.ErrInvalidPatch), errors.Is(err, users.ErrPatchConflict):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	log.Printf("Unable to complete call: %e", err)
+
+	return status.Error(codes.Internal, "")
+}
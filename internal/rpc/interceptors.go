@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/ratelimit"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	dapperv1 "github.com/adamstrickland/dapper-api/pkg/api/dapper/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicMethods may be called without a token.
+var publicMethods = map[string]bool{
+	"/" + dapperv1.AuthService_ServiceDesc.ServiceName + "/SignUp":   true,
+	"/" + dapperv1.AuthService_ServiceDesc.ServiceName + "/Login":    true,
+	"/" + dapperv1.AuthService_ServiceDesc.ServiceName + "/Validate": true,
+}
+
+// publicServices may be called without a token, whatever the method.
+var publicServices = []string{
+	"/" + healthpb.Health_ServiceDesc.ServiceName + "/",
+	"/grpc.reflection.",
+}
+
+func isPublic(method string) bool {
+	if publicMethods[method] {
+		return true
+	}
+
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rateLimitedMethods are counted against the rate limit of the REST route
+// doing the same, so that a client gains no attempts by switching API.
+var rateLimitedMethods = map[string]string{
+	"/" + dapperv1.AuthService_ServiceDesc.ServiceName + "/SignUp": "signup",
+	"/" + dapperv1.AuthService_ServiceDesc.ServiceName + "/Login":  "login",
+}
+
+// callToken returns the token sent in the call's metadata under the same
+// name as the REST API's token header.
+func callToken(cfg *config.Config, ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(cfg.GetString("tokenHeader"))
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func LoggingInterceptor(cfg *config.Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		log.Printf("RECV: %-8s %-25s %s", "GRPC", info.FullMethod, status.Code(err))
+		return resp, err
+	}
+}
+
+func LoggingStreamInterceptor(cfg *config.Config) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		log.Printf("RECV: %-8s %-25s %s", "GRPC", info.FullMethod, status.Code(err))
+		return err
+	}
+}
+
+// RateLimitInterceptor refuses calls to the rate-limited methods once the
+// peer has exhausted the limit of their route, taking from the same store as
+// RateLimitMiddleware.
+func RateLimitInterceptor(cfg *config.Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		name, ok := rateLimitedMethods[info.FullMethod]
+
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		if res := ratelimit.TakeForAddr(cfg, name, remoteAddr(ctx)); res != nil && !res.Allowed {
+			return nil, exhausted(res)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// ErrorInterceptor gives the errors the services return the status a client
+// should see; see statusOf.
+func ErrorInterceptor(cfg *config.Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+
+		if err != nil {
+			return nil, statusOf(err)
+		}
+
+		return resp, nil
+	}
+}
+
+// AuthInterceptor refuses calls to all but the public methods unless they
+// carry a valid token, and resolves the organization the token acts in as
+// TenantMiddleware does, refusing tokens that claim one their subject does
+// not belong to.
+func AuthInterceptor(cfg *config.Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isPublic(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		token := callToken(cfg, ctx)
+
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "A token is required")
+		}
+
+		if ok, err := security.IsValidToken(cfg, token); !ok || err != nil {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+
+		subj, err := security.TokenSubject(cfg, token)
+
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+
+		c := &caller{subject: *subj}
+
+		org, err := security.TokenOrganization(cfg, token)
+
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+
+		u, err := users.FindBySubject(cfg, c.subject, "id")
+
+		if errors.Is(err, users.ErrNotFound) {
+			return nil, status.Error(codes.Unauthenticated, "The token's user no longer exists")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.membership, err = organizations.ResolveTenant(cfg, org, u.ID)
+
+		if errors.Is(err, organizations.ErrNotMember) {
+			log.Printf("Unable to resolve organization '%s': %e", org, err)
+			return nil, status.Error(codes.PermissionDenied, "Not a member of the token's organization")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(withCaller(ctx, c), req)
+	}
+}
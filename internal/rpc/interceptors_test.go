@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	dapperv1 "github.com/adamstrickland/dapper-api/pkg/api/dapper/v1"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("rpc/interceptors.go", func() {
+	var (
+		cfg  *config.Config
+		conn *grpc.ClientConn
+		stop func()
+		u    *users.User
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		conn, stop = serve(cfg)
+
+		u, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	Describe("AuthInterceptor", func() {
+		get := func(ctx context.Context) error {
+			_, err := dapperv1.NewUserServiceClient(conn).Get(ctx, &dapperv1.GetRequest{Id: Me})
+			return err
+		}
+
+		It("admits calls with a valid token", func() {
+			token, _ := security.NewTokenForSubject(cfg, u.PublicID)
+			Expect(get(withToken(cfg, token))).To(Succeed())
+		})
+
+		It("refuses calls without a token", func() {
+			Expect(status.Code(get(context.Background()))).To(Equal(codes.Unauthenticated))
+		})
+
+		It("refuses calls with an invalid token", func() {
+			Expect(status.Code(get(withToken(cfg, "not-a-token")))).To(Equal(codes.Unauthenticated))
+		})
+
+		It("refuses tokens acting in an organization their subject does not belong to", func() {
+			owner, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+			org, _ := organizations.Create(cfg, &organizations.Organization{Name: "Sirius Cybernetics"}, owner)
+			token, _ := security.NewTokenForSubjectInOrganization(cfg, u.PublicID, org.PublicID)
+
+			Expect(status.Code(get(withToken(cfg, token)))).To(Equal(codes.PermissionDenied))
+		})
+
+		It("acts in the default organization of members whose token claims none", func() {
+			organizations.Create(cfg, &organizations.Organization{Name: "Sirius Cybernetics"}, u)
+			outsider, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+			token, _ := security.NewTokenForSubject(cfg, u.PublicID)
+
+			_, err := dapperv1.NewUserServiceClient(conn).Get(withToken(cfg, token), &dapperv1.GetRequest{Id: outsider.PublicID})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("admits health checks without a token", func() {
+			resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+				Service: dapperv1.UserService_ServiceDesc.ServiceName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Status).To(Equal(healthpb.HealthCheckResponse_SERVING))
+		})
+	})
+
+	Describe("RateLimitInterceptor", func() {
+		login := func() error {
+			_, err := dapperv1.NewAuthServiceClient(conn).Login(context.Background(), &dapperv1.LoginRequest{Email: u.Email, Password: "wrong"})
+			return err
+		}
+
+		BeforeEach(func() {
+			cfg.Set("rateLimit.routes.login.requests", 1)
+		})
+
+		It("refuses logins once the peer has exhausted the login limit", func() {
+			Expect(status.Code(login())).To(Equal(codes.Unauthenticated))
+
+			st := status.Convert(login())
+			Expect(st.Code()).To(Equal(codes.ResourceExhausted))
+			Expect(st.Details()[0].(*errdetails.RetryInfo).RetryDelay.AsDuration()).To(BeNumerically(">", 0))
+		})
+
+		It("leaves other methods to their own limits", func() {
+			Expect(status.Code(login())).To(Equal(codes.Unauthenticated))
+
+			token, _ := security.NewTokenForSubject(cfg, u.PublicID)
+			_, err := dapperv1.NewUserServiceClient(conn).Get(withToken(cfg, token), &dapperv1.GetRequest{Id: Me})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("ErrorInterceptor", func() {
+		It("hides errors the services do not expect", func() {
+			st := status.Convert(statusOf(errors.New("database is locked")))
+			Expect(st.Code()).To(Equal(codes.Internal))
+			Expect(st.Message()).To(BeEmpty())
+		})
+
+		It("leaves errors with a status as they are", func() {
+			err := status.Error(codes.Unauthenticated, "A token is required")
+			Expect(statusOf(err)).To(Equal(err))
+		})
+
+		It("gives missing users a status of not found", func() {
+			Expect(status.Code(statusOf(users.ErrNotFound))).To(Equal(codes.NotFound))
+		})
+	})
+})
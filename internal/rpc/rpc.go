@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	dapperv1 "github.com/adamstrickland/dapper-api/pkg/api/dapper/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+type callerKey struct{}
+
+// caller is who an authenticated call was made by: the subject of its token,
+// and the membership of the organization it acts in, if any, as resolved by
+// AuthInterceptor the way TenantMiddleware resolves it for the REST API.
+type caller struct {
+	subject    string
+	membership *organizations.Membership
+}
+
+// tenant is the ID of the organization the call acts in, or zero when it
+// acts in none.
+func (c *caller) tenant() uint {
+	if c.membership == nil {
+		return 0
+	}
+
+	return c.membership.OrganizationID
+}
+
+func withCaller(ctx context.Context, c *caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+func callerOf(ctx context.Context) *caller {
+	c, _ := ctx.Value(callerKey{}).(*caller)
+
+	return c
+}
+
+// NewServer returns a gRPC server offering the auth and user services, along
+// with the standard health service and, unless turned off, reflection.
+func NewServer(cfg *config.Config) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			LoggingInterceptor(cfg),
+			RateLimitInterceptor(cfg),
+			ErrorInterceptor(cfg),
+			AuthInterceptor(cfg),
+		),
+		grpc.ChainStreamInterceptor(
+			LoggingStreamInterceptor(cfg),
+		),
+	)
+
+	dapperv1.RegisterAuthServiceServer(s, &authServer{cfg: cfg})
+	dapperv1.RegisterUserServiceServer(s, &userServer{cfg: cfg})
+
+	hs := health.NewServer()
+
+	for name := range s.GetServiceInfo() {
+		hs.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	healthpb.RegisterHealthServer(s, hs)
+
+	if cfg.GetBool("grpc.reflection") {
+		reflection.Register(s)
+	}
+
+	return s
+}
+
+// Serve listens for gRPC calls on the configured port.
+func Serve(cfg *config.Config) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.GetString("grpc.port")))
+
+	if err != nil {
+		log.Printf("Unable to listen for gRPC calls: %e", err)
+		return err
+	}
+
+	return NewServer(cfg).Serve(lis)
+}
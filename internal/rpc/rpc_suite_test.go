@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRPC(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RPC Suite")
+}
+
+// serve starts the server on an in-memory listener and returns a connection
+// to it, and a function closing both.
+func serve(cfg *config.Config) (*grpc.ClientConn, func()) {
+	lis := bufconn.Listen(1 << 20)
+	s := NewServer(cfg)
+
+	go s.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	Expect(err).NotTo(HaveOccurred())
+
+	return conn, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+// withToken sends the token with calls made in the context.
+func withToken(cfg *config.Config, token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), cfg.GetString("tokenHeader"), token)
+}
@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	dapperv1 "github.com/adamstrickland/dapper-api/pkg/api/dapper/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Me stands for the caller where a user ID is expected.
+const Me = "me"
+
+type userServer struct {
+	dapperv1.UnimplementedUserServiceServer
+	cfg *config.Config
+}
+
+// newUser is the user as the services return them.
+func newUser(u *users.User) *dapperv1.User {
+	up := users.NewUserPayload(u)
+
+	var attrs map[string]interface{}
+
+	if err := json.Unmarshal(up.Attributes, &attrs); err != nil {
+		log.Printf("Unable to read attributes of user '%s': %e", u.PublicID, err)
+	}
+
+	s, err := structpb.NewStruct(attrs)
+
+	if err != nil {
+		log.Printf("Unable to convert attributes of user '%s': %e", u.PublicID, err)
+	}
+
+	return &dapperv1.User{
+		Id:         up.ID,
+		Email:      up.Email,
+		FirstName:  up.FirstName,
+		LastName:   up.LastName,
+		AvatarUrl:  up.AvatarURL,
+		Attributes: s,
+		Version:    uint64(u.Version),
+		CreatedAt:  timestamppb.New(u.CreatedAt),
+		UpdatedAt:  timestamppb.New(u.UpdatedAt),
+	}
+}
+
+// viewer returns the user the call's token was issued to.
+func (s *userServer) viewer(ctx context.Context) (*users.User, error) {
+	u, err := users.FindBySubject(s.cfg, callerOf(ctx).subject)
+
+	if errors.Is(err, users.ErrNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "The token's user no longer exists")
+	}
+
+	return u, err
+}
+
+func (s *userServer) Get(ctx context.Context, req *dapperv1.GetRequest) (*dapperv1.GetResponse, error) {
+	v, err := s.viewer(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Id == Me {
+		return &dapperv1.GetResponse{User: newUser(v)}, nil
+	}
+
+	u, err := users.FindByPublicID(s.cfg, req.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	inTenant, err := users.InTenant(s.cfg, u.ID, callerOf(ctx).tenant())
+
+	if err != nil {
+		return nil, err
+	}
+
+	// users who may not be seen are indistinguishable from missing ones
+	if !inTenant || !users.CanView(s.cfg, v, u) {
+		return nil, users.ErrNotFound
+	}
+
+	return &dapperv1.GetResponse{User: newUser(u)}, nil
+}
+
+func (s *userServer) List(ctx context.Context, req *dapperv1.ListRequest) (*dapperv1.ListResponse, error) {
+	v, err := s.viewer(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := callerOf(ctx).tenant()
+
+	q := users.Query{
+		Tenant: &tenant,
+		Limit:  int(req.Limit),
+		Cursor: req.Cursor,
+		Email:  req.Email,
+		Name:   req.Name,
+		Sort:   req.Sort,
+	}
+
+	if req.CreatedAfter != nil {
+		t := req.CreatedAfter.AsTime()
+		q.CreatedAfter = &t
+	}
+
+	if req.CreatedBefore != nil {
+		t := req.CreatedBefore.AsTime()
+		q.CreatedBefore = &t
+	}
+
+	if max := s.cfg.GetInt("pagination.maxLimit"); q.Limit < 0 || q.Limit > max {
+		return nil, validation.Errors{{
+			Field:   "limit",
+			Code:    validation.CodeInvalid,
+			Message: fmt.Sprintf("limit must be between 1 and %d", max),
+		}}
+	}
+
+	us, next, err := users.Page(s.cfg, users.ScopeQuery(s.cfg, v, q))
+
+	switch {
+	case errors.Is(err, users.ErrInvalidSort):
+		return nil, validation.Errors{{
+			Field:   "sort",
+			Code:    validation.CodeInvalid,
+			Message: "sort must be one of createdAt, email, firstName or lastName, optionally prefixed with '-'",
+		}}
+	case errors.Is(err, users.ErrInvalidCursor):
+		return nil, validation.Errors{{
+			Field:   "cursor",
+			Code:    validation.CodeInvalid,
+			Message: err.Error(),
+		}}
+	case err != nil:
+		return nil, err
+	}
+
+	resp := &dapperv1.ListResponse{
+		Users:      make([]*dapperv1.User, 0, len(*us)),
+		NextCursor: next,
+	}
+
+	for i := range *us {
+		resp.Users = append(resp.Users, newUser(&(*us)[i]))
+	}
+
+	return resp, nil
+}
+
+// Update changes the caller as a merge patch of the fields given would.
+func (s *userServer) Update(ctx context.Context, req *dapperv1.UpdateRequest) (*dapperv1.UpdateResponse, error) {
+	v, err := s.viewer(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Version == 0 && s.cfg.GetBool("users.requireIfMatch") {
+		return nil, users.ErrPreconditionRequired
+	}
+
+	changes := map[string]interface{}{}
+
+	if req.FirstName != nil {
+		changes["firstName"] = *req.FirstName
+	}
+
+	if req.LastName != nil {
+		changes["lastName"] = *req.LastName
+	}
+
+	if req.Attributes != nil {
+		changes["attributes"] = req.Attributes.AsMap()
+	}
+
+	patch, err := json.Marshal(changes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := users.Modify(s.cfg, v.ID, uint(req.Version), func(u *users.User) error {
+		return users.PatchUser(s.cfg, u, users.MergePatchType, patch)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dapperv1.UpdateResponse{User: newUser(u)}, nil
+}
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	dapperv1 "github.com/adamstrickland/dapper-api/pkg/api/dapper/v1"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ = Describe("rpc/users.go", func() {
+	var (
+		cfg                  *config.Config
+		client               dapperv1.UserServiceClient
+		stop                 func()
+		owner, member, other *users.User
+		org                  *organizations.Organization
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+
+		conn, s := serve(cfg)
+		client, stop = dapperv1.NewUserServiceClient(conn), s
+
+		owner, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: "Zaphod"})
+		member, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: "Ford"})
+		other, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+
+		org, _ = organizations.Create(cfg, &organizations.Organization{Name: "Heart of Gold"}, owner)
+		organizations.AddMember(cfg, org.ID, member.ID, organizations.RoleMember)
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	// as is a context calling as the user, acting in the organization.
+	as := func(u *users.User) context.Context {
+		token, _ := security.NewTokenForSubjectInOrganization(cfg, u.PublicID, org.PublicID)
+
+		return withToken(cfg, token)
+	}
+
+	Describe("Get", func() {
+		It("returns the caller for me", func() {
+			resp, err := client.Get(as(owner), &dapperv1.GetRequest{Id: Me})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.User.Id).To(Equal(owner.PublicID))
+			Expect(resp.User.FirstName).To(Equal("Zaphod"))
+			Expect(resp.User.Version).To(BeEquivalentTo(owner.Version))
+		})
+
+		It("returns users of the tenant", func() {
+			resp, err := client.Get(as(owner), &dapperv1.GetRequest{Id: member.PublicID})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.User.Email).To(Equal(member.Email))
+		})
+
+		It("does not find users of other tenants", func() {
+			_, err := client.Get(as(owner), &dapperv1.GetRequest{Id: other.PublicID})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("does not find users who do not exist", func() {
+			_, err := client.Get(as(owner), &dapperv1.GetRequest{Id: users.NewPublicID()})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+	})
+
+	Describe("List", func() {
+		It("pages through the users of the tenant", func() {
+			resp, err := client.List(as(owner), &dapperv1.ListRequest{Limit: 1, Sort: "createdAt"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Users).To(HaveLen(1))
+			Expect(resp.Users[0].Id).To(Equal(owner.PublicID))
+			Expect(resp.NextCursor).NotTo(BeEmpty())
+
+			resp, err = client.List(as(owner), &dapperv1.ListRequest{Limit: 1, Sort: "createdAt", Cursor: resp.NextCursor})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Users).To(HaveLen(1))
+			Expect(resp.Users[0].Id).To(Equal(member.PublicID))
+			Expect(resp.NextCursor).To(BeEmpty())
+		})
+
+		It("reports an invalid sort", func() {
+			_, err := client.List(as(owner), &dapperv1.ListRequest{Sort: "password"})
+
+			st := status.Convert(err)
+			Expect(st.Code()).To(Equal(codes.InvalidArgument))
+			Expect(st.Details()[0].(*errdetails.BadRequest).FieldViolations[0].Field).To(Equal("sort"))
+		})
+
+		It("reports an invalid limit", func() {
+			_, err := client.List(as(owner), &dapperv1.ListRequest{Limit: int32(cfg.GetInt("pagination.maxLimit") + 1)})
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+	})
+
+	Describe("Update", func() {
+		It("changes the names given", func() {
+			resp, err := client.Update(as(member), &dapperv1.UpdateRequest{LastName: proto.String("Prefect")})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.User.FirstName).To(Equal("Ford"))
+			Expect(resp.User.LastName).To(Equal("Prefect"))
+			Expect(resp.User.Version).To(BeEquivalentTo(member.Version + 1))
+		})
+
+		It("applies only to the user at the version given", func() {
+			_, err := client.Update(as(member), &dapperv1.UpdateRequest{LastName: proto.String("Prefect"), Version: uint64(member.Version + 1)})
+			Expect(status.Code(err)).To(Equal(codes.Aborted))
+		})
+
+		It("needs a version when updates must be conditional", func() {
+			cfg.Set("users.requireIfMatch", true)
+
+			_, err := client.Update(as(member), &dapperv1.UpdateRequest{LastName: proto.String("Prefect")})
+			Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		})
+	})
+})
@@ -0,0 +1,231 @@
+package scim
+
+import (
+	"net/http"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/gorilla/mux"
+)
+
+type supported struct {
+	Supported bool `json:"supported"`
+}
+
+type filterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type bulkSupport struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type authenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+type serviceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 supported              `json:"patch"`
+	Bulk                  bulkSupport            `json:"bulk"`
+	Filter                filterSupport          `json:"filter"`
+	ChangePassword        supported              `json:"changePassword"`
+	Sort                  supported              `json:"sort"`
+	ETag                  supported              `json:"etag"`
+	AuthenticationSchemes []authenticationScheme `json:"authenticationSchemes"`
+	Meta                  Meta                   `json:"meta"`
+}
+
+// Attribute describes an attribute of a schema, as in RFC 7643 section 7.
+type Attribute struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	MultiValued   bool        `json:"multiValued"`
+	Required      bool        `json:"required"`
+	CaseExact     bool        `json:"caseExact"`
+	Mutability    string      `json:"mutability"`
+	Returned      string      `json:"returned"`
+	Uniqueness    string      `json:"uniqueness"`
+	SubAttributes []Attribute `json:"subAttributes,omitempty"`
+}
+
+type Schema struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Attributes  []Attribute `json:"attributes"`
+	Meta        Meta        `json:"meta"`
+}
+
+type ResourceType struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Endpoint    string   `json:"endpoint"`
+	Description string   `json:"description"`
+	Schema      string   `json:"schema"`
+	Meta        Meta     `json:"meta"`
+}
+
+// attr describes a single-valued attribute that is read and written by
+// default and is not unique.
+func attr(name, typ string) Attribute {
+	return Attribute{Name: name, Type: typ, Mutability: "readWrite", Returned: "default", Uniqueness: "none"}
+}
+
+func userSchema() Schema {
+	id := attr("id", "string")
+	id.CaseExact, id.Mutability, id.Returned, id.Uniqueness = true, "readOnly", "always", "server"
+
+	externalID := attr("externalId", "string")
+	externalID.CaseExact = true
+
+	userName := attr("userName", "string")
+	userName.Required, userName.Uniqueness = true, "server"
+
+	name := attr("name", "complex")
+	name.SubAttributes = []Attribute{attr("formatted", "string"), attr("givenName", "string"), attr("familyName", "string")}
+	name.SubAttributes[0].Mutability = "readOnly"
+
+	displayName := attr("displayName", "string")
+	displayName.Mutability = "readOnly"
+
+	emails := attr("emails", "complex")
+	emails.MultiValued = true
+	emails.SubAttributes = []Attribute{attr("value", "string"), attr("type", "string"), attr("primary", "boolean")}
+
+	password := attr("password", "string")
+	password.CaseExact, password.Mutability, password.Returned = true, "writeOnly", "never"
+
+	return Schema{
+		Schemas:     []string{SchemaSchema},
+		ID:          SchemaUser,
+		Name:        "User",
+		Description: "User Account",
+		Attributes:  []Attribute{id, externalID, userName, name, displayName, emails, attr("active", "boolean"), password},
+		Meta:        Meta{ResourceType: "Schema", Location: BasePath + "/Schemas/" + SchemaUser},
+	}
+}
+
+func groupSchema() Schema {
+	id := attr("id", "string")
+	id.CaseExact, id.Mutability, id.Returned, id.Uniqueness = true, "readOnly", "always", "server"
+
+	displayName := attr("displayName", "string")
+	displayName.Required, displayName.Uniqueness = true, "server"
+
+	value := attr("value", "string")
+	value.CaseExact, value.Mutability = true, "immutable"
+
+	display := attr("display", "string")
+	display.Mutability = "readOnly"
+
+	ref := attr("$ref", "reference")
+	ref.Mutability = "immutable"
+
+	members := attr("members", "complex")
+	members.MultiValued = true
+	members.SubAttributes = []Attribute{value, display, ref}
+
+	return Schema{
+		Schemas:     []string{SchemaSchema},
+		ID:          SchemaGroup,
+		Name:        "Group",
+		Description: "Group",
+		Attributes:  []Attribute{id, displayName, members},
+		Meta:        Meta{ResourceType: "Schema", Location: BasePath + "/Schemas/" + SchemaGroup},
+	}
+}
+
+func schemas() []Schema {
+	return []Schema{userSchema(), groupSchema()}
+}
+
+func resourceTypes() []ResourceType {
+	return []ResourceType{
+		{
+			Schemas:     []string{SchemaResourceType},
+			ID:          "User",
+			Name:        "User",
+			Endpoint:    "/Users",
+			Description: "User Account",
+			Schema:      SchemaUser,
+			Meta:        Meta{ResourceType: "ResourceType", Location: BasePath + "/ResourceTypes/User"},
+		},
+		{
+			Schemas:     []string{SchemaResourceType},
+			ID:          "Group",
+			Name:        "Group",
+			Endpoint:    "/Groups",
+			Description: "Group",
+			Schema:      SchemaGroup,
+			Meta:        Meta{ResourceType: "ResourceType", Location: BasePath + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+func NewGetServiceProviderConfigHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, &serviceProviderConfig{
+			Schemas:        []string{SchemaServiceProviderConfig},
+			Patch:          supported{true},
+			Filter:         filterSupport{Supported: true, MaxResults: cfg.GetInt("pagination.maxLimit")},
+			ChangePassword: supported{true},
+			ETag:           supported{true},
+			AuthenticationSchemes: []authenticationScheme{{
+				Type:        "oauthbearertoken",
+				Name:        "Bearer Token",
+				Description: "The provisioning client's token, sent in the Authorization header",
+				Primary:     true,
+			}},
+			Meta: Meta{ResourceType: "ServiceProviderConfig", Location: BasePath + "/ServiceProviderConfig"},
+		})
+	}
+}
+
+func NewGetSchemasHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ss := schemas()
+		resources := make([]interface{}, 0, len(ss))
+
+		for _, s := range ss {
+			resources = append(resources, s)
+		}
+
+		writeJSON(w, http.StatusOK, newListPayload(int64(len(ss)), page{startIndex: 1}, resources))
+	}
+}
+
+func NewGetSchemaHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		for _, s := range schemas() {
+			if s.ID == id {
+				writeJSON(w, http.StatusOK, s)
+				return
+			}
+		}
+
+		WriteError(w, &Error{Status: http.StatusNotFound, Detail: "Schema " + id + " not found"})
+	}
+}
+
+func NewGetResourceTypesHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rts := resourceTypes()
+		resources := make([]interface{}, 0, len(rts))
+
+		for _, rt := range rts {
+			resources = append(resources, rt)
+		}
+
+		writeJSON(w, http.StatusOK, newListPayload(int64(len(rts)), page{startIndex: 1}, resources))
+	}
+}
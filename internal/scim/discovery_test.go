@@ -0,0 +1,71 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/scim"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scim/discovery.go", func() {
+	var (
+		rr     *httptest.ResponseRecorder
+		cfg    *config.Config
+		vars   map[string]string
+		result map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		vars = map[string]string{}
+	})
+
+	serve := func(handler http.HandlerFunc, path string) {
+		rr = httptest.NewRecorder()
+
+		r, _ := http.NewRequest("GET", path, nil)
+
+		handler.ServeHTTP(rr, mux.SetURLVars(r, vars))
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	Describe("NewGetServiceProviderConfigHandler()", func() {
+		It("describes what is supported", func() {
+			serve(scim.NewGetServiceProviderConfigHandler(cfg), "/scim/v2/ServiceProviderConfig")
+
+			Expect(result["patch"]).To(Equal(map[string]interface{}{"supported": true}))
+			Expect(result["bulk"].(map[string]interface{})["supported"]).To(BeFalse())
+			Expect(result["filter"].(map[string]interface{})["maxResults"]).To(BeNumerically("==", cfg.GetInt("pagination.maxLimit")))
+		})
+	})
+
+	Describe("NewGetSchemasHandler()", func() {
+		It("lists the User and Group schemas", func() {
+			serve(scim.NewGetSchemasHandler(cfg), "/scim/v2/Schemas")
+
+			Expect(result["totalResults"]).To(BeNumerically("==", 2))
+		})
+	})
+
+	Describe("NewGetSchemaHandler()", func() {
+		It("returns a schema by its URN", func() {
+			vars["id"] = scim.SchemaUser
+			serve(scim.NewGetSchemaHandler(cfg), "/scim/v2/Schemas/"+scim.SchemaUser)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["name"]).To(Equal("User"))
+		})
+
+		It("does not find unknown schemas", func() {
+			vars["id"] = "urn:example:Unknown"
+			serve(scim.NewGetSchemaHandler(cfg), "/scim/v2/Schemas/urn:example:Unknown")
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -0,0 +1,244 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"gorm.io/gorm"
+)
+
+// The kinds of attribute a filter can compare.
+const (
+	kindString   = "string"
+	kindDateTime = "dateTime"
+	kindActive   = "active"
+)
+
+// comparison is one attribute expression of a filter, such as
+// userName eq "arthur@example.com".  Attribute names and operators are
+// lowercased, as both are case-insensitive.
+type comparison struct {
+	attr  string
+	op    string
+	value interface{}
+}
+
+// attribute maps a filterable SCIM attribute onto a column.
+type attribute struct {
+	column    string
+	kind      string
+	caseExact bool
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+var operators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+
+	rs := []rune(s)
+
+	for i := 0; i < len(rs); {
+		switch {
+		case unicode.IsSpace(rs[i]):
+			i++
+		case rs[i] == '"':
+			j := i + 1
+
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				if rs[j] == '\\' {
+					j++
+				}
+			}
+
+			if j >= len(rs) {
+				return nil, invalid(TypeInvalidFilter, "Unterminated string in filter")
+			}
+
+			var text string
+
+			if err := json.Unmarshal([]byte(string(rs[i:j+1])), &text); err != nil {
+				return nil, invalid(TypeInvalidFilter, "Invalid string in filter: %s", err)
+			}
+
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = j + 1
+		case rs[i] == '(' || rs[i] == ')':
+			return nil, invalid(TypeInvalidFilter, "Grouping is not supported in filters")
+		default:
+			j := i
+
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && rs[j] != '"' {
+				j++
+			}
+
+			tokens = append(tokens, token{text: string(rs[i:j])})
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
+
+func literal(t token) (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	var n float64
+
+	if err := json.Unmarshal([]byte(t.text), &n); err != nil {
+		return nil, invalid(TypeInvalidFilter, "Invalid value '%s' in filter", t.text)
+	}
+
+	return n, nil
+}
+
+// parseFilter reads a filter made of comparisons joined by "and"; "or",
+// "not" and grouping are not supported.
+func parseFilter(s string) ([]comparison, error) {
+	tokens, err := tokenize(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var cs []comparison
+
+	for i := 0; i < len(tokens); {
+		if len(cs) > 0 {
+			switch strings.ToLower(tokens[i].text) {
+			case "and":
+				i++
+			case "or", "not":
+				return nil, invalid(TypeInvalidFilter, "'%s' is not supported in filters", tokens[i].text)
+			default:
+				return nil, invalid(TypeInvalidFilter, "Expected 'and' in filter, found '%s'", tokens[i].text)
+			}
+		}
+
+		if i+1 >= len(tokens) || tokens[i].quoted {
+			return nil, invalid(TypeInvalidFilter, "Incomplete filter")
+		}
+
+		c := comparison{
+			attr: strings.ToLower(tokens[i].text),
+			op:   strings.ToLower(tokens[i+1].text),
+		}
+
+		if !operators[c.op] {
+			return nil, invalid(TypeInvalidFilter, "Unknown operator '%s' in filter", tokens[i+1].text)
+		}
+
+		i += 2
+
+		if c.op != "pr" {
+			if i >= len(tokens) {
+				return nil, invalid(TypeInvalidFilter, "Missing value in filter")
+			}
+
+			if c.value, err = literal(tokens[i]); err != nil {
+				return nil, err
+			}
+
+			i++
+		}
+
+		cs = append(cs, c)
+	}
+
+	return cs, nil
+}
+
+var sqlOperators = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<=",
+}
+
+// applyFilter narrows the query to the resources the comparisons admit.
+func applyFilter(query *gorm.DB, cs []comparison, attrs map[string]attribute) (*gorm.DB, error) {
+	for _, c := range cs {
+		a, ok := attrs[c.attr]
+
+		if !ok {
+			return nil, invalid(TypeInvalidFilter, "Filtering on '%s' is not supported", c.attr)
+		}
+
+		switch a.kind {
+		case kindActive:
+			b, ok := c.value.(bool)
+
+			if !ok || (c.op != "eq" && c.op != "ne") {
+				return nil, invalid(TypeInvalidFilter, "active can only be compared with eq or ne and a boolean")
+			}
+
+			if b == (c.op == "eq") {
+				query = query.Where(a.column + " IS NULL")
+			} else {
+				query = query.Where(a.column + " IS NOT NULL")
+			}
+		case kindDateTime:
+			if c.op == "pr" {
+				continue
+			}
+
+			s, _ := c.value.(string)
+			t, err := time.Parse(time.RFC3339, s)
+
+			if err != nil || sqlOperators[c.op] == "" {
+				return nil, invalid(TypeInvalidFilter, "%s can only be compared with a timestamp", c.attr)
+			}
+
+			query = query.Where(a.column+" "+sqlOperators[c.op]+" ?", t)
+		default:
+			if c.op == "pr" {
+				query = query.Where(a.column + " IS NOT NULL AND " + a.column + " <> ''")
+				continue
+			}
+
+			s, ok := c.value.(string)
+
+			if !ok {
+				return nil, invalid(TypeInvalidFilter, "%s can only be compared with a string", c.attr)
+			}
+
+			column := a.column
+
+			if !a.caseExact {
+				column = "LOWER(" + column + ")"
+				s = strings.ToLower(s)
+			}
+
+			switch c.op {
+			case "co":
+				query = query.Where(column+` LIKE ? ESCAPE '\'`, "%"+internal.EscapeLike(s)+"%")
+			case "sw":
+				query = query.Where(column+` LIKE ? ESCAPE '\'`, internal.EscapeLike(s)+"%")
+			case "ew":
+				query = query.Where(column+` LIKE ? ESCAPE '\'`, "%"+internal.EscapeLike(s))
+			default:
+				query = query.Where(column+" "+sqlOperators[c.op]+" ?", s)
+			}
+		}
+	}
+
+	return query, nil
+}
@@ -0,0 +1,51 @@
+package scim
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scim/filter.go", func() {
+	Describe("parseFilter()", func() {
+		It("reads comparisons joined by and", func() {
+			cs, err := parseFilter(`userName Eq "arthur@example.com" and active eq true and name.familyName pr`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cs).To(Equal([]comparison{
+				{attr: "username", op: "eq", value: "arthur@example.com"},
+				{attr: "active", op: "eq", value: true},
+				{attr: "name.familyname", op: "pr"},
+			}))
+		})
+
+		It("reads escaped strings", func() {
+			cs, err := parseFilter(`displayName eq "The \"Heart\" of Gold"`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs[0].value).To(Equal(`The "Heart" of Gold`))
+		})
+
+		It("accepts an empty filter", func() {
+			cs, err := parseFilter("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs).To(BeEmpty())
+		})
+
+		unsupported := map[string]string{
+			"or":                   `userName eq "a" or userName eq "b"`,
+			"grouping":             `(userName eq "a")`,
+			"unknown operators":    `userName like "a"`,
+			"missing values":       `userName eq`,
+			"unterminated strings": `userName eq "a`,
+		}
+
+		for name, filter := range unsupported {
+			filter := filter
+
+			It("refuses "+name, func() {
+				_, err := parseFilter(filter)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*Error).ScimType).To(Equal(TypeInvalidFilter))
+			})
+		}
+	})
+})
@@ -0,0 +1,465 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type Member struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// GroupResource is a group as SCIM represents it.  Its members are users;
+// groups cannot be nested.
+type GroupResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	DisplayName string   `json:"displayName" validate:"required,max=100"`
+	Members     []Member `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// groupAttributes are the group attributes a filter can compare.
+var groupAttributes = map[string]attribute{
+	"id":                {column: "groups.public_id", kind: kindString, caseExact: true},
+	"displayname":       {column: "groups.name", kind: kindString},
+	"meta.created":      {column: "groups.created_at", kind: kindDateTime},
+	"meta.lastmodified": {column: "groups.updated_at", kind: kindDateTime},
+}
+
+func groupLocation(id string) string {
+	return BasePath + "/Groups/" + id
+}
+
+// groupVersion is the weak entity tag of a group, built from when it last
+// changed.
+func groupVersion(g *groups.Group) string {
+	return fmt.Sprintf(`W/"%d"`, g.UpdatedAt.UnixNano())
+}
+
+// NewGroupResource represents the group, with its members unless they are
+// left out.
+func NewGroupResource(g *groups.Group, members bool) *GroupResource {
+	created := g.CreatedAt
+	modified := g.UpdatedAt
+
+	gr := &GroupResource{
+		Schemas:     []string{SchemaGroup},
+		ID:          g.PublicID,
+		DisplayName: g.Name,
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      &created,
+			LastModified: &modified,
+			Location:     groupLocation(g.PublicID),
+			Version:      groupVersion(g),
+		},
+	}
+
+	if !members {
+		return gr
+	}
+
+	gr.Members = []Member{}
+
+	for _, m := range g.Memberships {
+		gr.Members = append(gr.Members, Member{
+			Value:   m.User.PublicID,
+			Display: m.User.Email,
+			Ref:     userLocation(m.User.PublicID),
+		})
+	}
+
+	return gr
+}
+
+func groupQuery(db *gorm.DB, tenant uint) *gorm.DB {
+	return db.Model(&groups.Group{}).Where("groups.organization_id = ?", tenant)
+}
+
+// withMembers loads the members of the groups, including those whose
+// accounts are closed.
+func withMembers(query *gorm.DB) *gorm.DB {
+	return query.
+		Preload("Memberships", func(db *gorm.DB) *gorm.DB {
+			return db.Order("group_memberships.id ASC")
+		}).
+		Preload("Memberships.User", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		})
+}
+
+func findGroup(cfg *config.Config, tenant uint, id string) (*groups.Group, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var g groups.Group
+
+	result := withMembers(groupQuery(db.DB, tenant)).Where("groups.public_id = ?", id).Limit(1).Find(&g)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, &Error{Status: http.StatusNotFound, Detail: fmt.Sprintf("Group %s not found", id)}
+	}
+
+	return &g, nil
+}
+
+func listGroups(cfg *config.Config, tenant uint, cs []comparison, p page, members bool) ([]groups.Group, int64, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, 0, err
+	}
+
+	query, err := applyFilter(groupQuery(db.DB, tenant), cs, groupAttributes)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	gs := []groups.Group{}
+
+	if p.count == 0 {
+		return gs, total, nil
+	}
+
+	if members {
+		query = withMembers(query)
+	}
+
+	result := query.Order("groups.id ASC").Offset(p.startIndex - 1).Limit(p.count).Find(&gs)
+
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return gs, total, nil
+}
+
+// resolveMembers finds the users the members refer to, which must be users of
+// the tenant.
+func resolveMembers(cfg *config.Config, tenant uint, ms []Member) (map[uint]*users.User, error) {
+	found := map[uint]*users.User{}
+
+	for _, m := range ms {
+		u, err := findUser(cfg, tenant, m.Value)
+
+		var se *Error
+
+		if errors.As(err, &se) && se.Status == http.StatusNotFound {
+			return nil, invalid(TypeInvalidValue, "Member %s is not a user", m.Value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		found[u.ID] = u
+	}
+
+	return found, nil
+}
+
+func validateGroup(gr *GroupResource) error {
+	if errs := validation.Validate(gr); errs != nil {
+		return invalid(TypeInvalidValue, "%s", errs.Error())
+	}
+
+	return nil
+}
+
+func nameTaken(name string) *Error {
+	return &Error{
+		Status:   http.StatusConflict,
+		ScimType: TypeUniqueness,
+		Detail:   fmt.Sprintf("displayName '%s' is already in use", name),
+	}
+}
+
+// createGroup records a group in the tenant with the given members.  Groups
+// provisioned through SCIM have no owner.
+func createGroup(cfg *config.Config, tenant uint, gr *GroupResource) (*groups.Group, error) {
+	if err := validateGroup(gr); err != nil {
+		return nil, err
+	}
+
+	members, err := resolveMembers(cfg, tenant, gr.Members)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := groups.Create(cfg, tenant, &groups.Group{Name: gr.DisplayName}, nil)
+
+	if errors.Is(err, groups.ErrNameTaken) {
+		return nil, nameTaken(gr.DisplayName)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range members {
+		if _, err := groups.AddMember(cfg, g, u, groups.RoleMember); err != nil {
+			return nil, err
+		}
+	}
+
+	return findGroup(cfg, tenant, g.PublicID)
+}
+
+// replaceGroup renames the group and makes its members those of the resource.
+// Members who stay keep their role.
+func replaceGroup(cfg *config.Config, g *groups.Group, gr *GroupResource) (*groups.Group, error) {
+	if err := validateGroup(gr); err != nil {
+		return nil, err
+	}
+
+	members, err := resolveMembers(cfg, g.OrganizationID, gr.Members)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if gr.DisplayName != g.Name {
+		g.Name = gr.DisplayName
+
+		if _, err := groups.Update(cfg, g); errors.Is(err, groups.ErrNameTaken) {
+			return nil, nameTaken(gr.DisplayName)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range g.Memberships {
+		if _, ok := members[m.UserID]; ok {
+			delete(members, m.UserID)
+			continue
+		}
+
+		if err := groups.RemoveMember(cfg, g, m.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, u := range members {
+		if _, err := groups.AddMember(cfg, g, u, groups.RoleMember); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	result := db.Model(&groups.Group{}).Where("id = ?", g.ID).UpdateColumn("updated_at", time.Now())
+
+	if result.Error != nil {
+		log.Printf("Unable to update Group %d: %e", g.ID, result.Error)
+		return nil, result.Error
+	}
+
+	return findGroup(cfg, g.OrganizationID, g.PublicID)
+}
+
+func writeGroup(w http.ResponseWriter, r *http.Request, status int, g *groups.Group) {
+	gr := NewGroupResource(g, !excludes(r, "members"))
+
+	w.Header().Set("ETag", gr.Meta.Version)
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", gr.Meta.Location)
+	}
+
+	writeJSON(w, status, gr)
+}
+
+func NewGetGroupsHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+
+		cs, err := parseFilter(qs.Get("filter"))
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		p := parsePage(cfg, qs)
+		members := !excludes(r, "members")
+
+		gs, total, err := listGroups(cfg, security.RequestTenant(r), cs, p, members)
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		resources := make([]interface{}, 0, len(gs))
+
+		for i := range gs {
+			resources = append(resources, NewGroupResource(&gs[i], members))
+		}
+
+		writeJSON(w, http.StatusOK, newListPayload(total, p, resources))
+	}
+}
+
+func NewGetGroupHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := findGroup(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		writeGroup(w, r, http.StatusOK, g)
+	}
+}
+
+func NewPostGroupHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var gr GroupResource
+
+		if err := decode(r, &gr); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		g, err := createGroup(cfg, security.RequestTenant(r), &gr)
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		writeGroup(w, r, http.StatusCreated, g)
+	}
+}
+
+func NewPutGroupHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := findGroup(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if err := matchVersion(r, groupVersion(g)); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		var gr GroupResource
+
+		if err := decode(r, &gr); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		g, err = replaceGroup(cfg, g, &gr)
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		writeGroup(w, r, http.StatusOK, g)
+	}
+}
+
+func NewPatchGroupHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := findGroup(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if err := matchVersion(r, groupVersion(g)); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		var req patchRequest
+
+		if err := decode(r, &req); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		gr := NewGroupResource(g, true)
+
+		if err := req.apply(gr.patch); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		g, err = replaceGroup(cfg, g, gr)
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		writeGroup(w, r, http.StatusOK, g)
+	}
+}
+
+func NewDeleteGroupHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := findGroup(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if err := matchVersion(r, groupVersion(g)); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if err := groups.Delete(cfg, g); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,162 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/groups"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/scim"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scim/groups.go", func() {
+	var (
+		rr      *httptest.ResponseRecorder
+		cfg     *config.Config
+		org     *organizations.Organization
+		members []*users.User
+		vars    map[string]string
+		result  map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+
+		owner, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+		org, _ = organizations.Create(cfg, &organizations.Organization{Name: faker.Word()}, owner)
+
+		members = nil
+
+		for i := 0; i < 2; i++ {
+			u, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+			organizations.AddMember(cfg, org.ID, u.ID, organizations.RoleMember)
+			members = append(members, u)
+		}
+
+		vars = map[string]string{}
+	})
+
+	serve := func(handler http.HandlerFunc, method, path, body string) {
+		rr = httptest.NewRecorder()
+		result = nil
+
+		r, err := http.NewRequest(method, path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(rr, security.WithTenant(mux.SetURLVars(r, vars), org.ID, organizations.RoleAdmin))
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	memberIDs := func() []string {
+		ids := []string{}
+
+		for _, m := range result["members"].([]interface{}) {
+			ids = append(ids, m.(map[string]interface{})["value"].(string))
+		}
+
+		return ids
+	}
+
+	Describe("NewPostGroupHandler()", func() {
+		It("creates the group with its members", func() {
+			serve(scim.NewPostGroupHandler(cfg), "POST", "/scim/v2/Groups", `{
+				"schemas": ["`+scim.SchemaGroup+`"],
+				"displayName": "Crew",
+				"members": [{"value": "`+members[0].PublicID+`"}]
+			}`)
+
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			Expect(memberIDs()).To(Equal([]string{members[0].PublicID}))
+
+			g, err := groups.Find(cfg, org.ID, result["id"].(string))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(g.Memberships).To(HaveLen(1))
+		})
+
+		It("refuses members from other tenants", func() {
+			stranger, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+
+			serve(scim.NewPostGroupHandler(cfg), "POST", "/scim/v2/Groups", `{
+				"displayName": "Crew",
+				"members": [{"value": "`+stranger.PublicID+`"}]
+			}`)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			Expect(result["scimType"]).To(Equal(scim.TypeInvalidValue))
+		})
+
+		It("refuses names in use", func() {
+			groups.Create(cfg, org.ID, &groups.Group{Name: "Crew"}, nil)
+
+			serve(scim.NewPostGroupHandler(cfg), "POST", "/scim/v2/Groups", `{"displayName": "Crew"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusConflict))
+		})
+	})
+
+	Describe("NewPatchGroupHandler()", func() {
+		var g *groups.Group
+
+		BeforeEach(func() {
+			g, _ = groups.Create(cfg, org.ID, &groups.Group{Name: "Crew"}, members[0])
+			vars["id"] = g.PublicID
+		})
+
+		It("changes the members", func() {
+			serve(scim.NewPatchGroupHandler(cfg), "PATCH", "/scim/v2/Groups/"+g.PublicID, `{
+				"schemas": ["`+scim.SchemaPatchOp+`"],
+				"Operations": [
+					{"op": "add", "path": "members", "value": [{"value": "`+members[1].PublicID+`"}]},
+					{"op": "remove", "path": "members[value eq \"`+members[0].PublicID+`\"]"},
+					{"op": "replace", "path": "displayName", "value": "Bridge"}
+				]
+			}`)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["displayName"]).To(Equal("Bridge"))
+			Expect(memberIDs()).To(Equal([]string{members[1].PublicID}))
+		})
+	})
+
+	Describe("NewGetGroupsHandler()", func() {
+		BeforeEach(func() {
+			groups.Create(cfg, org.ID, &groups.Group{Name: "Crew"}, members[0])
+			groups.Create(cfg, org.ID, &groups.Group{Name: "Passengers"}, nil)
+		})
+
+		It("filters by name", func() {
+			serve(scim.NewGetGroupsHandler(cfg), "GET", "/scim/v2/Groups?filter=displayName+eq+%22crew%22", "")
+
+			Expect(result["totalResults"]).To(BeNumerically("==", 1))
+		})
+
+		It("leaves out members when asked to", func() {
+			serve(scim.NewGetGroupsHandler(cfg), "GET", "/scim/v2/Groups?excludedAttributes=members", "")
+
+			Expect(result["Resources"]).To(HaveLen(2))
+			Expect(result["Resources"].([]interface{})[0]).NotTo(HaveKey("members"))
+		})
+	})
+
+	Describe("NewDeleteGroupHandler()", func() {
+		It("deletes the group", func() {
+			g, _ := groups.Create(cfg, org.ID, &groups.Group{Name: "Crew"}, members[0])
+			vars["id"] = g.PublicID
+
+			serve(scim.NewDeleteGroupHandler(cfg), "DELETE", "/scim/v2/Groups/"+g.PublicID, "")
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+
+			_, err := groups.Find(cfg, org.ID, g.PublicID)
+			Expect(err).To(Equal(groups.ErrNotFound))
+		})
+	})
+})
@@ -0,0 +1,323 @@
+package scim
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The operations of a PATCH request.
+const (
+	opAdd     = "add"
+	opReplace = "replace"
+	opRemove  = "remove"
+)
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+// ignoredKeys are the attributes of a resource given as a PATCH value that
+// clients cannot change and that are skipped rather than refused.
+var ignoredKeys = map[string]bool{"schemas": true, "id": true, "meta": true}
+
+// apply checks the request and passes its operations, with their op
+// lowercased, to fn in order.  An operation without a path has an object
+// value; each of its attributes is passed on as an operation of its own.
+func (req *patchRequest) apply(fn func(op patchOperation) error) error {
+	found := false
+
+	for _, s := range req.Schemas {
+		found = found || s == SchemaPatchOp
+	}
+
+	if !found {
+		return invalid(TypeInvalidSyntax, "PATCH requests must use the %s schema", SchemaPatchOp)
+	}
+
+	for _, op := range req.Operations {
+		op.Op = strings.ToLower(op.Op)
+
+		if op.Op != opAdd && op.Op != opReplace && op.Op != opRemove {
+			return invalid(TypeInvalidSyntax, "Unknown operation '%s'", op.Op)
+		}
+
+		if op.Path != "" {
+			if err := fn(op); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if op.Op == opRemove {
+			return invalid(TypeNoTarget, "remove operations need a path")
+		}
+
+		var values map[string]json.RawMessage
+
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return invalid(TypeInvalidValue, "Operations without a path need an object value")
+		}
+
+		keys := make([]string, 0, len(values))
+
+		for k := range values {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if ignoredKeys[strings.ToLower(k)] {
+				continue
+			}
+
+			if err := fn(patchOperation{Op: op.Op, Path: k, Value: values[k]}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitPath breaks a path such as emails[type eq "work"].value into its
+// attribute, value filter and sub-attribute, lowercasing the names.  A
+// schema URN prefix is dropped.
+func splitPath(path string) (attr, filter, sub string) {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		open := strings.Index(path, "[")
+
+		if open < 0 {
+			open = len(path)
+		}
+
+		path = path[strings.LastIndex(path[:open], ":")+1:]
+	}
+
+	attr = path
+
+	if open := strings.Index(path, "["); open >= 0 {
+		attr = path[:open]
+		rest := path[open+1:]
+
+		if end := strings.LastIndex(rest, "]"); end >= 0 {
+			filter = rest[:end]
+			sub = strings.TrimPrefix(rest[end+1:], ".")
+		}
+	}
+
+	return strings.ToLower(attr), filter, strings.ToLower(sub)
+}
+
+func setString(op patchOperation, name string, s *string) error {
+	if op.Op == opRemove {
+		*s = ""
+		return nil
+	}
+
+	if err := json.Unmarshal(op.Value, s); err != nil {
+		return invalid(TypeInvalidValue, "%s must be a string", name)
+	}
+
+	return nil
+}
+
+// setBool also accepts booleans sent as strings, as some clients do.
+func setBool(op patchOperation, name string, b **bool) error {
+	var v bool
+
+	if err := json.Unmarshal(op.Value, &v); err != nil {
+		var s string
+
+		if json.Unmarshal(op.Value, &s) != nil {
+			return invalid(TypeInvalidValue, "%s must be a boolean", name)
+		}
+
+		if v, err = strconv.ParseBool(s); err != nil {
+			return invalid(TypeInvalidValue, "%s must be a boolean", name)
+		}
+	}
+
+	*b = &v
+
+	return nil
+}
+
+func required(name string) *Error {
+	return invalid(TypeMutability, "%s is required and cannot be removed", name)
+}
+
+// patch applies one operation to the user.
+func (ur *UserResource) patch(op patchOperation) error {
+	attr, filter, sub := splitPath(op.Path)
+
+	if ur.Name == nil {
+		ur.Name = &Name{}
+	}
+
+	path := attr
+
+	if sub != "" {
+		path += "." + sub
+	}
+
+	switch path {
+	case "username":
+		if op.Op == opRemove {
+			return required("userName")
+		}
+
+		return setString(op, "userName", &ur.UserName)
+	case "externalid":
+		return setString(op, "externalId", &ur.ExternalID)
+	case "name":
+		if op.Op == opRemove {
+			ur.Name = &Name{}
+			return nil
+		}
+
+		if err := json.Unmarshal(op.Value, ur.Name); err != nil {
+			return invalid(TypeInvalidValue, "name must be an object")
+		}
+
+		return nil
+	case "name.givenname":
+		return setString(op, "name.givenName", &ur.Name.GivenName)
+	case "name.familyname":
+		return setString(op, "name.familyName", &ur.Name.FamilyName)
+	case "name.formatted", "displayname":
+		// Both are derived from the given and family names.
+		return nil
+	case "active":
+		if op.Op == opRemove {
+			return required("active")
+		}
+
+		return setBool(op, "active", &ur.Active)
+	case "password":
+		if op.Op == opRemove {
+			return required("password")
+		}
+
+		return setString(op, "password", &ur.Password)
+	case "emails", "emails.value":
+		if op.Op == opRemove {
+			return required("emails")
+		}
+
+		// A user has one email, their userName, so any filter selects it.
+		if filter != "" || sub == "value" {
+			return setString(op, "emails.value", &ur.UserName)
+		}
+
+		var es []Email
+
+		if err := json.Unmarshal(op.Value, &es); err != nil || len(es) == 0 {
+			return invalid(TypeInvalidValue, "emails must be a list of emails")
+		}
+
+		ur.UserName = es[0].Value
+
+		for _, e := range es {
+			if e.Primary {
+				ur.UserName = e.Value
+			}
+		}
+
+		return nil
+	}
+
+	return invalid(TypeInvalidPath, "'%s' is not a path to a user attribute that can be changed", op.Path)
+}
+
+// patch applies one operation to the group.
+func (gr *GroupResource) patch(op patchOperation) error {
+	attr, filter, _ := splitPath(op.Path)
+
+	switch attr {
+	case "displayname":
+		if op.Op == opRemove {
+			return required("displayName")
+		}
+
+		return setString(op, "displayName", &gr.DisplayName)
+	case "externalid":
+		// Groups are not correlated with the client's.
+		return nil
+	case "members":
+		return gr.patchMembers(op, filter)
+	}
+
+	return invalid(TypeInvalidPath, "'%s' is not a path to a group attribute that can be changed", op.Path)
+}
+
+func (gr *GroupResource) patchMembers(op patchOperation, filter string) error {
+	if op.Op == opRemove {
+		if filter == "" {
+			gr.Members = nil
+			return nil
+		}
+
+		cs, err := parseFilter(filter)
+
+		if err != nil {
+			return err
+		}
+
+		if len(cs) != 1 || cs[0].attr != "value" || cs[0].op != "eq" {
+			return invalid(TypeInvalidFilter, "Members can only be selected with value eq")
+		}
+
+		id, _ := cs[0].value.(string)
+		kept := []Member{}
+
+		for _, m := range gr.Members {
+			if m.Value != id {
+				kept = append(kept, m)
+			}
+		}
+
+		gr.Members = kept
+
+		return nil
+	}
+
+	if filter != "" {
+		return invalid(TypeInvalidPath, "Members can only be added or replaced as a whole")
+	}
+
+	var ms []Member
+
+	if err := json.Unmarshal(op.Value, &ms); err != nil {
+		return invalid(TypeInvalidValue, "members must be a list of members")
+	}
+
+	if op.Op == opReplace {
+		gr.Members = ms
+		return nil
+	}
+
+	for _, m := range ms {
+		found := false
+
+		for _, existing := range gr.Members {
+			found = found || existing.Value == m.Value
+		}
+
+		if !found {
+			gr.Members = append(gr.Members, m)
+		}
+	}
+
+	return nil
+}
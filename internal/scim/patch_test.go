@@ -0,0 +1,104 @@
+package scim
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scim/patch.go", func() {
+	var (
+		ur  *UserResource
+		req patchRequest
+	)
+
+	BeforeEach(func() {
+		ur = &UserResource{UserName: "arthur@example.com", Name: &Name{GivenName: "Arthur", FamilyName: "Dent"}}
+		req = patchRequest{}
+	})
+
+	apply := func(body string) error {
+		Expect(json.Unmarshal([]byte(body), &req)).To(Succeed())
+
+		return req.apply(ur.patch)
+	}
+
+	Describe("splitPath()", func() {
+		It("separates the value filter and sub-attribute", func() {
+			attr, filter, sub := splitPath(`emails[type eq "work"].value`)
+
+			Expect(attr).To(Equal("emails"))
+			Expect(filter).To(Equal(`type eq "work"`))
+			Expect(sub).To(Equal("value"))
+		})
+
+		It("drops schema prefixes", func() {
+			attr, _, _ := splitPath(SchemaUser + ":userName")
+			Expect(attr).To(Equal("username"))
+		})
+	})
+
+	Describe("UserResource.patch()", func() {
+		It("applies operations by path", func() {
+			Expect(apply(`{
+				"schemas": ["` + SchemaPatchOp + `"],
+				"Operations": [
+					{"op": "Replace", "path": "name.givenName", "value": "Ford"},
+					{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "ford@example.com"},
+					{"op": "remove", "path": "name.familyName"}
+				]
+			}`)).To(Succeed())
+
+			Expect(ur.Name.GivenName).To(Equal("Ford"))
+			Expect(ur.Name.FamilyName).To(BeEmpty())
+			Expect(ur.UserName).To(Equal("ford@example.com"))
+		})
+
+		It("applies operations without a path attribute by attribute", func() {
+			Expect(apply(`{
+				"schemas": ["` + SchemaPatchOp + `"],
+				"Operations": [{"op": "replace", "value": {"active": "False", "externalId": "e-42"}}]
+			}`)).To(Succeed())
+
+			Expect(*ur.Active).To(BeFalse())
+			Expect(ur.ExternalID).To(Equal("e-42"))
+		})
+
+		It("refuses to remove the userName", func() {
+			err := apply(`{"schemas": ["` + SchemaPatchOp + `"], "Operations": [{"op": "remove", "path": "userName"}]}`)
+			Expect(err.(*Error).ScimType).To(Equal(TypeMutability))
+		})
+
+		It("refuses unknown paths", func() {
+			err := apply(`{"schemas": ["` + SchemaPatchOp + `"], "Operations": [{"op": "add", "path": "nickName", "value": "Ford"}]}`)
+			Expect(err.(*Error).ScimType).To(Equal(TypeInvalidPath))
+		})
+
+		It("needs the PatchOp schema", func() {
+			err := apply(`{"Operations": [{"op": "add", "path": "externalId", "value": "e-42"}]}`)
+			Expect(err.(*Error).ScimType).To(Equal(TypeInvalidSyntax))
+		})
+	})
+
+	Describe("GroupResource.patch()", func() {
+		var gr *GroupResource
+
+		BeforeEach(func() {
+			gr = &GroupResource{DisplayName: "Crew", Members: []Member{{Value: "a"}, {Value: "b"}}}
+		})
+
+		It("adds and removes members", func() {
+			Expect(json.Unmarshal([]byte(`{
+				"schemas": ["`+SchemaPatchOp+`"],
+				"Operations": [
+					{"op": "add", "path": "members", "value": [{"value": "b"}, {"value": "c"}]},
+					{"op": "remove", "path": "members[value eq \"a\"]"}
+				]
+			}`), &req)).To(Succeed())
+
+			Expect(req.apply(gr.patch)).To(Succeed())
+			Expect(gr.Members).To(Equal([]Member{{Value: "b"}, {Value: "c"}}))
+		})
+	})
+})
@@ -0,0 +1,208 @@
+package scim
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+// MediaType is the content type of SCIM requests and responses.
+const MediaType = "application/scim+json"
+
+// BasePath is where the SCIM endpoints are served.
+const BasePath = "/scim/v2"
+
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// The scimType of errors, from RFC 7644 section 3.12.
+const (
+	TypeInvalidFilter = "invalidFilter"
+	TypeInvalidSyntax = "invalidSyntax"
+	TypeInvalidPath   = "invalidPath"
+	TypeInvalidValue  = "invalidValue"
+	TypeNoTarget      = "noTarget"
+	TypeMutability    = "mutability"
+	TypeUniqueness    = "uniqueness"
+)
+
+var ErrUnauthorized = errors.New("SCIM requests need the provisioning client's bearer token")
+
+// Error is a failure reported to the client as a SCIM error response.
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+func invalid(scimType, format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+type errorPayload struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+	Version      string     `json:"version,omitempty"`
+}
+
+type listPayload struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int64         `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// Enabled reports whether a provisioning client has been configured.
+func Enabled(cfg *config.Config) bool {
+	return cfg.GetString("scim.token") != ""
+}
+
+// Authorize checks that the request carries the provisioning client's
+// bearer token.
+func Authorize(cfg *config.Config, r *http.Request) error {
+	token := strings.TrimSpace(r.Header.Get("Authorization"))
+
+	if len(token) < 7 || !strings.EqualFold(token[:7], "Bearer ") {
+		return ErrUnauthorized
+	}
+
+	expected := cfg.GetString("scim.token")
+
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token[7:])), []byte(expected)) != 1 {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var data bytes.Buffer
+
+	err := json.NewEncoder(&data).Encode(v)
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(status)
+
+	_, err = w.Write(data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+	}
+}
+
+// WriteError responds with the error in SCIM's format; errors other than
+// *Error are internal.
+func WriteError(w http.ResponseWriter, err error) {
+	var se *Error
+
+	if !errors.As(err, &se) {
+		log.Printf("Unable to handle SCIM request: %e", err)
+		se = &Error{Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	writeJSON(w, se.Status, &errorPayload{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(se.Status),
+		ScimType: se.ScimType,
+		Detail:   se.Detail,
+	})
+}
+
+// decode reads a request body into v.
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return invalid(TypeInvalidSyntax, "Unable to parse request: %s", err)
+	}
+
+	return nil
+}
+
+// page is the window of a list request, with a 1-based start index.
+type page struct {
+	startIndex int
+	count      int
+}
+
+// parsePage reads startIndex and count, clamping them to what is allowed as
+// RFC 7644 section 3.4.2.4 asks rather than refusing them.
+func parsePage(cfg *config.Config, qs url.Values) page {
+	p := page{startIndex: 1, count: cfg.GetInt("pagination.defaultLimit")}
+
+	if v, err := strconv.Atoi(qs.Get("startIndex")); err == nil && v > 1 {
+		p.startIndex = v
+	}
+
+	if v, err := strconv.Atoi(qs.Get("count")); err == nil {
+		p.count = v
+	}
+
+	if p.count < 0 {
+		p.count = 0
+	}
+
+	if max := cfg.GetInt("pagination.maxLimit"); p.count > max {
+		p.count = max
+	}
+
+	return p
+}
+
+func newListPayload(total int64, p page, resources []interface{}) *listPayload {
+	return &listPayload{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   p.startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// excludes reports whether the request's excludedAttributes name the
+// attribute.
+func excludes(r *http.Request, attr string) bool {
+	for _, a := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(a), attr) {
+			return true
+		}
+	}
+
+	return false
+}
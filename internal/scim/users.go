@@ -0,0 +1,609 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/privacy"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// UserResource is a user as SCIM represents it.  Its userName is the user's
+// email, and a user who is not active has had their account closed.
+type UserResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Password    string   `json:"password,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// userFields holds the parts of a UserResource that are validated.
+type userFields struct {
+	UserName   string `json:"userName" validate:"required,email,max=254"`
+	Password   string `json:"password" validate:"raw,min=8,max=72"`
+	GivenName  string `json:"name.givenName" validate:"max=100"`
+	FamilyName string `json:"name.familyName" validate:"max=100"`
+	ExternalID string `json:"externalId" validate:"max=254"`
+}
+
+// userAttributes are the user attributes a filter can compare.
+var userAttributes = map[string]attribute{
+	"id":                {column: "users.public_id", kind: kindString, caseExact: true},
+	"externalid":        {column: "users.external_id", kind: kindString, caseExact: true},
+	"username":          {column: "users.email", kind: kindString},
+	"emails":            {column: "users.email", kind: kindString},
+	"emails.value":      {column: "users.email", kind: kindString},
+	"name.givenname":    {column: "users.first_name", kind: kindString},
+	"name.familyname":   {column: "users.last_name", kind: kindString},
+	"active":            {column: "users.deleted_at", kind: kindActive},
+	"meta.created":      {column: "users.created_at", kind: kindDateTime},
+	"meta.lastmodified": {column: "users.updated_at", kind: kindDateTime},
+}
+
+func userLocation(id string) string {
+	return BasePath + "/Users/" + id
+}
+
+// userVersion is the weak entity tag of a user, built from its version.
+func userVersion(u *users.User) string {
+	return fmt.Sprintf(`W/"%d"`, u.Version)
+}
+
+func NewUserResource(u *users.User) *UserResource {
+	active := !u.DeletedAt.Valid
+	created := u.CreatedAt
+	modified := u.UpdatedAt
+
+	ur := &UserResource{
+		Schemas:    []string{SchemaUser},
+		ID:         u.PublicID,
+		ExternalID: u.ExternalID,
+		UserName:   u.Email,
+		Emails:     []Email{{Value: u.Email, Type: "work", Primary: true}},
+		Active:     &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      &created,
+			LastModified: &modified,
+			Location:     userLocation(u.PublicID),
+			Version:      userVersion(u),
+		},
+	}
+
+	if u.FirstName != "" || u.LastName != "" {
+		ur.DisplayName = strings.TrimSpace(u.FirstName + " " + u.LastName)
+		ur.Name = &Name{
+			Formatted:  ur.DisplayName,
+			GivenName:  u.FirstName,
+			FamilyName: u.LastName,
+		}
+	}
+
+	return ur
+}
+
+// normalize fills in userName from the primary email when it is missing and
+// validates the resource.
+func (ur *UserResource) normalize() error {
+	if ur.UserName == "" {
+		for _, e := range ur.Emails {
+			if e.Primary || ur.UserName == "" {
+				ur.UserName = e.Value
+			}
+		}
+	}
+
+	if ur.Name == nil {
+		ur.Name = &Name{}
+	}
+
+	f := userFields{
+		UserName:   ur.UserName,
+		Password:   ur.Password,
+		GivenName:  ur.Name.GivenName,
+		FamilyName: ur.Name.FamilyName,
+		ExternalID: ur.ExternalID,
+	}
+
+	if errs := validation.Validate(&f); errs != nil {
+		return invalid(TypeInvalidValue, "%s", errs.Error())
+	}
+
+	ur.UserName = f.UserName
+	ur.Name.GivenName = f.GivenName
+	ur.Name.FamilyName = f.FamilyName
+	ur.ExternalID = f.ExternalID
+
+	return nil
+}
+
+// userQuery selects the tenant's users, including closed accounts but not
+// those waiting to be erased: once deleted through SCIM a user is gone.
+func userQuery(db *gorm.DB, tenant uint) *gorm.DB {
+	query := db.Unscoped().
+		Model(&users.User{}).
+		Where("users.id NOT IN (SELECT user_id FROM erasures WHERE erased_at IS NULL AND deleted_at IS NULL)")
+
+	return users.TenantScope(query, tenant)
+}
+
+func findUser(cfg *config.Config, tenant uint, id string) (*users.User, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var u users.User
+
+	result := userQuery(db.DB, tenant).Where("users.public_id = ?", id).Limit(1).Find(&u)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, &Error{Status: http.StatusNotFound, Detail: fmt.Sprintf("User %s not found", id)}
+	}
+
+	return &u, nil
+}
+
+func listUsers(cfg *config.Config, tenant uint, cs []comparison, p page) ([]users.User, int64, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, 0, err
+	}
+
+	query, err := applyFilter(userQuery(db.DB, tenant), cs, userAttributes)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	us := []users.User{}
+
+	if p.count == 0 {
+		return us, total, nil
+	}
+
+	result := query.Order("users.id ASC").Offset(p.startIndex - 1).Limit(p.count).Find(&us)
+
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return us, total, nil
+}
+
+func checkUserName(tx *gorm.DB, email string, except uint) error {
+	var count int64
+
+	result := tx.Unscoped().
+		Model(&users.User{}).
+		Where("email = ? AND id <> ?", email, except).
+		Count(&count)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if count > 0 {
+		return &Error{
+			Status:   http.StatusConflict,
+			ScimType: TypeUniqueness,
+			Detail:   fmt.Sprintf("userName '%s' is already in use", email),
+		}
+	}
+
+	return nil
+}
+
+// setActive closes or reopens the user's account.  Closing it revokes the
+// user's tokens, as closing it through the API does.  It is only called as
+// part of a write that already bumps the user's version.
+func setActive(tx *gorm.DB, u *users.User, active bool) error {
+	if active == !u.DeletedAt.Valid {
+		return nil
+	}
+
+	if !active {
+		if err := tx.Delete(&users.User{}, u.ID).Error; err != nil {
+			return err
+		}
+
+		return security.RevokeSubjectTx(tx, u.PublicID)
+	}
+
+	return tx.Unscoped().Model(&users.User{}).Where("id = ?", u.ID).UpdateColumn("deleted_at", nil).Error
+}
+
+func reload(tx *gorm.DB, id uint) (*users.User, error) {
+	var u users.User
+
+	if err := tx.Unscoped().Where("id = ?", id).Limit(1).Find(&u).Error; err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// createUser provisions a user in the tenant.  Users provisioned without a
+// password are given a random one nobody is told, so they cannot log in until
+// the provisioning client sets one with a PUT or PATCH.
+func createUser(cfg *config.Config, tenant uint, ur *UserResource) (*users.User, error) {
+	if err := ur.normalize(); err != nil {
+		return nil, err
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	u := &users.User{
+		Email:               ur.UserName,
+		UnencryptedPassword: ur.Password,
+		FirstName:           ur.Name.GivenName,
+		LastName:            ur.Name.FamilyName,
+		ExternalID:          ur.ExternalID,
+	}
+
+	if u.UnencryptedPassword == "" {
+		if u.UnencryptedPassword, err = security.RandomToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := checkUserName(tx, u.Email, 0); err != nil {
+			return err
+		}
+
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+
+		if tenant != 0 {
+			m := &organizations.Membership{
+				OrganizationID: tenant,
+				UserID:         u.ID,
+				Role:           organizations.RoleMember,
+			}
+
+			if err := tx.Omit("Organization").Create(m).Error; err != nil {
+				return err
+			}
+		}
+
+		if ur.Active != nil {
+			if err := setActive(tx, u, *ur.Active); err != nil {
+				return err
+			}
+		}
+
+		if err := users.RecordRevision(tx, u.ID, users.ActorSCIM); err != nil {
+			return err
+		}
+
+		u, err = reload(tx, u.ID)
+
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Unable to provision User record: %e", err)
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// replaceUser overwrites the user with the resource.  Attributes the resource
+// leaves out are cleared, apart from the password, which is only changed when
+// given, and active, which is only changed when set.
+func replaceUser(cfg *config.Config, u *users.User, ur *UserResource) (*users.User, error) {
+	if err := ur.normalize(); err != nil {
+		return nil, err
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	columns := users.Changed(map[string]interface{}{
+		"email":       ur.UserName,
+		"first_name":  ur.Name.GivenName,
+		"last_name":   ur.Name.FamilyName,
+		"external_id": ur.ExternalID,
+	})
+
+	if ur.Password != "" {
+		columns["unencrypted_password"] = ur.Password
+	}
+
+	var updated *users.User
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := checkUserName(tx, ur.UserName, u.ID); err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().
+			Model(&users.User{}).
+			Where("id = ? AND version = ?", u.ID, u.Version).
+			UpdateColumns(columns)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return users.ErrVersionMismatch
+		}
+
+		if ur.Active != nil {
+			if err := setActive(tx, u, *ur.Active); err != nil {
+				return err
+			}
+		}
+
+		if ur.UserName != u.Email {
+			for _, subj := range []string{u.PublicID, u.Email} {
+				if err := security.RevokeSubjectTx(tx, subj); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := users.RecordRevision(tx, u.ID, users.ActorSCIM); err != nil {
+			return err
+		}
+
+		updated, err = reload(tx, u.ID)
+
+		return err
+	})
+
+	if errors.Is(err, users.ErrVersionMismatch) {
+		return nil, &Error{Status: http.StatusPreconditionFailed, Detail: "The user has changed since it was read"}
+	}
+
+	if err != nil {
+		log.Printf("Unable to replace User %d: %e", u.ID, err)
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// matchVersion checks the request's If-Match header, if any, against the
+// user's version.
+func matchVersion(r *http.Request, version string) error {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(version, "W/") {
+			return nil
+		}
+	}
+
+	return &Error{Status: http.StatusPreconditionFailed, Detail: "The resource has changed since it was read"}
+}
+
+func writeUser(w http.ResponseWriter, status int, u *users.User) {
+	ur := NewUserResource(u)
+
+	w.Header().Set("ETag", ur.Meta.Version)
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", ur.Meta.Location)
+	}
+
+	writeJSON(w, status, ur)
+}
+
+func NewGetUsersHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+
+		cs, err := parseFilter(qs.Get("filter"))
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		p := parsePage(cfg, qs)
+
+		us, total, err := listUsers(cfg, security.RequestTenant(r), cs, p)
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		resources := make([]interface{}, 0, len(us))
+
+		for i := range us {
+			resources = append(resources, NewUserResource(&us[i]))
+		}
+
+		writeJSON(w, http.StatusOK, newListPayload(total, p, resources))
+	}
+}
+
+func NewGetUserHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := findUser(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		writeUser(w, http.StatusOK, u)
+	}
+}
+
+func NewPostUserHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ur UserResource
+
+		if err := decode(r, &ur); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		u, err := createUser(cfg, security.RequestTenant(r), &ur)
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		writeUser(w, http.StatusCreated, u)
+	}
+}
+
+func NewPutUserHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := findUser(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if err := matchVersion(r, userVersion(u)); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		var ur UserResource
+
+		if err := decode(r, &ur); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		u, err = replaceUser(cfg, u, &ur)
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		writeUser(w, http.StatusOK, u)
+	}
+}
+
+func NewPatchUserHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := findUser(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if err := matchVersion(r, userVersion(u)); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		var req patchRequest
+
+		if err := decode(r, &req); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		ur := NewUserResource(u)
+
+		if err := req.apply(ur.patch); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		u, err = replaceUser(cfg, u, ur)
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		writeUser(w, http.StatusOK, u)
+	}
+}
+
+// NewDeleteUserHandler closes the user's account and schedules the erasure of
+// their data, as the user could themselves.
+func NewDeleteUserHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := findUser(cfg, security.RequestTenant(r), mux.Vars(r)["id"])
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if err := matchVersion(r, userVersion(u)); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if _, err := privacy.Close(cfg, u, true); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,229 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/scim"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scim/users.go", func() {
+	var (
+		rr     *httptest.ResponseRecorder
+		cfg    *config.Config
+		org    *organizations.Organization
+		vars   map[string]string
+		result map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+
+		owner, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+		org, _ = organizations.Create(cfg, &organizations.Organization{Name: faker.Word()}, owner)
+
+		vars = map[string]string{}
+	})
+
+	serve := func(handler http.HandlerFunc, method, path, body string) {
+		rr = httptest.NewRecorder()
+		result = nil
+
+		r, err := http.NewRequest(method, path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(rr, security.WithTenant(mux.SetURLVars(r, vars), org.ID, organizations.RoleAdmin))
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	provision := func(email string) string {
+		serve(scim.NewPostUserHandler(cfg), "POST", "/scim/v2/Users", `{
+			"schemas": ["`+scim.SchemaUser+`"],
+			"userName": "`+email+`",
+			"externalId": "hr-`+email+`",
+			"name": {"givenName": "Arthur", "familyName": "Dent"}
+		}`)
+
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+
+		return result["id"].(string)
+	}
+
+	Describe("NewPostUserHandler()", func() {
+		It("provisions the user in the tenant", func() {
+			email := faker.Email()
+			id := provision(email)
+
+			Expect(rr.Header().Get("Location")).To(Equal("/scim/v2/Users/" + id))
+			Expect(rr.Header().Get("Content-Type")).To(Equal(scim.MediaType))
+			Expect(result["userName"]).To(Equal(email))
+			Expect(result["active"]).To(BeTrue())
+			Expect(result).NotTo(HaveKey("password"))
+
+			u, _ := users.FindByPublicID(cfg, id)
+			Expect(u.UnencryptedPassword).NotTo(BeEmpty())
+
+			ok, _ := users.InTenant(cfg, u.ID, org.ID)
+			Expect(ok).To(BeTrue())
+		})
+
+		It("refuses a userName in use", func() {
+			email := faker.Email()
+			users.Create(cfg, &users.User{Email: email})
+
+			serve(scim.NewPostUserHandler(cfg), "POST", "/scim/v2/Users", `{"userName": "`+email+`"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusConflict))
+			Expect(result["scimType"]).To(Equal(scim.TypeUniqueness))
+		})
+
+		It("refuses invalid users", func() {
+			serve(scim.NewPostUserHandler(cfg), "POST", "/scim/v2/Users", `{"userName": "arthur"}`)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			Expect(result["scimType"]).To(Equal(scim.TypeInvalidValue))
+		})
+	})
+
+	Describe("NewGetUsersHandler()", func() {
+		var email string
+
+		BeforeEach(func() {
+			email = faker.Email()
+			provision(email)
+			provision(faker.Email())
+		})
+
+		It("lists the tenant's users a page at a time", func() {
+			serve(scim.NewGetUsersHandler(cfg), "GET", "/scim/v2/Users?startIndex=2&count=1", "")
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["totalResults"]).To(BeNumerically("==", 3))
+			Expect(result["startIndex"]).To(BeNumerically("==", 2))
+			Expect(result["Resources"]).To(HaveLen(1))
+		})
+
+		It("filters them", func() {
+			serve(scim.NewGetUsersHandler(cfg), "GET", "/scim/v2/Users?filter="+`userName+eq+"`+strings.ToUpper(email)+`"`, "")
+
+			Expect(result["totalResults"]).To(BeNumerically("==", 1))
+			Expect(result["Resources"].([]interface{})[0].(map[string]interface{})["userName"]).To(Equal(email))
+		})
+
+		It("refuses filters it does not support", func() {
+			serve(scim.NewGetUsersHandler(cfg), "GET", "/scim/v2/Users?filter=nickName+eq+%22ford%22", "")
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			Expect(result["scimType"]).To(Equal(scim.TypeInvalidFilter))
+		})
+	})
+
+	Describe("NewGetUserHandler()", func() {
+		It("hides users of other tenants", func() {
+			other, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+			vars["id"] = other.PublicID
+
+			serve(scim.NewGetUserHandler(cfg), "GET", "/scim/v2/Users/"+other.PublicID, "")
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+			Expect(result["schemas"]).To(ContainElement(scim.SchemaError))
+		})
+	})
+
+	Describe("NewPatchUserHandler()", func() {
+		var id string
+
+		BeforeEach(func() {
+			id = provision(faker.Email())
+			vars["id"] = id
+		})
+
+		It("deactivates and reactivates the user", func() {
+			serve(scim.NewPatchUserHandler(cfg), "PATCH", "/scim/v2/Users/"+id, `{
+				"schemas": ["`+scim.SchemaPatchOp+`"],
+				"Operations": [{"op": "replace", "path": "active", "value": false}]
+			}`)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["active"]).To(BeFalse())
+
+			_, err := users.FindByPublicID(cfg, id)
+			Expect(err).To(Equal(users.ErrNotFound))
+
+			serve(scim.NewPatchUserHandler(cfg), "PATCH", "/scim/v2/Users/"+id, `{
+				"schemas": ["`+scim.SchemaPatchOp+`"],
+				"Operations": [{"op": "replace", "value": {"active": true}}]
+			}`)
+
+			Expect(result["active"]).To(BeTrue())
+
+			_, err = users.FindByPublicID(cfg, id)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("records the revision", func() {
+			serve(scim.NewPatchUserHandler(cfg), "PATCH", "/scim/v2/Users/"+id, `{
+				"schemas": ["`+scim.SchemaPatchOp+`"],
+				"Operations": [{"op": "replace", "path": "name.givenName", "value": "Ford"}]
+			}`)
+
+			Expect(result["name"].(map[string]interface{})["givenName"]).To(Equal("Ford"))
+
+			u, _ := users.FindByPublicID(cfg, id)
+			revs, _, _ := users.History(cfg, u.ID, "", 1)
+			Expect((*revs)[0].Actor).To(Equal(users.ActorSCIM))
+			Expect((*revs)[0].ChangedFields).To(ContainSubstring("firstName"))
+		})
+
+		It("revokes the user's tokens when their userName changes", func() {
+			token, _ := security.NewTokenForSubject(cfg, id)
+
+			serve(scim.NewPatchUserHandler(cfg), "PATCH", "/scim/v2/Users/"+id, `{
+				"schemas": ["`+scim.SchemaPatchOp+`"],
+				"Operations": [{"op": "replace", "path": "userName", "value": "`+faker.Email()+`"}]
+			}`)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			ok, _ := security.IsValidToken(cfg, token)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("honors If-Match", func() {
+			rr = httptest.NewRecorder()
+
+			r, _ := http.NewRequest("PATCH", "/scim/v2/Users/"+id, strings.NewReader(`{
+				"schemas": ["`+scim.SchemaPatchOp+`"],
+				"Operations": [{"op": "replace", "path": "externalId", "value": "stale"}]
+			}`))
+			r.Header.Set("If-Match", `W/"0"`)
+
+			scim.NewPatchUserHandler(cfg)(rr, security.WithTenant(mux.SetURLVars(r, vars), org.ID, organizations.RoleAdmin))
+
+			Expect(rr.Code).To(Equal(http.StatusPreconditionFailed))
+		})
+	})
+
+	Describe("NewDeleteUserHandler()", func() {
+		It("closes the account and schedules its erasure", func() {
+			id := provision(faker.Email())
+			vars["id"] = id
+
+			serve(scim.NewDeleteUserHandler(cfg), "DELETE", "/scim/v2/Users/"+id, "")
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+
+			serve(scim.NewGetUserHandler(cfg), "GET", "/scim/v2/Users/"+id, "")
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -2,10 +2,13 @@ package security
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"time"
 
 	"github.com/adamstrickland/dapper-api/internal/config"
@@ -16,6 +19,18 @@ type TokenPayload struct {
 	Token string `json:"token"`
 }
 
+// Claims are the standard claims, plus the public ID of the organization the
+// token is acting in, if any, and of the groups its subject belongs to there.
+// IssuedAt replaces the standard claim's whole seconds with a fraction, so
+// that a token issued right after its subject's tokens were revoked is not
+// taken for one of them.
+type Claims struct {
+	jwt.StandardClaims
+	IssuedAt     float64  `json:"iat,omitempty"`
+	Organization string   `json:"org,omitempty"`
+	Groups       []string `json:"groups,omitempty"`
+}
+
 func parsedToken(cfg *config.Config, token string) (*jwt.Token, error) {
 	t, err := jwt.Parse(token, func(_ *jwt.Token) (interface{}, error) {
 		return []byte(cfg.GetString("secret")), nil
@@ -73,11 +88,82 @@ func IsValidToken(cfg *config.Config, token string) (bool, error) {
 		return false, err
 	}
 
+	if subj, ok := claims["sub"].(string); ok {
+		iat, _ := claims["iat"].(float64)
+
+		revoked, err := isRevoked(cfg, subj, iat)
+
+		if err != nil {
+			return false, err
+		}
+
+		if revoked {
+			return false, errors.New("Invalid token (token has been revoked)")
+		}
+	}
+
 	return true, nil
 }
 
+// RequestSubject returns the subject of the token presented with the request.
+func RequestSubject(cfg *config.Config, r *http.Request) (*string, error) {
+	token := r.Header.Get(cfg.GetString("tokenHeader"))
+
+	if token == "" {
+		return nil, errors.New("No token found")
+	}
+
+	return TokenSubject(cfg, token)
+}
+
+// RequestOrganization returns the organization claimed by the token presented
+// with the request, or an empty string when it claims none.
+func RequestOrganization(cfg *config.Config, r *http.Request) (string, error) {
+	token := r.Header.Get(cfg.GetString("tokenHeader"))
+
+	if token == "" {
+		return "", errors.New("No token found")
+	}
+
+	return TokenOrganization(cfg, token)
+}
+
+// TokenOrganization returns the organization claimed by the token, or an
+// empty string when it claims none.
+func TokenOrganization(cfg *config.Config, token string) (string, error) {
+	claims, err := tokenClaims(cfg, token)
+
+	if err != nil {
+		return "", err
+	}
+
+	org, _ := claims["org"].(string)
+
+	return org, nil
+}
+
+// RandomToken returns a URL-safe random string suitable for single-use codes
+// sent to users.
+func RandomToken() (string, error) {
+	b := make([]byte, 24)
+
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Unable to generate random token: %e", err)
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 func NewTokenPayload(cfg *config.Config, subj string) ([]byte, error) {
-	ts, err := NewTokenForSubject(cfg, subj)
+	return NewTokenPayloadInOrganization(cfg, subj, "")
+}
+
+// NewTokenPayloadInOrganization issues a token acting in the organization
+// with the given public ID; an empty ID acts in none.  Groups, when given,
+// are embedded as the groups claim.
+func NewTokenPayloadInOrganization(cfg *config.Config, subj, org string, groups ...string) ([]byte, error) {
+	ts, err := NewTokenForSubjectInOrganization(cfg, subj, org, groups...)
 
 	if err != nil {
 		log.Printf("Unable to generate token: %e", err)
@@ -99,7 +185,7 @@ func NewTokenPayload(cfg *config.Config, subj string) ([]byte, error) {
 	return data.Bytes(), nil
 }
 
-func newTokenWithClaims(cfg *config.Config, claims *jwt.StandardClaims) (string, error) {
+func newTokenWithClaims(cfg *config.Config, claims jwt.Claims) (string, error) {
 
 	log.Printf("Issuing authorization: '%+v'", claims)
 
@@ -117,14 +203,22 @@ func newTokenWithClaims(cfg *config.Config, claims *jwt.StandardClaims) (string,
 }
 
 func NewTokenForSubject(cfg *config.Config, subj string) (string, error) {
+	return NewTokenForSubjectInOrganization(cfg, subj, "")
+}
+
+func NewTokenForSubjectInOrganization(cfg *config.Config, subj, org string, groups ...string) (string, error) {
 	ts := time.Now()
 
-	claims := &jwt.StandardClaims{
-		Audience:  "dapper-client",
-		ExpiresAt: ts.Add(time.Hour * 24).Unix(),
-		Issuer:    "dapper-api",
-		IssuedAt:  ts.Unix(),
-		Subject:   subj,
+	claims := &Claims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  "dapper-client",
+			ExpiresAt: ts.Add(time.Hour * 24).Unix(),
+			Issuer:    "dapper-api",
+			Subject:   subj,
+		},
+		IssuedAt:     float64(ts.UnixMicro()) / 1e6,
+		Organization: org,
+		Groups:       groups,
 	}
 
 	return newTokenWithClaims(cfg, claims)
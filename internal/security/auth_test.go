@@ -1,6 +1,8 @@
 package security
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"time"
 
 	"github.com/adamstrickland/dapper-api/internal/config"
@@ -104,4 +106,48 @@ var _ = Describe("security/auth.go", func() {
 			})
 		})
 	})
+
+	Describe("RequestOrganization", func() {
+		var r *http.Request
+
+		BeforeEach(func() {
+			r = httptest.NewRequest(http.MethodGet, "/", nil)
+		})
+
+		It("returns the organization the token acts in", func() {
+			token, _ := NewTokenForSubjectInOrganization(cfg, faker.Email(), "01ARZ3NDEKTSV4RRFFQ69G5FAV")
+			r.Header.Set(cfg.GetString("tokenHeader"), token)
+
+			org, err := RequestOrganization(cfg, r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org).To(Equal("01ARZ3NDEKTSV4RRFFQ69G5FAV"))
+		})
+
+		It("is empty when the token acts in none", func() {
+			token, _ := NewTokenForSubject(cfg, faker.Email())
+			r.Header.Set(cfg.GetString("tokenHeader"), token)
+
+			org, err := RequestOrganization(cfg, r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org).To(BeEmpty())
+		})
+	})
+
+	Describe("TokenOrganization", func() {
+		It("returns the organization the token acts in", func() {
+			token, _ := NewTokenForSubjectInOrganization(cfg, faker.Email(), "01ARZ3NDEKTSV4RRFFQ69G5FAV")
+
+			org, err := TokenOrganization(cfg, token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org).To(Equal("01ARZ3NDEKTSV4RRFFQ69G5FAV"))
+		})
+
+		It("reports an error for a token it did not sign", func() {
+			token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{Organization: "01ARZ3NDEKTSV4RRFFQ69G5FAV"}).
+				SignedString([]byte("othersecret"))
+
+			_, err := TokenOrganization(cfg, token)
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
@@ -0,0 +1,91 @@
+package security
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"gorm.io/gorm"
+)
+
+// Revocation invalidates every token issued to Subject at or before
+// RevokedAt.
+type Revocation struct {
+	Subject   string `gorm:"primaryKey"`
+	RevokedAt time.Time
+}
+
+func RevokeSubject(cfg *config.Config, subj string) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	return RevokeSubjectTx(db.DB, subj)
+}
+
+// RevokeSubjectTx revokes the subject's tokens as part of an enclosing
+// transaction.
+func RevokeSubjectTx(tx *gorm.DB, subj string) error {
+	result := tx.Save(&Revocation{
+		Subject:   subj,
+		RevokedAt: time.Now(),
+	})
+
+	if result.Error != nil {
+		log.Printf("Unable to revoke tokens for '%s': %e", subj, result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// RevocationsFor returns the revocations of any of the subjects.
+func RevocationsFor(cfg *config.Config, subjects ...string) ([]Revocation, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var revs []Revocation
+
+	result := db.Where("subject IN ?", subjects).Order("subject ASC").Find(&revs)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return revs, nil
+}
+
+// isRevoked reports whether the subject's token issued at the given time, in
+// seconds, has been revoked.  Times are compared to the microsecond, which is
+// as precise as an iat claim holds.
+func isRevoked(cfg *config.Config, subj string, issuedAt float64) (bool, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return false, err
+	}
+
+	var rev Revocation
+
+	result := db.Where("subject = ?", subj).Limit(1).Find(&rev)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	return int64(math.Round(issuedAt*1e6)) <= rev.RevokedAt.UnixMicro(), nil
+}
@@ -0,0 +1,95 @@
+package security
+
+import (
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/bxcodec/faker/v3"
+	"github.com/golang-jwt/jwt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("security/revocations.go", func() {
+	var (
+		cfg   *config.Config
+		email string
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		email = faker.Email()
+	})
+
+	Describe("RevokeSubject()", func() {
+		var (
+			before, after string
+		)
+
+		BeforeEach(func() {
+			before, _ = newTokenWithClaims(cfg, &jwt.StandardClaims{
+				Subject:  email,
+				IssuedAt: time.Now().Add(-time.Hour).Unix(),
+			})
+
+			Expect(RevokeSubject(cfg, email)).NotTo(HaveOccurred())
+
+			db, _ := internal.NewConnection(cfg)
+			db.Model(&Revocation{Subject: email}).Update("revoked_at", time.Now().Add(-time.Minute))
+
+			after, _ = newTokenWithClaims(cfg, &jwt.StandardClaims{
+				Subject:  email,
+				IssuedAt: time.Now().Unix(),
+			})
+		})
+
+		It("invalidates tokens issued before the revocation", func() {
+			ok, err := IsValidToken(cfg, before)
+			Expect(ok).To(BeFalse())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("does not invalidate tokens issued afterwards", func() {
+			ok, err := IsValidToken(cfg, after)
+			Expect(ok).To(BeTrue())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("tells apart tokens issued within the second of the revocation", func() {
+			subj := faker.Email()
+
+			revoked, _ := NewTokenForSubject(cfg, subj)
+			Expect(RevokeSubject(cfg, subj)).NotTo(HaveOccurred())
+			reissued, _ := NewTokenForSubject(cfg, subj)
+
+			ok, _ := IsValidToken(cfg, revoked)
+			Expect(ok).To(BeFalse())
+
+			ok, err := IsValidToken(cfg, reissued)
+			Expect(ok).To(BeTrue())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not invalidate tokens for other subjects", func() {
+			other, _ := NewTokenForSubject(cfg, faker.Email())
+
+			ok, _ := IsValidToken(cfg, other)
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Describe("RevocationsFor()", func() {
+		It("returns the revocations of the subjects", func() {
+			other := faker.Email()
+
+			RevokeSubject(cfg, email)
+			RevokeSubject(cfg, faker.Email())
+
+			revs, err := RevocationsFor(cfg, email, other)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revs).To(HaveLen(1))
+			Expect(revs[0].Subject).To(Equal(email))
+		})
+	})
+})
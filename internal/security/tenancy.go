@@ -0,0 +1,36 @@
+package security
+
+import (
+	"context"
+	"net/http"
+)
+
+type tenantKey struct{}
+
+type tenant struct {
+	id   uint
+	role string
+}
+
+// WithTenant returns the request carrying the ID of the organization it acts
+// in, as resolved from its token, and the role of its subject there.  Zero is
+// the tenant of users who belong to no organization, where no one has a role.
+func WithTenant(r *http.Request, id uint, role string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant{id, role}))
+}
+
+// RequestTenant returns the ID of the organization the request acts in, or
+// zero when it acts in none.
+func RequestTenant(r *http.Request) uint {
+	t, _ := r.Context().Value(tenantKey{}).(tenant)
+
+	return t.id
+}
+
+// RequestTenantRole returns the role the request's subject has in the
+// organization it acts in, if any.
+func RequestTenantRole(r *http.Request) string {
+	t, _ := r.Context().Value(tenantKey{}).(tenant)
+
+	return t.role
+}
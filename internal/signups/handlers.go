@@ -2,26 +2,43 @@ package signups
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/adamstrickland/dapper-api/internal/config"
-	"github.com/adamstrickland/dapper-api/internal/security"
-	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/validation"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-type requestPayload struct {
-	Email     string `json:"email"`
-	Password  string `json:"password"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+const (
+	ModeOpen       = "open"
+	ModeInviteOnly = "invite_only"
+	ModeClosed     = "closed"
+)
+
+const AuditActionRejected = "signup.rejected"
+
+// reject responds with the reason a signup was refused.
+func reject(w http.ResponseWriter, status int, rej *Rejection) {
+	data, err := json.Marshal(rej)
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, rej.Message, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
 }
 
 func NewPostHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var qp requestPayload
+		var qp Request
 
 		err := json.NewDecoder(r.Body).Decode(&qp)
 
@@ -33,22 +50,26 @@ func NewPostHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Requ
 
 		log.Printf("Received payload: '%+v'", qp)
 
-		user := &users.User{
-			Email:               qp.Email,
-			UnencryptedPassword: qp.Password,
-			FirstName:           qp.FirstName,
-			LastName:            qp.LastName,
-		}
+		u, m, err := SignUp(cfg, r.RemoteAddr, &qp)
 
-		u, err := users.Create(cfg, user)
+		var (
+			errs validation.Errors
+			rej  *Rejection
+		)
 
-		if err != nil {
-			log.Printf("Unable to create User: %e", err)
+		switch {
+		case errors.As(err, &errs):
+			validation.WriteErrors(w, errs)
+			return
+		case errors.As(err, &rej):
+			reject(w, http.StatusForbidden, rej)
+			return
+		case err != nil:
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		data, err := security.NewTokenPayload(cfg, u.Email)
+		data, err := organizations.NewTokenPayload(cfg, u, m)
 
 		if err != nil {
 			log.Printf("Unable to create session: %e", err)
@@ -7,8 +7,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 
+	"github.com/adamstrickland/dapper-api/internal/audit"
 	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
 	"github.com/adamstrickland/dapper-api/internal/signups"
 	"github.com/adamstrickland/dapper-api/internal/users"
 	"github.com/bxcodec/faker/v3"
@@ -120,5 +124,132 @@ var _ = Describe("signups/handlers.go", func() {
 				})
 			})
 		})
+
+		When("signups are invite only", func() {
+			var email string
+
+			BeforeEach(func() {
+				cfg.Set("signupMode", signups.ModeInviteOnly)
+				email = faker.Email()
+			})
+
+			When("and no code is provided", func() {
+				BeforeEach(func() {
+					body = fmt.Sprintf(`{"email": "%s", "password": "p@ssw0rd"}`, email)
+				})
+
+				It("is forbidden", func() {
+					Expect(rr.Code).To(Equal(http.StatusForbidden))
+				})
+			})
+
+			When("and a valid code is provided", func() {
+				var inv *invitations.Invitation
+
+				BeforeEach(func() {
+					var err error
+
+					inv, err = invitations.Create(cfg, &invitations.Invitation{})
+					Expect(err).NotTo(HaveOccurred())
+
+					body = fmt.Sprintf(`{"email": "%s", "password": "p@ssw0rd", "inviteCode": "%s"}`, email, inv.Code)
+				})
+
+				It("is OK", func() {
+					Expect(rr.Code).To(Equal(http.StatusOK))
+				})
+
+				It("uses up the code", func() {
+					_, err := invitations.Reserve(cfg, inv.Code, faker.Email())
+					Expect(err).To(HaveOccurred())
+				})
+			})
+
+			When("and the code invites them to an organization", func() {
+				var org *organizations.Organization
+
+				BeforeEach(func() {
+					owner, _ := users.Create(cfg, &users.User{Email: faker.Email()})
+					org, _ = organizations.Create(cfg, &organizations.Organization{Name: "Heart of Gold"}, owner)
+
+					inv, err := invitations.Create(cfg, &invitations.Invitation{
+						OrganizationID: org.ID,
+						Role:           organizations.RoleMember,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					body = fmt.Sprintf(`{"email": "%s", "password": "p@ssw0rd", "inviteCode": "%s"}`, email, inv.Code)
+				})
+
+				It("makes them a member", func() {
+					Expect(rr.Code).To(Equal(http.StatusOK))
+
+					u, _ := users.FindByEmail(cfg, email)
+					_, err := organizations.FindMembership(cfg, org.PublicID, u.ID)
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+		})
+
+		When("signups are closed", func() {
+			BeforeEach(func() {
+				cfg.Set("signupMode", signups.ModeClosed)
+				body = fmt.Sprintf(`{"email": "%s", "password": "p@ssw0rd"}`, faker.Email())
+			})
+
+			It("is forbidden", func() {
+				Expect(rr.Code).To(Equal(http.StatusForbidden))
+			})
+		})
+
+		When("the email is rejected by the signup rules", func() {
+			var before int64
+
+			BeforeEach(func() {
+				before, _ = audit.Count(cfg, signups.AuditActionRejected, signups.CodeEmailDisposable)
+				body = `{"email": "ford@mailinator.com", "password": "p@ssw0rd"}`
+			})
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+
+			It("explains why", func() {
+				var p map[string]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &p)
+				Expect(p["errors"]).To(ContainElement(SatisfyAll(
+					HaveKeyWithValue("field", "email"),
+					HaveKeyWithValue("code", signups.CodeEmailDisposable),
+				)))
+			})
+
+			It("is counted in the audit trail", func() {
+				after, _ := audit.Count(cfg, signups.AuditActionRejected, signups.CodeEmailDisposable)
+				Expect(after).To(Equal(before + 1))
+			})
+		})
+
+		When("the payload fails validation", func() {
+			BeforeEach(func() {
+				body = fmt.Sprintf(`{"email": "not-an-email", "password": "short", "firstName": "%s"}`, strings.Repeat("x", 101))
+			})
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+
+			It("lists every invalid field", func() {
+				var p map[string][]map[string]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &p)
+
+				fields := make([]interface{}, 0)
+
+				for _, e := range p["errors"] {
+					fields = append(fields, e["field"])
+				}
+
+				Expect(fields).To(ConsistOf("email", "password", "firstName"))
+			})
+		})
 	})
 })
@@ -0,0 +1,210 @@
+package signups
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+const (
+	CodeEmailInvalid     = "email_invalid"
+	CodeDomainNotAllowed = "domain_not_allowed"
+	CodeDomainDenied     = "domain_denied"
+	CodeEmailDisposable  = "email_disposable"
+
+	CodeSignupsClosed      = "signups_closed"
+	CodeInvitationRequired = "invitation_required"
+	CodeInvitationInvalid  = "invitation_invalid"
+)
+
+//go:embed disposable_domains.txt
+var bundledDisposableDomains string
+
+// Rejection explains why an email may not be used to sign up.
+type Rejection struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+type domainList struct {
+	path    string
+	modTime time.Time
+	domains map[string]bool
+}
+
+var (
+	disposableMu    sync.Mutex
+	disposableCache *domainList
+)
+
+func parseDomains(r io.Reader) (map[string]bool, error) {
+	domains := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domains[strings.ToLower(line)] = true
+	}
+
+	return domains, scanner.Err()
+}
+
+// disposableDomains returns the bundled list merged with the configured local
+// file, which is re-read whenever it changes.
+func disposableDomains(cfg *config.Config) map[string]bool {
+	disposableMu.Lock()
+	defer disposableMu.Unlock()
+
+	fp := cfg.GetString("signups.disposableDomainsFile")
+
+	var modTime time.Time
+
+	if fp != "" {
+		if fi, err := os.Stat(fp); err == nil {
+			modTime = fi.ModTime()
+		} else {
+			log.Printf("Unable to read disposable domains from '%s': %e", fp, err)
+		}
+	}
+
+	if disposableCache != nil && disposableCache.path == fp && disposableCache.modTime.Equal(modTime) {
+		return disposableCache.domains
+	}
+
+	domains, _ := parseDomains(strings.NewReader(bundledDisposableDomains))
+
+	if !modTime.IsZero() {
+		if f, err := os.Open(fp); err == nil {
+			extra, err := parseDomains(f)
+			f.Close()
+
+			if err != nil {
+				log.Printf("Unable to parse disposable domains from '%s': %e", fp, err)
+			}
+
+			for d := range extra {
+				domains[d] = true
+			}
+		}
+	}
+
+	disposableCache = &domainList{
+		path:    fp,
+		modTime: modTime,
+		domains: domains,
+	}
+
+	return domains
+}
+
+func matchesAny(domain string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), domain); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isListed reports whether the domain, or any domain it is a subdomain of, is
+// in the list.
+func isListed(domain string, domains map[string]bool) bool {
+	for d := domain; d != ""; {
+		if domains[d] {
+			return true
+		}
+
+		i := strings.Index(d, ".")
+
+		if i < 0 {
+			break
+		}
+
+		d = d[i+1:]
+	}
+
+	return false
+}
+
+func checkSyntax(email string) (string, *Rejection) {
+	invalid := &Rejection{
+		Code:    CodeEmailInvalid,
+		Message: fmt.Sprintf("'%s' is not a valid email address", email),
+	}
+
+	addr, err := mail.ParseAddress(email)
+
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return "", invalid
+	}
+
+	i := strings.LastIndex(email, "@")
+	domain := strings.ToLower(email[i+1:])
+
+	if !strings.Contains(domain, ".") || strings.HasSuffix(domain, ".") || strings.Contains(domain, "..") {
+		return "", invalid
+	}
+
+	return domain, nil
+}
+
+// CheckEmail applies the configured signup rules to the email, returning why
+// it may not be used, if so.
+func CheckEmail(cfg *config.Config, email string) *Rejection {
+	var domain string
+
+	if cfg.GetBool("signups.checkSyntax") {
+		d, rej := checkSyntax(email)
+
+		if rej != nil {
+			return rej
+		}
+
+		domain = d
+	} else {
+		domain = strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+	}
+
+	if allowed := cfg.GetStringSlice("signups.allowedDomains"); len(allowed) > 0 && !matchesAny(domain, allowed) {
+		return &Rejection{
+			Code:    CodeDomainNotAllowed,
+			Message: fmt.Sprintf("Signups from '%s' are not allowed", domain),
+		}
+	}
+
+	if matchesAny(domain, cfg.GetStringSlice("signups.deniedDomains")) {
+		return &Rejection{
+			Code:    CodeDomainDenied,
+			Message: fmt.Sprintf("Signups from '%s' are not accepted", domain),
+		}
+	}
+
+	if cfg.GetBool("signups.blockDisposable") && isListed(domain, disposableDomains(cfg)) {
+		return &Rejection{
+			Code:    CodeEmailDisposable,
+			Message: "Disposable email addresses are not accepted",
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package signups
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("signups/rules.go", func() {
+	var (
+		cfg   *config.Config
+		email string
+		rej   *Rejection
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		email = "ford@example.com"
+	})
+
+	JustBeforeEach(func() {
+		rej = CheckEmail(cfg, email)
+	})
+
+	Describe("CheckEmail()", func() {
+		When("the email is acceptable", func() {
+			It("is not rejected", func() {
+				Expect(rej).To(BeNil())
+			})
+		})
+
+		When("the email is malformed", func() {
+			BeforeEach(func() {
+				email = "Ford <ford@example.com>"
+			})
+
+			It("is rejected", func() {
+				Expect(rej.Code).To(Equal(CodeEmailInvalid))
+			})
+		})
+
+		When("the domain has no TLD", func() {
+			BeforeEach(func() {
+				email = "ford@localhost"
+			})
+
+			It("is rejected", func() {
+				Expect(rej.Code).To(Equal(CodeEmailInvalid))
+			})
+		})
+
+		When("an allowlist is configured", func() {
+			BeforeEach(func() {
+				cfg.Set("signups.allowedDomains", []string{"partner.com", "*.partner.com"})
+			})
+
+			It("rejects other domains", func() {
+				Expect(rej.Code).To(Equal(CodeDomainNotAllowed))
+			})
+
+			When("and the domain matches a pattern", func() {
+				BeforeEach(func() {
+					email = "ford@eu.partner.com"
+				})
+
+				It("is not rejected", func() {
+					Expect(rej).To(BeNil())
+				})
+			})
+		})
+
+		When("the domain is denied", func() {
+			BeforeEach(func() {
+				cfg.Set("signups.deniedDomains", []string{"example.*"})
+			})
+
+			It("is rejected", func() {
+				Expect(rej.Code).To(Equal(CodeDomainDenied))
+			})
+		})
+
+		When("the domain is disposable", func() {
+			BeforeEach(func() {
+				email = "ford@mail.mailinator.com"
+			})
+
+			It("is rejected", func() {
+				Expect(rej.Code).To(Equal(CodeEmailDisposable))
+			})
+
+			When("but disposable domains are allowed", func() {
+				BeforeEach(func() {
+					cfg.Set("signups.blockDisposable", false)
+				})
+
+				It("is not rejected", func() {
+					Expect(rej).To(BeNil())
+				})
+			})
+		})
+
+		When("the domain is listed in the local file", func() {
+			var dir string
+
+			BeforeEach(func() {
+				dir, _ = ioutil.TempDir("", "signups")
+
+				fp := filepath.Join(dir, "domains.txt")
+				Expect(ioutil.WriteFile(fp, []byte("# local\nexample.com\n"), 0644)).NotTo(HaveOccurred())
+
+				cfg.Set("signups.disposableDomainsFile", fp)
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(dir)
+			})
+
+			It("is rejected", func() {
+				Expect(rej.Code).To(Equal(CodeEmailDisposable))
+			})
+		})
+	})
+})
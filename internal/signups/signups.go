@@ -0,0 +1,119 @@
+package signups
+
+import (
+	"log"
+
+	"github.com/adamstrickland/dapper-api/internal/audit"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/invitations"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+)
+
+// Request is what a prospective user signs up with.
+type Request struct {
+	Email      string `json:"email" validate:"required,email,max=254"`
+	Password   string `json:"password" validate:"raw,required,min=8,max=72"`
+	FirstName  string `json:"firstName" validate:"max=100"`
+	LastName   string `json:"lastName" validate:"max=100"`
+	InviteCode string `json:"inviteCode" validate:"max=64"`
+}
+
+// record counts a refused signup in the audit trail.
+func record(cfg *config.Config, remoteAddr, email string, rej *Rejection) {
+	log.Printf("Rejected signup for '%s': %s", email, rej.Code)
+
+	audit.Record(cfg, &audit.Entry{
+		Action:     AuditActionRejected,
+		Subject:    email,
+		Outcome:    "rejected",
+		Reason:     rej.Code,
+		RemoteAddr: remoteAddr,
+	})
+}
+
+// SignUp creates the account the request asks for, and returns the
+// membership it joined through its invitation, if any.  Invalid requests fail
+// with validation.Errors, including emails the signup rules refuse; requests
+// the signup mode refuses fail with a *Rejection.  Refusals are recorded in
+// the audit trail.
+func SignUp(cfg *config.Config, remoteAddr string, req *Request) (*users.User, *organizations.Membership, error) {
+	if errs := validation.Validate(req); errs != nil {
+		log.Printf("Invalid payload: %s", errs)
+		return nil, nil, errs
+	}
+
+	if rej := CheckEmail(cfg, req.Email); rej != nil {
+		record(cfg, remoteAddr, req.Email, rej)
+
+		return nil, nil, validation.Errors{{
+			Field:   "email",
+			Code:    rej.Code,
+			Message: rej.Message,
+		}}
+	}
+
+	var (
+		inv *invitations.Invitation
+		err error
+	)
+
+	switch mode := cfg.GetString("signupMode"); mode {
+	case ModeOpen:
+	case ModeInviteOnly:
+		if req.InviteCode == "" {
+			rej := &Rejection{Code: CodeInvitationRequired, Message: "An invitation code is required"}
+			record(cfg, remoteAddr, req.Email, rej)
+			return nil, nil, rej
+		}
+
+		inv, err = invitations.Reserve(cfg, req.InviteCode, req.Email)
+
+		if err != nil {
+			rej := &Rejection{Code: CodeInvitationInvalid, Message: err.Error()}
+			record(cfg, remoteAddr, req.Email, rej)
+			return nil, nil, rej
+		}
+	default:
+		rej := &Rejection{Code: CodeSignupsClosed, Message: "Signups are closed"}
+		record(cfg, remoteAddr, req.Email, rej)
+		return nil, nil, rej
+	}
+
+	u, err := users.Create(cfg, &users.User{
+		Email:               req.Email,
+		UnencryptedPassword: req.Password,
+		FirstName:           req.FirstName,
+		LastName:            req.LastName,
+	})
+
+	if err != nil {
+		log.Printf("Unable to create User: %e", err)
+
+		if inv != nil {
+			if err := invitations.Release(cfg, inv); err != nil {
+				log.Printf("Unable to release invitation: %e", err)
+			}
+		}
+
+		return nil, nil, err
+	}
+
+	var m *organizations.Membership
+
+	switch {
+	case inv != nil && inv.OrganizationID != 0:
+		m, err = organizations.Join(cfg, inv, u)
+
+		if err != nil {
+			log.Printf("Unable to join organization: %e", err)
+		}
+	case inv != nil:
+		if err := invitations.Record(cfg, inv, u); err != nil {
+			log.Printf("Unable to record invitation use: %e", err)
+		}
+	}
+
+	return u, m, nil
+}
@@ -0,0 +1,124 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/adamstrickland/dapper-api/internal/attributes"
+	"github.com/adamstrickland/dapper-api/internal/caching"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+)
+
+const SchemaType = "application/schema+json"
+
+// ValidateAttributes checks attributes written by a client against the schema
+// in force, returning them compacted for storage.  Absent attributes are left
+// unchanged and come back empty; null clears them.
+func ValidateAttributes(cfg *config.Config, raw json.RawMessage) (string, validation.Errors, error) {
+	if raw == nil {
+		return "", nil, nil
+	}
+
+	raw = bytes.TrimSpace(raw)
+
+	if string(raw) == "null" {
+		raw = json.RawMessage("{}")
+	}
+
+	if len(raw) == 0 || raw[0] != '{' {
+		return "", validation.Errors{{
+			Field:   "attributes",
+			Code:    validation.CodeInvalid,
+			Message: "attributes must be an object",
+		}}, nil
+	}
+
+	errs, err := attributes.Validate(cfg, raw)
+
+	if err != nil || errs != nil {
+		return "", errs, err
+	}
+
+	var buf bytes.Buffer
+
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", nil, err
+	}
+
+	return buf.String(), nil, nil
+}
+
+func writeSchema(w http.ResponseWriter, r *http.Request, s *attributes.Schema) {
+	w.Header().Set("Content-Type", SchemaType)
+
+	_, err := caching.Write(w, r, fmt.Sprintf(`"%d"`, s.ID), s.CreatedAt, []byte(s.Document))
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+	}
+}
+
+func NewGetAttributeSchemaHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := attributes.Current(cfg)
+
+		if err != nil {
+			log.Printf("Unable to load attribute schema: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeSchema(w, r, s)
+	}
+}
+
+// NewPutAttributeSchemaHandler lets admins replace the schema user attributes
+// are validated against.
+func NewPutAttributeSchemaHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cu, err := CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		if !cu.IsAdmin() {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		doc, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			log.Printf("Unable to read schema: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s, err := attributes.Define(cfg, doc, cu.PublicID)
+
+		if errors.Is(err, attributes.ErrInvalidSchema) {
+			validation.WriteErrors(w, validation.Errors{{
+				Field:   "schema",
+				Code:    validation.CodeInvalid,
+				Message: err.Error(),
+			}})
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeSchema(w, r, s)
+	}
+}
@@ -0,0 +1,204 @@
+package users_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/attributes"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("users/attributes.go", func() {
+	const schema = `{
+		"type": "object",
+		"properties": {
+			"department": {"type": "string"},
+			"employeeNumber": {"type": "integer", "minimum": 1}
+		},
+		"additionalProperties": false
+	}`
+
+	var (
+		rr     *httptest.ResponseRecorder
+		cfg    *config.Config
+		user   *users.User
+		token  string
+		result map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		rr = httptest.NewRecorder()
+		result = nil
+
+		user = &users.User{Email: faker.Email(), FirstName: "Zaphod"}
+		users.Create(cfg, user)
+
+		token, _ = security.NewTokenForSubject(cfg, user.PublicID)
+
+		_, err := attributes.Define(cfg, []byte(schema), "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		attributes.Define(cfg, []byte(attributes.DefaultDocument), "")
+	})
+
+	serve := func(handler http.HandlerFunc, method, path, contentType, body string) {
+		r, err := http.NewRequest(method, path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		handler.ServeHTTP(rr, r)
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	Describe("NewGetAttributeSchemaHandler()", func() {
+		BeforeEach(func() {
+			serve(users.NewGetAttributeSchemaHandler(cfg), "GET", "/users/attributes/schema", "", "")
+		})
+
+		It("returns the schema in force", func() {
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Header().Get("Content-Type")).To(Equal(users.SchemaType))
+			Expect(result).To(HaveKey("properties"))
+		})
+	})
+
+	Describe("NewPutAttributeSchemaHandler()", func() {
+		When("the user is not an admin", func() {
+			BeforeEach(func() {
+				serve(users.NewPutAttributeSchemaHandler(cfg), "PUT", "/users/attributes/schema", users.SchemaType, attributes.DefaultDocument)
+			})
+
+			It("is forbidden", func() {
+				Expect(rr.Code).To(Equal(http.StatusForbidden))
+			})
+		})
+
+		When("the user is an admin", func() {
+			BeforeEach(func() {
+				users.SetRole(cfg, user.Email, users.RoleAdmin)
+			})
+
+			It("puts the schema in force", func() {
+				serve(users.NewPutAttributeSchemaHandler(cfg), "PUT", "/users/attributes/schema", users.SchemaType, attributes.DefaultDocument)
+
+				s, _ := attributes.Current(cfg)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(s.Document).To(Equal(attributes.DefaultDocument))
+				Expect(s.CreatedBy).To(Equal(user.PublicID))
+			})
+
+			It("rejects an invalid schema", func() {
+				serve(users.NewPutAttributeSchemaHandler(cfg), "PUT", "/users/attributes/schema", users.SchemaType, `{"type":"array"}`)
+
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+		})
+	})
+
+	Describe("writing attributes", func() {
+		When("they are put", func() {
+			It("stores conforming attributes", func() {
+				serve(users.NewPutHandler(cfg), "PUT", "/users", "application/json",
+					fmt.Sprintf(`{"email":"%s","attributes":{"department":"Sales"}}`, user.Email))
+
+				u, _ := users.FindByEmail(cfg, user.Email)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(u.Attributes).To(MatchJSON(`{"department":"Sales"}`))
+				Expect(result["attributes"]).To(Equal(map[string]interface{}{"department": "Sales"}))
+			})
+
+			It("rejects attributes that do not conform", func() {
+				serve(users.NewPutHandler(cfg), "PUT", "/users", "application/json",
+					fmt.Sprintf(`{"email":"%s","attributes":{"employeeNumber":"x"}}`, user.Email))
+
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(result["errors"]).To(ContainElement(HaveKeyWithValue("field", "attributes.employeeNumber")))
+			})
+		})
+
+		When("they are patched", func() {
+			BeforeEach(func() {
+				users.Update(cfg, &users.User{Email: user.Email, Attributes: `{"department":"Sales"}`})
+			})
+
+			It("merges the change", func() {
+				serve(users.NewPatchHandler(cfg), "PATCH", "/users/me", users.MergePatchType, `{"attributes":{"employeeNumber":42}}`)
+
+				u, _ := users.FindByEmail(cfg, user.Email)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(u.Attributes).To(MatchJSON(`{"department":"Sales","employeeNumber":42}`))
+			})
+
+			It("rejects attributes that do not conform", func() {
+				serve(users.NewPatchHandler(cfg), "PATCH", "/users/me", users.MergePatchType, `{"attributes":{"shoeSize":9}}`)
+
+				u, _ := users.FindByEmail(cfg, user.Email)
+
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(u.Attributes).To(MatchJSON(`{"department":"Sales"}`))
+			})
+		})
+	})
+
+	Describe("filtering on attributes", func() {
+		var (
+			number int
+		)
+
+		BeforeEach(func() {
+			number = 100000 + int(faker.UnixTime()%100000)
+
+			users.Update(cfg, &users.User{
+				Email:      user.Email,
+				Attributes: fmt.Sprintf(`{"department":"Sales","employeeNumber":%d}`, number),
+			})
+		})
+
+		list := func(query string) []interface{} {
+			serve(users.NewGetHandler(cfg), "GET", "/users?"+query, "", "")
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			return result["users"].([]interface{})
+		}
+
+		It("matches numbers", func() {
+			us := list(fmt.Sprintf("attributes.employeeNumber=%d&email=%s", number, user.Email))
+
+			Expect(us).To(HaveLen(1))
+			Expect(us[0]).To(HaveKeyWithValue("email", user.Email))
+		})
+
+		It("matches strings alongside other filters", func() {
+			us := list("attributes.department=Sales&email=" + user.Email)
+
+			Expect(us).To(HaveLen(1))
+		})
+
+		It("excludes users without a match", func() {
+			Expect(list("attributes.department=Sales&attributes.employeeNumber=0&email=" + user.Email)).To(BeEmpty())
+		})
+
+		It("rejects malformed attribute names", func() {
+			serve(users.NewGetHandler(cfg), "GET", "/users?attributes.a%27b=1", "", "")
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+	})
+})
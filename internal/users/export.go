@@ -0,0 +1,245 @@
+package users
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/audit"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+)
+
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+const AuditActionExported = "users.exported"
+
+// ExportTypes maps each export format to the media type it is served as.
+var ExportTypes = map[string]string{
+	ExportCSV:    "text/csv",
+	ExportNDJSON: "application/x-ndjson",
+}
+
+// exportFlushEvery is how many rows are written between flushes to the client.
+const exportFlushEvery = 500
+
+// negotiateExport picks the export format from an Accept header, preferring
+// NDJSON when any format will do.  Quality values only rule types out.
+func negotiateExport(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return ExportNDJSON, true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+
+		switch mt {
+		case "text/csv", "text/*":
+			return ExportCSV, true
+		case "application/x-ndjson", "application/jsonl", "application/*", "*/*":
+			return ExportNDJSON, true
+		}
+	}
+
+	return "", false
+}
+
+// ParseExport reads the fields and filters of an export from query
+// parameters, as accepted by GET /users.  Pagination and sort do not apply.
+func ParseExport(cfg *config.Config, qs url.Values) (Query, []string, validation.Errors) {
+	q, errs := parseQuery(cfg, qs)
+	p := parseProjection(qs, &errs)
+
+	if len(p.Include) > 0 {
+		errs = append(errs, validation.FieldError{
+			Field:   "include",
+			Code:    validation.CodeInvalid,
+			Message: "exports cannot include related data",
+		})
+	}
+
+	q.Columns = p.Columns()
+
+	return q, p.fields(), errs
+}
+
+// exportWriter encodes rows of user payload fields.
+type exportWriter interface {
+	write(values map[string]interface{}) error
+	flush() error
+}
+
+type csvExport struct {
+	w      *csv.Writer
+	fields []string
+	record []string
+}
+
+func (ce *csvExport) write(values map[string]interface{}) error {
+	for i, f := range ce.fields {
+		switch v := values[f].(type) {
+		case json.RawMessage:
+			ce.record[i] = string(v)
+		default:
+			ce.record[i] = fmt.Sprint(v)
+		}
+	}
+
+	return ce.w.Write(ce.record)
+}
+
+func (ce *csvExport) flush() error {
+	ce.w.Flush()
+
+	return ce.w.Error()
+}
+
+type ndjsonExport struct {
+	enc    *json.Encoder
+	fields []string
+}
+
+func (ne *ndjsonExport) write(values map[string]interface{}) error {
+	m := make(map[string]interface{}, len(ne.fields))
+
+	for _, f := range ne.fields {
+		m[f] = values[f]
+	}
+
+	return ne.enc.Encode(m)
+}
+
+func (ne *ndjsonExport) flush() error {
+	return nil
+}
+
+func newExportWriter(format string, w io.Writer, fields []string) (exportWriter, error) {
+	switch format {
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+
+		if err := cw.Write(fields); err != nil {
+			return nil, err
+		}
+
+		return &csvExport{w: cw, fields: fields, record: make([]string, len(fields))}, nil
+	case ExportNDJSON:
+		return &ndjsonExport{enc: json.NewEncoder(w), fields: fields}, nil
+	default:
+		return nil, fmt.Errorf("Unknown export format '%s'", format)
+	}
+}
+
+// WriteExport streams the fields of every user matching the query to w, one
+// row at a time, calling flush, when given, as rows are written.
+func WriteExport(cfg *config.Config, w io.Writer, format string, q Query, fields []string, flush func()) (int, error) {
+	ew, err := newExportWriter(format, w, fields)
+
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+
+	err = Each(cfg, q, func(u *User) error {
+		if err := ew.write(payloadValues(NewUserPayload(u))); err != nil {
+			return err
+		}
+
+		n++
+
+		if n%exportFlushEvery == 0 && flush != nil {
+			if err := ew.flush(); err != nil {
+				return err
+			}
+
+			flush()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return n, err
+	}
+
+	if err := ew.flush(); err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+// NewExportHandler lets admins download every user, in the format named by
+// the Accept header.  Rows are streamed, so errors after the first row can
+// only cut the response short.
+func NewExportHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cu, err := CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		if !cu.IsAdmin() {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		format, ok := negotiateExport(r.Header.Get("Accept"))
+
+		if !ok {
+			http.Error(w, "", http.StatusNotAcceptable)
+			return
+		}
+
+		q, fields, errs := ParseExport(cfg, r.URL.Query())
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		q.Tenant = requestTenant(r)
+
+		w.Header().Set("Content-Type", ExportTypes[format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, format))
+
+		flusher, _ := w.(http.Flusher)
+
+		n, err := WriteExport(cfg, w, format, q, fields, func() {
+			if flusher != nil {
+				flusher.Flush()
+			}
+		})
+
+		outcome := "success"
+
+		if err != nil {
+			log.Printf("Unable to export users after %d rows: %e", n, err)
+			outcome = "failure"
+		}
+
+		audit.Record(cfg, &audit.Entry{
+			Action:     AuditActionExported,
+			Actor:      cu.PublicID,
+			Outcome:    outcome,
+			RemoteAddr: r.RemoteAddr,
+		})
+	}
+}
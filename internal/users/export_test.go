@@ -0,0 +1,116 @@
+package users_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("users/export.go", func() {
+	var (
+		rr     *httptest.ResponseRecorder
+		cfg    *config.Config
+		admin  *users.User
+		user   *users.User
+		accept string
+		query  string
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		rr = httptest.NewRecorder()
+
+		admin = &users.User{Email: faker.Email()}
+		users.Create(cfg, admin)
+		users.SetRole(cfg, admin.Email, users.RoleAdmin)
+
+		user = &users.User{Email: faker.Email(), FirstName: "Trillian"}
+		users.Create(cfg, user)
+
+		accept = "text/csv"
+		query = "fields=email,firstName&email=" + user.Email
+	})
+
+	serve := func(as *users.User) {
+		r, err := http.NewRequest("GET", "/admin/users/export?"+query, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		token, _ := security.NewTokenForSubject(cfg, as.PublicID)
+		r.Header.Set(cfg.GetString("tokenHeader"), token)
+		r.Header.Set("Accept", accept)
+
+		http.HandlerFunc(users.NewExportHandler(cfg)).ServeHTTP(rr, r)
+	}
+
+	It("is forbidden to users who are not admins", func() {
+		serve(user)
+
+		Expect(rr.Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("streams the selected fields of matching users as CSV", func() {
+		serve(admin)
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rr.Header().Get("Content-Type")).To(Equal("text/csv"))
+		Expect(records).To(Equal([][]string{
+			{"email", "firstName"},
+			{user.Email, "Trillian"},
+		}))
+	})
+
+	When("NDJSON is acceptable", func() {
+		BeforeEach(func() {
+			accept = "application/x-ndjson"
+			query = "email=" + user.Email
+		})
+
+		It("streams one JSON object per user", func() {
+			serve(admin)
+
+			lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+
+			var row map[string]interface{}
+
+			Expect(lines).To(HaveLen(1))
+			Expect(json.Unmarshal([]byte(lines[0]), &row)).To(Succeed())
+			Expect(row).To(HaveKeyWithValue("id", user.PublicID))
+			Expect(row).To(HaveKeyWithValue("attributes", map[string]interface{}{}))
+		})
+	})
+
+	When("no export format is acceptable", func() {
+		BeforeEach(func() {
+			accept = "application/pdf"
+		})
+
+		It("is not acceptable", func() {
+			serve(admin)
+
+			Expect(rr.Code).To(Equal(http.StatusNotAcceptable))
+		})
+	})
+
+	When("related data is included", func() {
+		BeforeEach(func() {
+			query = "include=roles"
+		})
+
+		It("is unprocessable", func() {
+			serve(admin)
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+	})
+})
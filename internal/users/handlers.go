@@ -3,21 +3,231 @@ package users
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/adamstrickland/dapper-api/internal/caching"
 	"github.com/adamstrickland/dapper-api/internal/config"
 	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/gorilla/mux"
 )
 
 type UserPayload struct {
-	Email     string `json:"email"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	ID        string `json:"id"`
+	Email     string `json:"email" validate:"required,email,max=254"`
+	FirstName string `json:"firstName" validate:"max=100"`
+	LastName  string `json:"lastName" validate:"max=100"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+
+	Attributes json.RawMessage `json:"attributes,omitempty"`
+}
+
+func NewUserPayload(u *User) UserPayload {
+	return UserPayload{
+		ID:         u.PublicID,
+		Email:      u.Email,
+		FirstName:  u.FirstName,
+		LastName:   u.LastName,
+		AvatarURL:  AvatarURL(u),
+		Attributes: attributesOf(u),
+	}
+}
+
+// attributesOf is the user's custom attributes, an empty object when they
+// have none.
+func attributesOf(u *User) json.RawMessage {
+	if u.Attributes == "" {
+		return json.RawMessage("{}")
+	}
+
+	return json.RawMessage(u.Attributes)
+}
+
+// AvatarURL is the path the user's avatar is served at, if they have one.
+func AvatarURL(u *User) string {
+	if u.Avatar == "" {
+		return ""
+	}
+
+	return "/avatars/" + u.Avatar
+}
+
+// CurrentUser returns the user identified by the request's token.
+func CurrentUser(cfg *config.Config, r *http.Request, columns ...string) (*User, error) {
+	subj, err := security.RequestSubject(cfg, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return FindBySubject(cfg, *subj, columns...)
+}
+
+func writeUser(w http.ResponseWriter, r *http.Request, u *User) {
+	writeProjectedUser(nil, w, r, u, Projection{})
+}
+
+// writeProjectedUser writes the user trimmed to the projection, unless the
+// client's copy of that representation is current.
+func writeProjectedUser(cfg *config.Config, w http.ResponseWriter, r *http.Request, u *User, p Projection) {
+	var data bytes.Buffer
+
+	ups, err := p.Render(cfg, security.RequestTenant(r), []User{*u})
+
+	if err != nil {
+		log.Printf("Unable to load included data: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(&data).Encode(ups[0])
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, err = caching.Write(w, r, projectedETag(u, p), u.UpdatedAt, data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// lastModified is the latest update among the users, or zero when there are
+// none.  Users leaving a page do not advance it, which is why the page's ETag
+// is derived from its body and takes precedence over it.
+func lastModified(us *[]User) time.Time {
+	var lm time.Time
+
+	for _, u := range *us {
+		if u.UpdatedAt.After(lm) {
+			lm = u.UpdatedAt
+		}
+	}
+
+	return lm
 }
 
 type usersPayload struct {
-	Users []UserPayload `json:"users"`
+	Users []interface{} `json:"users"`
+	Next  string        `json:"next,omitempty"`
+}
+
+func parseTime(qs url.Values, field string, errs *validation.Errors) *time.Time {
+	v := qs.Get(field)
+
+	if v == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+
+	if err != nil {
+		*errs = append(*errs, validation.FieldError{
+			Field:   field,
+			Code:    validation.CodeInvalid,
+			Message: fmt.Sprintf("%s must be an RFC 3339 timestamp", field),
+		})
+		return nil
+	}
+
+	return &t
+}
+
+// parseLimit reads the page size parameter, returning 0 when it is absent.
+func parseLimit(cfg *config.Config, qs url.Values, errs *validation.Errors) int {
+	v := qs.Get("limit")
+
+	if v == "" {
+		return 0
+	}
+
+	limit, err := strconv.Atoi(v)
+	max := cfg.GetInt("pagination.maxLimit")
+
+	if err != nil || limit < 1 || limit > max {
+		*errs = append(*errs, validation.FieldError{
+			Field:   "limit",
+			Code:    validation.CodeInvalid,
+			Message: fmt.Sprintf("limit must be between 1 and %d", max),
+		})
+	}
+
+	return limit
+}
+
+// parseAttributeFilters reads the "attributes.<name>" parameters, each of
+// which only admits users with that attribute set to the value.
+func parseAttributeFilters(qs url.Values, errs *validation.Errors) map[string]string {
+	var filters map[string]string
+
+	for k, vs := range qs {
+		name := strings.TrimPrefix(k, "attributes.")
+
+		if name == k {
+			continue
+		}
+
+		if !AttributeKey.MatchString(name) {
+			*errs = append(*errs, validation.FieldError{
+				Field:   k,
+				Code:    validation.CodeInvalid,
+				Message: "attribute names may only contain letters, digits and underscores",
+			})
+			continue
+		}
+
+		if filters == nil {
+			filters = make(map[string]string)
+		}
+
+		filters[name] = vs[0]
+	}
+
+	return filters
+}
+
+// parseQuery reads the pagination, filter and sort parameters of a request
+// for a list of users.
+func parseQuery(cfg *config.Config, qs url.Values) (Query, validation.Errors) {
+	var errs validation.Errors
+
+	q := Query{
+		Cursor:        qs.Get("cursor"),
+		Email:         qs.Get("email"),
+		Name:          qs.Get("name"),
+		CreatedAfter:  parseTime(qs, "createdAfter", &errs),
+		CreatedBefore: parseTime(qs, "createdBefore", &errs),
+		Sort:          qs.Get("sort"),
+	}
+
+	q.Limit = parseLimit(cfg, qs, &errs)
+	q.Attributes = parseAttributeFilters(qs, &errs)
+
+	if q.Sort != "" {
+		if _, _, _, err := parseSort(q.Sort); err != nil {
+			errs = append(errs, validation.FieldError{
+				Field:   "sort",
+				Code:    validation.CodeInvalid,
+				Message: "sort must be one of createdAt, email, firstName or lastName, optionally prefixed with '-'",
+			})
+		}
+	}
+
+	return q, errs
 }
 
 func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
@@ -26,26 +236,56 @@ func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Reque
 
 		w.Header().Set("Content-Type", "application/json")
 
-		users, err := All(cfg)
+		q, errs := parseQuery(cfg, r.URL.Query())
+		p := parseProjection(r.URL.Query(), &errs)
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		q.Columns = p.Columns()
+		q.Tenant = requestTenant(r)
+
+		if cfg.GetString("users.visibility") != VisibilityAll {
+			viewer, err := CurrentUser(cfg, r)
+
+			if err != nil {
+				log.Printf("Unable to identify user: %e", err)
+				http.Error(w, "", http.StatusUnauthorized)
+				return
+			}
+
+			q = ScopeQuery(cfg, viewer, q)
+		}
+
+		users, next, err := Page(cfg, q)
+
+		if errors.Is(err, ErrInvalidCursor) {
+			validation.WriteErrors(w, validation.Errors{{
+				Field:   "cursor",
+				Code:    validation.CodeInvalid,
+				Message: err.Error(),
+			}})
+			return
+		}
 
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		ups := make([]UserPayload, 0)
+		ups, err := p.Render(cfg, security.RequestTenant(r), *users)
 
-		for _, u := range *users {
-			up := UserPayload{
-				Email:     u.Email,
-				FirstName: u.FirstName,
-				LastName:  u.LastName,
-			}
-			ups = append(ups, up)
+		if err != nil {
+			log.Printf("Unable to load included data: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		err = json.NewEncoder(&data).Encode(&usersPayload{
 			Users: ups,
+			Next:  next,
 		})
 
 		if err != nil {
@@ -53,7 +293,8 @@ func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Reque
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		_, err = w.Write(data.Bytes())
+
+		_, err = caching.Write(w, r, caching.WeakETag(data.Bytes()), lastModified(users), data.Bytes())
 
 		if err != nil {
 			log.Printf("Unable to write body: %e", err)
@@ -65,10 +306,7 @@ func NewGetHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Reque
 
 func NewPutHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var (
-			data bytes.Buffer
-			up   UserPayload
-		)
+		var up UserPayload
 
 		w.Header().Set("Content-Type", "application/json")
 
@@ -80,61 +318,305 @@ func NewPutHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		t := r.Header.Get(cfg.GetString("tokenHeader"))
-
-		if t == "" {
-			log.Printf("No token found")
-			http.Error(w, "", http.StatusUnauthorized)
+		if errs := validation.Validate(&up); errs != nil {
+			log.Printf("Invalid payload: %s", errs)
+			validation.WriteErrors(w, errs)
 			return
 		}
 
-		subj, err := security.TokenSubject(cfg, t)
+		cu, err := CurrentUser(cfg, r)
 
 		if err != nil {
-			log.Printf("Subject could not be extracted from token")
+			log.Printf("Unable to identify user: %e", err)
 			http.Error(w, "", http.StatusUnauthorized)
 			return
 		}
 
-		if *subj != up.Email {
+		if cu.Email != up.Email {
 			log.Printf("Token is not authorized to modify resource at '%s'", up.Email)
 			http.Error(w, "", http.StatusUnauthorized)
 			return
 		}
 
+		version, err := expectedVersion(cfg, r, cu)
+
+		if err != nil {
+			http.Error(w, err.Error(), updateStatus(r, err))
+			return
+		}
+
+		attrs, errs, err := ValidateAttributes(cfg, up.Attributes)
+
+		if err != nil {
+			log.Printf("Unable to validate attributes: %e", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
 		u := &User{
-			Email:     up.Email,
-			FirstName: up.FirstName,
-			LastName:  up.LastName,
+			Email:      up.Email,
+			FirstName:  up.FirstName,
+			LastName:   up.LastName,
+			Attributes: attrs,
+			Version:    version,
 		}
 
 		uu, err := Update(cfg, u)
 
 		if err != nil {
 			log.Printf("Could not update user with email '%s': %e", u.Email, err)
+			http.Error(w, err.Error(), updateStatus(r, err))
+			return
+		}
+
+		writeUser(w, r, uu)
+	}
+}
+
+func NewGetCurrentHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var errs validation.Errors
+
+		w.Header().Set("Content-Type", "application/json")
+
+		p := parseProjection(r.URL.Query(), &errs)
+		t := parseTime(r.URL.Query(), "asOf", &errs)
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		u, err := CurrentUser(cfg, r, p.Columns()...)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		u, err = asOf(cfg, u, t)
+
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeProjectedUser(cfg, w, r, u, p)
+	}
+}
+
+func NewGetOneHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var errs validation.Errors
+
+		w.Header().Set("Content-Type", "application/json")
+
+		p := parseProjection(r.URL.Query(), &errs)
+		t := parseTime(r.URL.Query(), "asOf", &errs)
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		viewer, err := CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		u, err := FindByPublicID(cfg, mux.Vars(r)["id"], p.Columns()...)
+
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		inTenant, err := InTenant(cfg, u.ID, security.RequestTenant(r))
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// users who may not be seen are indistinguishable from missing ones
+		if !inTenant || !CanView(cfg, viewer, u) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		u, err = asOf(cfg, u, t)
+
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		up = UserPayload{
-			Email:     uu.Email,
-			FirstName: uu.FirstName,
-			LastName:  uu.LastName,
+		writeProjectedUser(cfg, w, r, u, p)
+	}
+}
+
+func NewPatchHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		if err != nil || (mt != MergePatchType && mt != JSONPatchType) {
+			http.Error(w, ErrUnsupportedPatch.Error(), http.StatusUnsupportedMediaType)
+			return
 		}
 
-		err = json.NewEncoder(&data).Encode(&up)
+		patch, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			log.Printf("Unable to read patch: %e", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cu, err := CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		version, err := expectedVersion(cfg, r, cu)
+
+		if err != nil {
+			http.Error(w, err.Error(), updateStatus(r, err))
+			return
+		}
+
+		u, err := Modify(cfg, cu.ID, version, func(u *User) error {
+			return PatchUser(cfg, u, mt, patch)
+		})
+
+		var errs validation.Errors
+
+		switch {
+		case err == nil:
+			writeUser(w, r, u)
+		case errors.As(err, &errs):
+			validation.WriteErrors(w, errs)
+		case errors.Is(err, ErrInvalidPatch):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrPatchConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Unable to patch User: %e", err)
+			http.Error(w, err.Error(), updateStatus(r, err))
+		}
+	}
+}
+
+type SearchResultPayload struct {
+	User    UserPayload `json:"user"`
+	Score   float64     `json:"score"`
+	Snippet string      `json:"snippet"`
+}
+
+type searchResultsPayload struct {
+	Results []SearchResultPayload `json:"results"`
+}
+
+func NewSearchHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			data bytes.Buffer
+			errs validation.Errors
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		qs := r.URL.Query()
+		q := strings.TrimSpace(qs.Get("q"))
+
+		if q == "" {
+			errs = append(errs, validation.FieldError{
+				Field:   "q",
+				Code:    validation.CodeRequired,
+				Message: "q is required",
+			})
+		}
+
+		limit := parseLimit(cfg, qs, &errs)
+
+		if errs != nil {
+			validation.WriteErrors(w, errs)
+			return
+		}
+
+		if limit == 0 {
+			limit = cfg.GetInt("pagination.defaultLimit")
+		}
+
+		viewer, err := CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		results, err := Search(cfg, q, limit, requestTenant(r), viewer)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		srps := make([]SearchResultPayload, 0)
+
+		for i := range *results {
+			sr := &(*results)[i]
+
+			srps = append(srps, SearchResultPayload{
+				User:    NewUserPayload(&sr.User),
+				Score:   sr.Score,
+				Snippet: sr.Snippet,
+			})
+		}
+
+		err = json.NewEncoder(&data).Encode(&searchResultsPayload{
+			Results: srps,
+		})
 
 		if err != nil {
 			log.Printf("Unable to generate payload: %e", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+
 		_, err = w.Write(data.Bytes())
 
 		if err != nil {
 			log.Printf("Unable to write body: %e", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
 		}
 	}
 }
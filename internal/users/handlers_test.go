@@ -3,14 +3,19 @@ package users_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/adamstrickland/dapper-api/internal"
 	"github.com/adamstrickland/dapper-api/internal/config"
 	"github.com/adamstrickland/dapper-api/internal/security"
 	"github.com/adamstrickland/dapper-api/internal/users"
 	"github.com/bxcodec/faker/v3"
+	"github.com/gorilla/mux"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -114,6 +119,54 @@ var _ = Describe("users/handlers.go", func() {
 					Expect(u.LastName).To(Equal(newln))
 				})
 
+				It("returns an ETag", func() {
+					u, _ := users.FindByEmail(cfg, email)
+					Expect(rr.Header().Get("ETag")).To(Equal(users.ETag(u)))
+				})
+
+				When("If-Match names a stale version", func() {
+					BeforeEach(func() {
+						r.Header.Set("If-Match", `"0", W/"1"`)
+					})
+
+					It("fails the precondition", func() {
+						Expect(rr.Code).To(Equal(http.StatusPreconditionFailed))
+					})
+
+					It("leaves the record unchanged", func() {
+						u, _ := users.FindByEmail(cfg, email)
+						Expect(u.FirstName).To(Equal("Zaphod"))
+					})
+				})
+
+				When("If-Match is a wildcard", func() {
+					BeforeEach(func() {
+						r.Header.Set("If-Match", "*")
+					})
+
+					It("the request is accepted", func() {
+						Expect(rr.Code).To(Equal(http.StatusOK))
+					})
+				})
+
+				When("but the payload is invalid", func() {
+					BeforeEach(func() {
+						var data bytes.Buffer
+
+						Expect(json.NewEncoder(&data).Encode(&users.UserPayload{
+							Email:     email,
+							FirstName: strings.Repeat("x", 101),
+						})).NotTo(HaveOccurred())
+
+						r, err = http.NewRequest("PUT", "/users", &data)
+						r.Header.Set(cfg.GetString("tokenHeader"), token)
+					})
+
+					It("the request is unprocessable", func() {
+						Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+					})
+				})
+
 				It("returns the modified record", func() {
 					json.Unmarshal(rr.Body.Bytes(), &result)
 					Expect(result["email"]).To(Equal(email))
@@ -148,6 +201,100 @@ var _ = Describe("users/handlers.go", func() {
 			Expect(result["users"]).To(ContainElement(HaveKeyWithValue("email", email)))
 		})
 
+		When("fields are requested", func() {
+			BeforeEach(func() {
+				r, err = http.NewRequest("GET", "/users?fields=email&include=roles", nil)
+			})
+
+			It("returns only those fields", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+
+				for _, u := range result["users"].([]interface{}) {
+					Expect(u).To(HaveLen(2))
+					Expect(u).To(HaveKey("email"))
+					Expect(u).To(HaveKey("roles"))
+				}
+			})
+		})
+
+		When("an unknown field is requested", func() {
+			BeforeEach(func() {
+				r, err = http.NewRequest("GET", "/users?fields=email,password", nil)
+			})
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+		})
+
+		It("returns a weak ETag and a modification date", func() {
+			Expect(rr.Header().Get("ETag")).To(HavePrefix(`W/"`))
+			Expect(rr.Header().Get("Last-Modified")).NotTo(BeEmpty())
+		})
+
+		When("the client's copy is current", func() {
+			BeforeEach(func() {
+				first := httptest.NewRecorder()
+				handler.ServeHTTP(first, r)
+
+				r, err = http.NewRequest("GET", "/users", nil)
+				r.Header.Set("If-None-Match", first.Header().Get("ETag"))
+			})
+
+			It("is not modified", func() {
+				Expect(rr.Code).To(Equal(http.StatusNotModified))
+				Expect(rr.Body.Len()).To(BeZero())
+			})
+		})
+
+		When("a user changed since the client's copy", func() {
+			BeforeEach(func() {
+				first := httptest.NewRecorder()
+				handler.ServeHTTP(first, r)
+
+				users.Update(cfg, &users.User{Email: email, FirstName: "Ford"})
+
+				r, err = http.NewRequest("GET", "/users", nil)
+				r.Header.Set("If-None-Match", first.Header().Get("ETag"))
+			})
+
+			It("is OK", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		When("a page size is requested", func() {
+			BeforeEach(func() {
+				users.Create(cfg, &users.User{Email: faker.Email()})
+				r, err = http.NewRequest("GET", "/users?limit=1", nil)
+			})
+
+			It("returns one page of users", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+				Expect(result["users"]).To(HaveLen(1))
+			})
+
+			It("returns a cursor for the next page", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+				Expect(result["next"]).NotTo(BeEmpty())
+			})
+		})
+
+		When("the query is invalid", func() {
+			BeforeEach(func() {
+				r, err = http.NewRequest("GET", "/users?limit=0&sort=password&createdAfter=yesterday", nil)
+			})
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+
+			It("reports each invalid parameter", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+				Expect(result["errors"]).To(HaveLen(3))
+			})
+		})
+
 		Context("accounting for drift", func() {
 			var (
 				count int
@@ -156,6 +303,9 @@ var _ = Describe("users/handlers.go", func() {
 			BeforeEach(func() {
 				db, _ := internal.NewConnection(cfg)
 				db.Exec("DELETE FROM users")
+				db.Exec("DELETE FROM memberships")
+				db.Exec("DELETE FROM user_revisions")
+				db.Exec("DELETE FROM erasures")
 
 				expected := 3
 
@@ -175,4 +325,431 @@ var _ = Describe("users/handlers.go", func() {
 			})
 		})
 	})
+
+	Describe("NewGetCurrentHandler()", func() {
+		BeforeEach(func() {
+			handler = http.HandlerFunc(users.NewGetCurrentHandler(cfg))
+			r, err = http.NewRequest("GET", "/users/me", nil)
+		})
+
+		When("the request is authenticated", func() {
+			BeforeEach(func() {
+				token, _ := security.NewTokenForSubject(cfg, user.PublicID)
+				r.Header.Set(cfg.GetString("tokenHeader"), token)
+			})
+
+			It("is OK", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the token's user", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+				Expect(result["email"]).To(Equal(email))
+				Expect(result["id"]).To(Equal(user.PublicID))
+			})
+
+			It("returns an ETag", func() {
+				Expect(rr.Header().Get("ETag")).To(Equal(users.ETag(user)))
+			})
+
+			When("fields are requested", func() {
+				BeforeEach(func() {
+					r.URL.RawQuery = "fields=firstName&include=roles"
+				})
+
+				It("returns only those fields", func() {
+					var payload map[string]interface{}
+
+					json.Unmarshal(rr.Body.Bytes(), &payload)
+
+					Expect(payload).To(Equal(map[string]interface{}{
+						"firstName": "Zaphod",
+						"roles":     []interface{}{users.RoleUser},
+					}))
+				})
+
+				It("tags the trimmed representation", func() {
+					Expect(rr.Header().Get("ETag")).NotTo(Equal(users.ETag(user)))
+					Expect(rr.Header().Get("ETag")).To(HavePrefix(`"1;`))
+				})
+			})
+
+			When("an unknown include is requested", func() {
+				BeforeEach(func() {
+					r.URL.RawQuery = "include=sessions"
+				})
+
+				It("is unprocessable", func() {
+					Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+				})
+			})
+
+			It("returns the modification date", func() {
+				Expect(rr.Header().Get("Last-Modified")).To(Equal(user.UpdatedAt.UTC().Format(http.TimeFormat)))
+			})
+
+			When("the client's copy is current", func() {
+				BeforeEach(func() {
+					r.Header.Set("If-None-Match", users.ETag(user))
+				})
+
+				It("is not modified", func() {
+					Expect(rr.Code).To(Equal(http.StatusNotModified))
+					Expect(rr.Body.Len()).To(BeZero())
+				})
+			})
+
+			When("the client's copy is outdated", func() {
+				BeforeEach(func() {
+					users.Update(cfg, &users.User{Email: email, FirstName: "Ford"})
+					r.Header.Set("If-None-Match", users.ETag(user))
+				})
+
+				It("is OK", func() {
+					Expect(rr.Code).To(Equal(http.StatusOK))
+				})
+			})
+
+			When("the user has not changed since the given date", func() {
+				BeforeEach(func() {
+					r.Header.Set("If-Modified-Since", user.UpdatedAt.Add(time.Second).UTC().Format(http.TimeFormat))
+				})
+
+				It("is not modified", func() {
+					Expect(rr.Code).To(Equal(http.StatusNotModified))
+				})
+			})
+
+			It("does not expose the sequential ID", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+				Expect(result["id"]).NotTo(Equal(fmt.Sprintf("%d", user.ID)))
+			})
+		})
+
+		When("the token carries a legacy email subject", func() {
+			BeforeEach(func() {
+				token, _ := security.NewTokenForSubject(cfg, email)
+				r.Header.Set(cfg.GetString("tokenHeader"), token)
+			})
+
+			It("returns the token's user", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(result["id"]).To(Equal(user.PublicID))
+			})
+		})
+
+		When("the token's user does not exist", func() {
+			BeforeEach(func() {
+				token, _ := security.NewTokenForSubject(cfg, faker.Email())
+				r.Header.Set(cfg.GetString("tokenHeader"), token)
+			})
+
+			It("is unauthorized", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+	})
+
+	Describe("NewGetOneHandler()", func() {
+		var (
+			other *users.User
+			id    string
+		)
+
+		BeforeEach(func() {
+			handler = http.HandlerFunc(users.NewGetOneHandler(cfg))
+
+			other, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: "Trillian"})
+			id = other.PublicID
+		})
+
+		request := func() {
+			r, err = http.NewRequest("GET", "/users/"+id, nil)
+			r = mux.SetURLVars(r, map[string]string{"id": id})
+
+			token, _ := security.NewTokenForSubject(cfg, email)
+			r.Header.Set(cfg.GetString("tokenHeader"), token)
+		}
+
+		BeforeEach(request)
+
+		It("is OK", func() {
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+
+		It("returns the user", func() {
+			json.Unmarshal(rr.Body.Bytes(), &result)
+			Expect(result["firstName"]).To(Equal("Trillian"))
+		})
+
+		When("the user does not exist", func() {
+			BeforeEach(func() {
+				id = users.NewPublicID()
+				request()
+			})
+
+			It("is not found", func() {
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		When("users may only see themselves", func() {
+			BeforeEach(func() {
+				cfg.Set("users.visibility", users.VisibilitySelf)
+			})
+
+			It("is not found", func() {
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("NewPatchHandler()", func() {
+		var (
+			contentType, body string
+		)
+
+		BeforeEach(func() {
+			handler = http.HandlerFunc(users.NewPatchHandler(cfg))
+			contentType = users.MergePatchType
+			body = `{"firstName":"Arthur"}`
+		})
+
+		request := func() {
+			r, err = http.NewRequest("PATCH", "/users/me", strings.NewReader(body))
+			r.Header.Set("Content-Type", contentType)
+
+			token, _ := security.NewTokenForSubject(cfg, user.PublicID)
+			r.Header.Set(cfg.GetString("tokenHeader"), token)
+		}
+
+		JustBeforeEach(func() {
+			json.Unmarshal(rr.Body.Bytes(), &result)
+		})
+
+		When("a merge patch changes one field", func() {
+			BeforeEach(request)
+
+			It("is OK", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+
+			It("changes only that field", func() {
+				u, _ := users.FindByEmail(cfg, email)
+
+				Expect(u.FirstName).To(Equal("Arthur"))
+				Expect(u.LastName).To(Equal("Beeblebrox"))
+			})
+
+			It("returns the modified record", func() {
+				Expect(result["firstName"]).To(Equal("Arthur"))
+				Expect(result["lastName"]).To(Equal("Beeblebrox"))
+			})
+		})
+
+		When("a merge patch removes a field", func() {
+			BeforeEach(func() {
+				body = `{"lastName":null}`
+				request()
+			})
+
+			It("clears it", func() {
+				u, _ := users.FindByEmail(cfg, email)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(u.LastName).To(Equal(""))
+			})
+		})
+
+		When("a JSON patch is applied", func() {
+			BeforeEach(func() {
+				contentType = users.JSONPatchType
+				body = `[{"op":"test","path":"/lastName","value":"Beeblebrox"},{"op":"replace","path":"/lastName","value":"Dent"}]`
+				request()
+			})
+
+			It("applies every operation", func() {
+				u, _ := users.FindByEmail(cfg, email)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(u.FirstName).To(Equal("Zaphod"))
+				Expect(u.LastName).To(Equal("Dent"))
+			})
+		})
+
+		When("a JSON patch test fails", func() {
+			BeforeEach(func() {
+				contentType = users.JSONPatchType
+				body = `[{"op":"replace","path":"/firstName","value":"Arthur"},{"op":"test","path":"/lastName","value":"Dent"}]`
+				request()
+			})
+
+			It("conflicts", func() {
+				Expect(rr.Code).To(Equal(http.StatusConflict))
+			})
+
+			It("leaves the record unchanged", func() {
+				u, _ := users.FindByEmail(cfg, email)
+				Expect(u.FirstName).To(Equal("Zaphod"))
+			})
+		})
+
+		When("the patch changes a read-only field", func() {
+			BeforeEach(func() {
+				body = `{"firstName":"Arthur","email":"arthur@example.com"}`
+				request()
+			})
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+
+			It("names the field", func() {
+				errs := result["errors"].([]interface{})
+				Expect(errs[0].(map[string]interface{})["field"]).To(Equal("email"))
+				Expect(errs[0].(map[string]interface{})["code"]).To(Equal("read_only"))
+			})
+
+			It("leaves the record unchanged", func() {
+				u, _ := users.FindByEmail(cfg, email)
+				Expect(u.FirstName).To(Equal("Zaphod"))
+			})
+		})
+
+		When("the patch adds an unknown field", func() {
+			BeforeEach(func() {
+				body = `{"role":"admin"}`
+				request()
+			})
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+		})
+
+		When("the patched value is invalid", func() {
+			BeforeEach(func() {
+				body = `{"firstName":"` + strings.Repeat("x", 101) + `"}`
+				request()
+			})
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+		})
+
+		When("the patch is malformed", func() {
+			BeforeEach(func() {
+				body = `{"firstName":`
+				request()
+			})
+
+			It("is a bad request", func() {
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		When("the media type is not a patch", func() {
+			BeforeEach(func() {
+				contentType = "application/json"
+				request()
+			})
+
+			It("is unsupported", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnsupportedMediaType))
+			})
+		})
+
+		When("If-Match names the current version", func() {
+			BeforeEach(func() {
+				request()
+				r.Header.Set("If-Match", users.ETag(user))
+			})
+
+			It("is OK", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the new ETag", func() {
+				u, _ := users.FindByEmail(cfg, email)
+
+				Expect(u.Version).To(Equal(user.Version + 1))
+				Expect(rr.Header().Get("ETag")).To(Equal(users.ETag(u)))
+			})
+		})
+
+		When("If-Match names a stale version", func() {
+			BeforeEach(func() {
+				users.Update(cfg, &users.User{Email: email, FirstName: "Ford"})
+
+				request()
+				r.Header.Set("If-Match", users.ETag(user))
+			})
+
+			It("fails the precondition", func() {
+				Expect(rr.Code).To(Equal(http.StatusPreconditionFailed))
+			})
+
+			It("leaves the record unchanged", func() {
+				u, _ := users.FindByEmail(cfg, email)
+				Expect(u.FirstName).To(Equal("Ford"))
+			})
+		})
+
+		When("If-Match is required but missing", func() {
+			BeforeEach(func() {
+				cfg.Set("users.requireIfMatch", true)
+				request()
+			})
+
+			It("requires a precondition", func() {
+				Expect(rr.Code).To(Equal(http.StatusPreconditionRequired))
+			})
+		})
+	})
+
+	Describe("NewSearchHandler()", func() {
+		var query string
+
+		BeforeEach(func() {
+			handler = http.HandlerFunc(users.NewSearchHandler(cfg))
+			query = "?q=Beeblebrox+" + url.QueryEscape(email)
+		})
+
+		request := func() {
+			r, err = http.NewRequest("GET", "/users/search"+query, nil)
+
+			token, _ := security.NewTokenForSubject(cfg, user.PublicID)
+			r.Header.Set(cfg.GetString("tokenHeader"), token)
+		}
+
+		When("the query matches", func() {
+			BeforeEach(request)
+
+			It("is OK", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the matching users", func() {
+				json.Unmarshal(rr.Body.Bytes(), &result)
+				Expect(result["results"]).To(HaveLen(1))
+
+				sr := result["results"].([]interface{})[0].(map[string]interface{})
+				Expect(sr["user"]).To(HaveKeyWithValue("id", user.PublicID))
+				Expect(sr["snippet"]).To(ContainSubstring("<mark>"))
+			})
+		})
+
+		When("the query is missing", func() {
+			BeforeEach(func() {
+				query = ""
+				request()
+			})
+
+			It("is unprocessable", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+			})
+		})
+	})
 })
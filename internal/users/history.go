@@ -0,0 +1,180 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/caching"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	"github.com/gorilla/mux"
+)
+
+type RevisionPayload struct {
+	Number        uint            `json:"number"`
+	Actor         string          `json:"actor"`
+	ChangedFields json.RawMessage `json:"changedFields"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	User          json.RawMessage `json:"user"`
+}
+
+type historyPayload struct {
+	Revisions []RevisionPayload `json:"revisions"`
+	Next      string            `json:"next,omitempty"`
+}
+
+func NewRevisionPayload(rev *Revision) RevisionPayload {
+	return RevisionPayload{
+		Number:        rev.Number,
+		Actor:         rev.Actor,
+		ChangedFields: json.RawMessage(rev.ChangedFields),
+		CreatedAt:     rev.CreatedAt,
+		User:          json.RawMessage(rev.Snapshot),
+	}
+}
+
+// asOf returns the user as they were at the time the request's asOf
+// parameter names, or as they are when it has none.
+func asOf(cfg *config.Config, u *User, t *time.Time) (*User, error) {
+	if t == nil {
+		return u, nil
+	}
+
+	return At(cfg, u.ID, *t)
+}
+
+// writeHistory writes a page of the user's revisions, newest first.
+func writeHistory(cfg *config.Config, w http.ResponseWriter, r *http.Request, u *User) {
+	var errs validation.Errors
+
+	qs := r.URL.Query()
+	limit := parseLimit(cfg, qs, &errs)
+
+	if errs != nil {
+		validation.WriteErrors(w, errs)
+		return
+	}
+
+	if limit == 0 {
+		limit = cfg.GetInt("pagination.defaultLimit")
+	}
+
+	revs, next, err := History(cfg, u.ID, qs.Get("cursor"), limit)
+
+	if errors.Is(err, ErrInvalidCursor) {
+		validation.WriteErrors(w, validation.Errors{{
+			Field:   "cursor",
+			Code:    validation.CodeInvalid,
+			Message: err.Error(),
+		}})
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var (
+		data bytes.Buffer
+		lm   time.Time
+	)
+
+	rps := make([]RevisionPayload, 0, len(*revs))
+
+	for i := range *revs {
+		rps = append(rps, NewRevisionPayload(&(*revs)[i]))
+
+		if (*revs)[i].CreatedAt.After(lm) {
+			lm = (*revs)[i].CreatedAt
+		}
+	}
+
+	err = json.NewEncoder(&data).Encode(&historyPayload{
+		Revisions: rps,
+		Next:      next,
+	})
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, err = caching.Write(w, r, caching.WeakETag(data.Bytes()), lm, data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// NewGetCurrentHistoryHandler lists the revisions of the current user.
+func NewGetCurrentHistoryHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		u, err := CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		writeHistory(cfg, w, r, u)
+	}
+}
+
+// NewGetHistoryHandler lets admins list the revisions of any user of their
+// tenant.
+func NewGetHistoryHandler(cfg *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		cu, err := CurrentUser(cfg, r)
+
+		if err != nil {
+			log.Printf("Unable to identify user: %e", err)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		if !cu.IsAdmin() {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		u, err := FindByPublicID(cfg, mux.Vars(r)["id"])
+
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		inTenant, err := InTenant(cfg, u.ID, security.RequestTenant(r))
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !inTenant {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		writeHistory(cfg, w, r, u)
+	}
+}
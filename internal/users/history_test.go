@@ -0,0 +1,115 @@
+package users_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("users/history.go", func() {
+	var (
+		rr          *httptest.ResponseRecorder
+		cfg         *config.Config
+		admin, user *users.User
+		then        time.Time
+		result      map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		rr = httptest.NewRecorder()
+		result = nil
+
+		admin, _ = users.Create(cfg, &users.User{Email: faker.Email()})
+		users.SetRole(cfg, admin.Email, users.RoleAdmin)
+
+		user, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: "Arthur"})
+		then = time.Now()
+		users.Update(cfg, &users.User{Email: user.Email, FirstName: "Zaphod"})
+	})
+
+	serve := func(handler http.HandlerFunc, path string, as *users.User) {
+		r, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		token, _ := security.NewTokenForSubject(cfg, as.PublicID)
+		r.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		r = mux.SetURLVars(r, map[string]string{"id": user.PublicID})
+
+		handler.ServeHTTP(rr, r)
+		json.Unmarshal(rr.Body.Bytes(), &result)
+	}
+
+	asOf := func(t time.Time) string {
+		return "?asOf=" + url.QueryEscape(t.Format(time.RFC3339Nano))
+	}
+
+	Describe("NewGetCurrentHistoryHandler()", func() {
+		It("lists the current user's revisions, newest first", func() {
+			serve(users.NewGetCurrentHistoryHandler(cfg), "/users/me/history", user)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["revisions"]).To(HaveLen(2))
+
+			rev := result["revisions"].([]interface{})[0].(map[string]interface{})
+			Expect(rev["actor"]).To(Equal(user.PublicID))
+			Expect(rev["changedFields"]).To(ConsistOf("firstName"))
+			Expect(rev["user"]).To(HaveKeyWithValue("firstName", "Zaphod"))
+		})
+	})
+
+	Describe("NewGetHistoryHandler()", func() {
+		It("is forbidden to users who are not admins", func() {
+			serve(users.NewGetHistoryHandler(cfg), "/admin/users/"+user.PublicID+"/history", user)
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("lists the user's revisions for admins", func() {
+			serve(users.NewGetHistoryHandler(cfg), "/admin/users/"+user.PublicID+"/history?limit=1", admin)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["revisions"]).To(HaveLen(1))
+			Expect(result["next"]).To(Equal("2"))
+		})
+	})
+
+	Describe("asOf", func() {
+		It("reads the user as they were", func() {
+			serve(users.NewGetOneHandler(cfg), "/users/"+user.PublicID+asOf(then), admin)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["firstName"]).To(Equal("Arthur"))
+		})
+
+		It("reads the current user as they were", func() {
+			serve(users.NewGetCurrentHandler(cfg), "/users/me"+asOf(then), user)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(result["firstName"]).To(Equal("Arthur"))
+		})
+
+		It("does not find users before they were created", func() {
+			serve(users.NewGetCurrentHandler(cfg), "/users/me"+asOf(user.CreatedAt.Add(-time.Hour)), user)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("must be a timestamp", func() {
+			serve(users.NewGetCurrentHandler(cfg), "/users/me?asOf=yesterday", user)
+
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+	})
+})
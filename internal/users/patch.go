@@ -0,0 +1,212 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch     = errors.New("Invalid patch document")
+	ErrPatchConflict    = errors.New("Patch cannot be applied to the user")
+	ErrUnsupportedPatch = errors.New("Unsupported patch media type")
+)
+
+// writableFields are the UserPayload fields a user may change on themselves;
+// email changes must go through the confirmation flow instead.
+var writableFields = map[string]bool{
+	"firstName":  true,
+	"lastName":   true,
+	"attributes": true,
+}
+
+// applyPatch applies a merge patch (RFC 7396) or JSON patch (RFC 6902),
+// depending on the media type, to the JSON document.
+func applyPatch(mediaType string, doc, patch []byte) ([]byte, error) {
+	switch mediaType {
+	case MergePatchType:
+		if !json.Valid(patch) {
+			return nil, ErrInvalidPatch
+		}
+
+		out, err := jsonpatch.MergePatch(doc, patch)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+
+		return out, nil
+	case JSONPatchType:
+		p, err := jsonpatch.DecodePatch(patch)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+
+		out, err := p.Apply(doc)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPatchConflict, err)
+		}
+
+		return out, nil
+	default:
+		return nil, ErrUnsupportedPatch
+	}
+}
+
+// PatchUser applies the patch to the user's payload representation and copies
+// the writable fields back, failing with validation.Errors when the patch
+// touches anything else or leaves the user invalid.
+func PatchUser(cfg *config.Config, u *User, mediaType string, patch []byte) error {
+	doc, err := json.Marshal(NewUserPayload(u))
+
+	if err != nil {
+		return err
+	}
+
+	out, err := applyPatch(mediaType, doc, patch)
+
+	if err != nil {
+		return err
+	}
+
+	var before, after map[string]json.RawMessage
+
+	if err := json.Unmarshal(doc, &before); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(out, &after); err != nil {
+		return fmt.Errorf("%w: %v", ErrPatchConflict, err)
+	}
+
+	var errs validation.Errors
+
+	values := make(map[string]string)
+
+	for _, k := range changedFields(before, after) {
+		if !writableFields[k] {
+			errs = append(errs, validation.FieldError{
+				Field:   k,
+				Code:    validation.CodeReadOnly,
+				Message: fmt.Sprintf("%s cannot be changed", k),
+			})
+			continue
+		}
+
+		if k == "attributes" {
+			continue
+		}
+
+		var s *string
+
+		if raw, ok := after[k]; ok {
+			if err := json.Unmarshal(raw, &s); err != nil {
+				errs = append(errs, validation.FieldError{
+					Field:   k,
+					Code:    validation.CodeInvalid,
+					Message: fmt.Sprintf("%s must be a string", k),
+				})
+				continue
+			}
+		}
+
+		values[k] = ""
+
+		if s != nil {
+			values[k] = *s
+		}
+	}
+
+	if errs != nil {
+		return errs
+	}
+
+	up := NewUserPayload(u)
+
+	if v, ok := values["firstName"]; ok {
+		up.FirstName = v
+	}
+
+	if v, ok := values["lastName"]; ok {
+		up.LastName = v
+	}
+
+	errs = validation.Validate(&up)
+
+	// removing the attributes clears them
+	attrs, ok := after["attributes"]
+
+	if !ok {
+		attrs = json.RawMessage("null")
+	}
+
+	if sameJSON(before["attributes"], attrs) {
+		attrs = nil
+	}
+
+	stored, aerrs, err := ValidateAttributes(cfg, attrs)
+
+	if err != nil {
+		return err
+	}
+
+	errs = append(errs, aerrs...)
+
+	if errs != nil {
+		return errs
+	}
+
+	u.FirstName = up.FirstName
+	u.LastName = up.LastName
+
+	if stored != "" {
+		u.Attributes = stored
+	}
+
+	return nil
+}
+
+// changedFields lists the keys added, removed or changed between the two
+// documents.
+func changedFields(before, after map[string]json.RawMessage) []string {
+	var keys []string
+
+	for k, v := range after {
+		if bv, ok := before[k]; !ok || !sameJSON(bv, v) {
+			keys = append(keys, k)
+		}
+	}
+
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sameJSON(a, b json.RawMessage) bool {
+	var av, bv interface{}
+
+	if json.Unmarshal(a, &av) != nil || json.Unmarshal(b, &bv) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(av, bv)
+}
@@ -0,0 +1,75 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+var ErrPreconditionRequired = errors.New("An If-Match header is required")
+
+// ETag is the strong entity tag of the user's current representation.
+func ETag(u *User) string {
+	return fmt.Sprintf(`"%d"`, u.Version)
+}
+
+// projectedETag tags a representation trimmed by a projection.  It differs
+// from the full representation's tag but shares its version, so either may be
+// used in If-Match.
+func projectedETag(u *User, p Projection) string {
+	if p.IsZero() {
+		return ETag(u)
+	}
+
+	return fmt.Sprintf(`"%d;%s"`, u.Version, p.key())
+}
+
+// expectedVersion returns the version the request's If-Match header requires
+// the user to be at, or 0 when the request is unconditional.  It fails with
+// ErrVersionMismatch when no listed tag matches the user as loaded.
+func expectedVersion(cfg *config.Config, r *http.Request, u *User) (uint, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	if header == "" {
+		if cfg.GetBool("users.requireIfMatch") {
+			return 0, ErrPreconditionRequired
+		}
+
+		return 0, nil
+	}
+
+	if header == "*" {
+		return 0, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag == ETag(u) || strings.HasPrefix(tag, strings.TrimSuffix(ETag(u), `"`)+";") {
+			return u.Version, nil
+		}
+	}
+
+	return 0, ErrVersionMismatch
+}
+
+// updateStatus maps the errors of a conditional update to a response status.
+// A lost race on an unconditional request is a conflict rather than a failed
+// precondition, since the client did not state one.
+func updateStatus(r *http.Request, err error) int {
+	switch {
+	case errors.Is(err, ErrPreconditionRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, ErrVersionMismatch) && r.Header.Get("If-Match") != "":
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrVersionMismatch):
+		return http.StatusConflict
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,243 @@
+package users
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+)
+
+// baseColumns are read for every projection: the primary key for includes
+// and the columns behind the ETag and Last-Modified headers.
+var baseColumns = []string{"id", "version", "updated_at"}
+
+// fieldColumns maps each UserPayload field to the column it is read from.
+var fieldColumns = map[string]string{
+	"id":         "public_id",
+	"email":      "email",
+	"firstName":  "first_name",
+	"lastName":   "last_name",
+	"avatarUrl":  "avatar",
+	"attributes": "attributes",
+}
+
+// fieldOrder lists the UserPayload fields as they appear in the payload.
+var fieldOrder = []string{"id", "email", "firstName", "lastName", "avatarUrl", "attributes"}
+
+// Includer loads related data for a batch of users, keyed by their primary
+// key, to embed in their payloads.  It loads only what the tenant the request
+// acts in may see.
+type Includer func(cfg *config.Config, tenant uint, us []User) (map[uint]interface{}, error)
+
+type include struct {
+	columns []string
+	load    Includer
+}
+
+var (
+	includesMu sync.RWMutex
+	includes   = map[string]include{}
+)
+
+// RegisterInclude makes related data available to ?include=name, loaded by
+// fn from users read with at least the given columns.
+func RegisterInclude(name string, columns []string, fn Includer) {
+	includesMu.Lock()
+	defer includesMu.Unlock()
+
+	includes[name] = include{columns: columns, load: fn}
+}
+
+func lookupInclude(name string) (include, bool) {
+	includesMu.RLock()
+	defer includesMu.RUnlock()
+
+	inc, ok := includes[name]
+
+	return inc, ok
+}
+
+func includeNames() []string {
+	includesMu.RLock()
+	defer includesMu.RUnlock()
+
+	names := make([]string, 0, len(includes))
+
+	for name := range includes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func init() {
+	RegisterInclude("roles", []string{"role"}, func(cfg *config.Config, tenant uint, us []User) (map[uint]interface{}, error) {
+		roles := make(map[uint]interface{}, len(us))
+
+		for _, u := range us {
+			roles[u.ID] = []string{u.Role}
+		}
+
+		return roles, nil
+	})
+}
+
+// payloadValues maps each UserPayload field to its value.
+func payloadValues(up UserPayload) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         up.ID,
+		"email":      up.Email,
+		"firstName":  up.FirstName,
+		"lastName":   up.LastName,
+		"avatarUrl":  up.AvatarURL,
+		"attributes": up.Attributes,
+	}
+}
+
+// Projection is the part of a user payload a client asked for: a subset of
+// its fields, when Fields is not empty, and any related data to include.
+type Projection struct {
+	Fields  []string
+	Include []string
+}
+
+// IsZero reports whether the projection asks for the plain payload.
+func (p Projection) IsZero() bool {
+	return len(p.Fields) == 0 && len(p.Include) == 0
+}
+
+func (p Projection) fields() []string {
+	if len(p.Fields) == 0 {
+		return fieldOrder
+	}
+
+	return p.Fields
+}
+
+// Columns lists the columns the projection needs read, or none when every
+// column is needed.
+func (p Projection) Columns() []string {
+	if p.IsZero() {
+		return nil
+	}
+
+	columns := withColumns(nil, baseColumns...)
+
+	for _, f := range p.fields() {
+		columns = withColumns(columns, fieldColumns[f])
+	}
+
+	for _, name := range p.Include {
+		inc, _ := lookupInclude(name)
+		columns = withColumns(columns, inc.columns...)
+	}
+
+	return columns
+}
+
+// key identifies the projection, to distinguish the representations it
+// produces.
+func (p Projection) key() string {
+	return strings.Join(p.Fields, ",") + "+" + strings.Join(p.Include, ",")
+}
+
+// Render builds the payloads of the users, trimmed to the projection's fields
+// and with the related data it includes as seen by the tenant.
+func (p Projection) Render(cfg *config.Config, tenant uint, us []User) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(us))
+
+	if p.IsZero() {
+		for i := range us {
+			out = append(out, NewUserPayload(&us[i]))
+		}
+
+		return out, nil
+	}
+
+	loaded := make(map[string]map[uint]interface{}, len(p.Include))
+
+	for _, name := range p.Include {
+		inc, _ := lookupInclude(name)
+
+		data, err := inc.load(cfg, tenant, us)
+
+		if err != nil {
+			return nil, err
+		}
+
+		loaded[name] = data
+	}
+
+	for i := range us {
+		values := payloadValues(NewUserPayload(&us[i]))
+
+		m := make(map[string]interface{}, len(p.fields())+len(p.Include))
+
+		for _, f := range p.fields() {
+			m[f] = values[f]
+		}
+
+		for _, name := range p.Include {
+			m[name] = loaded[name][us[i].ID]
+		}
+
+		out = append(out, m)
+	}
+
+	return out, nil
+}
+
+// splitList reads a comma separated parameter, dropping empty and repeated
+// entries.
+func splitList(v string) []string {
+	var items []string
+
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+
+		if item != "" && !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// parseProjection reads the fields and include parameters of a request.
+func parseProjection(qs url.Values, errs *validation.Errors) Projection {
+	p := Projection{
+		Fields:  splitList(qs.Get("fields")),
+		Include: splitList(qs.Get("include")),
+	}
+
+	for _, f := range p.Fields {
+		if _, ok := fieldColumns[f]; !ok {
+			*errs = append(*errs, validation.FieldError{
+				Field:   "fields",
+				Code:    validation.CodeInvalid,
+				Message: fmt.Sprintf("'%s' is not a field; fields must be among %s", f, strings.Join(fieldOrder, ", ")),
+			})
+		}
+	}
+
+	for _, name := range p.Include {
+		if _, ok := lookupInclude(name); !ok {
+			*errs = append(*errs, validation.FieldError{
+				Field:   "include",
+				Code:    validation.CodeInvalid,
+				Message: fmt.Sprintf("'%s' cannot be included; include must be among %s", name, strings.Join(includeNames(), ", ")),
+			})
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,89 @@
+package users
+
+import (
+	"net/url"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/validation"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("users/projection.go", func() {
+	var (
+		cfg  *config.Config
+		errs validation.Errors
+		u    User
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		errs = nil
+		u = User{PublicID: NewPublicID(), Email: "ford@example.com", FirstName: "Ford", LastName: "Prefect", Role: RoleAdmin}
+	})
+
+	Describe("parseProjection()", func() {
+		It("reads fields and includes", func() {
+			p := parseProjection(url.Values{"fields": {"email, firstName,email"}, "include": {"roles"}}, &errs)
+
+			Expect(errs).To(BeNil())
+			Expect(p.Fields).To(Equal([]string{"email", "firstName"}))
+			Expect(p.Include).To(Equal([]string{"roles"}))
+		})
+
+		It("rejects unknown fields", func() {
+			parseProjection(url.Values{"fields": {"email,password"}}, &errs)
+
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("fields"))
+		})
+
+		It("rejects unknown includes", func() {
+			parseProjection(url.Values{"include": {"sessions"}}, &errs)
+
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("include"))
+		})
+	})
+
+	Describe("Projection.Columns()", func() {
+		It("reads every column without a projection", func() {
+			Expect(Projection{}.Columns()).To(BeEmpty())
+		})
+
+		It("reads only the requested fields' columns", func() {
+			columns := Projection{Fields: []string{"email"}}.Columns()
+
+			Expect(columns).To(ContainElements("id", "version", "updated_at", "email"))
+			Expect(columns).NotTo(ContainElements("first_name", "last_name", "public_id"))
+		})
+
+		It("reads the columns includes need", func() {
+			columns := Projection{Fields: []string{"email"}, Include: []string{"roles"}}.Columns()
+
+			Expect(columns).To(ContainElement("role"))
+		})
+	})
+
+	Describe("Projection.Render()", func() {
+		It("renders the plain payload without a projection", func() {
+			out, err := Projection{}.Render(cfg, 0, []User{u})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out[0]).To(Equal(NewUserPayload(&u)))
+		})
+
+		It("trims the payload to the requested fields", func() {
+			out, _ := Projection{Fields: []string{"firstName"}}.Render(cfg, 0, []User{u})
+
+			Expect(out[0]).To(Equal(map[string]interface{}{"firstName": "Ford"}))
+		})
+
+		It("embeds included data", func() {
+			out, _ := Projection{Include: []string{"roles"}}.Render(cfg, 0, []User{u})
+
+			Expect(out[0]).To(HaveKeyWithValue("roles", []string{RoleAdmin}))
+			Expect(out[0]).To(HaveKeyWithValue("lastName", "Prefect"))
+		})
+	})
+})
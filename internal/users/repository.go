@@ -1,24 +1,306 @@
 package users
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/adamstrickland/dapper-api/internal"
 	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/oklog/ulid/v2"
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	gorm.Model
-	ID                  uint   `gorm:"primaryKey"`
+	PublicID            string `gorm:"uniqueIndex"`
 	Email               string `gorm:"uniqueIndex"`
 	UnencryptedPassword string
 	FirstName           string
 	LastName            string
+	Role                string `gorm:"not null;default:user"`
+	Version             uint   `gorm:"not null;default:1"`
+	Avatar              string
+	Attributes          string `gorm:"type:text;not null;default:'{}'"`
+	ExternalID          string `gorm:"index"`
+}
+
+// NewPublicID returns a ULID: unique, not guessable, and safe to expose in
+// URLs and tokens in place of the sequential primary key.
+func NewPublicID() string {
+	return ulid.Make().String()
+}
+
+// IsPublicID reports whether s is shaped like an ID from NewPublicID.
+func IsPublicID(s string) bool {
+	_, err := ulid.ParseStrict(s)
+
+	return err == nil
+}
+
+// BeforeSave also covers users created before public IDs were introduced that
+// have not been backfilled yet.
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	if u.PublicID == "" {
+		u.PublicID = NewPublicID()
+	}
+
+	return nil
+}
+
+// BackfillPublicIDs assigns a public ID to every user created before they
+// were introduced, returning the number of users updated.
+func BackfillPublicIDs(cfg *config.Config) (int, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return 0, err
+	}
+
+	var ids []uint
+
+	result := db.Model(&User{}).
+		Unscoped().
+		Where("public_id IS NULL OR public_id = ''").
+		Pluck("id", &ids)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	for _, id := range ids {
+		result = db.Model(&User{}).
+			Unscoped().
+			Where("id = ?", id).
+			UpdateColumn("public_id", NewPublicID())
+
+		if result.Error != nil {
+			log.Printf("Unable to backfill public ID of User %d: %e", id, result.Error)
+			return 0, result.Error
+		}
+	}
+
+	return len(ids), nil
+}
+
+// userColumns lists every column of users, which AutoIncrementIDs copies by
+// name.  A column added to User must be added here and to
+// createUsersAutoIncrement too, or the rebuild refuses to run.
+var userColumns = []string{
+	"id", "created_at", "updated_at", "deleted_at", "public_id", "email",
+	"unencrypted_password", "first_name", "last_name", "role", "version",
+	"avatar", "attributes", "external_id",
+}
+
+// createUsersAutoIncrement creates users as AutoMigrate does, but with an
+// AUTOINCREMENT primary key.
+const createUsersAutoIncrement = "CREATE TABLE `users_autoincrement` (" +
+	"`id` integer PRIMARY KEY AUTOINCREMENT," +
+	"`created_at` datetime," +
+	"`updated_at` datetime," +
+	"`deleted_at` datetime," +
+	"`public_id` text," +
+	"`email` text," +
+	"`unencrypted_password` text," +
+	"`first_name` text," +
+	"`last_name` text," +
+	"`role` text NOT NULL DEFAULT \"user\"," +
+	"`version` integer NOT NULL DEFAULT 1," +
+	"`avatar` text," +
+	"`attributes` text NOT NULL DEFAULT \"{}\"," +
+	"`external_id` text)"
+
+// AutoIncrementIDs rebuilds the users table with an AUTOINCREMENT primary key
+// where it was created without one, reporting whether it did.  Without it
+// SQLite hands the ID of the last user erased to the next one created, who
+// then inherits the rows of other tables still referring to that ID.
+func AutoIncrementIDs(cfg *config.Config) (bool, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return false, err
+	}
+
+	var create string
+
+	if err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'users'").Scan(&create).Error; err != nil {
+		return false, err
+	}
+
+	if create == "" || strings.Contains(create, "AUTOINCREMENT") {
+		return false, nil
+	}
+
+	columns := strings.Join(userColumns, ", ")
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().AutoMigrate(&User{}); err != nil {
+			return err
+		}
+
+		cts, err := tx.Migrator().ColumnTypes("users")
+
+		if err != nil {
+			return err
+		}
+
+		known := make(map[string]bool, len(userColumns))
+
+		for _, c := range userColumns {
+			known[c] = true
+		}
+
+		for _, ct := range cts {
+			if !known[ct.Name()] {
+				return fmt.Errorf("Unknown users column '%s' would be dropped", ct.Name())
+			}
+		}
+
+		var before, after int64
+
+		if err := tx.Raw("SELECT COUNT(*) FROM users").Scan(&before).Error; err != nil {
+			return err
+		}
+
+		for _, stmt := range []string{
+			createUsersAutoIncrement,
+			"INSERT INTO users_autoincrement (" + columns + ") SELECT " + columns + " FROM users",
+			"DROP TABLE users",
+			"ALTER TABLE users_autoincrement RENAME TO users",
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Raw("SELECT COUNT(*) FROM users").Scan(&after).Error; err != nil {
+			return err
+		}
+
+		if after != before {
+			return fmt.Errorf("Copied %d of %d users", after, before)
+		}
+
+		// the indexes and search index triggers went with the old table
+		if err := tx.Migrator().AutoMigrate(&User{}); err != nil {
+			return err
+		}
+
+		_, err = ensureSearchIndex(tx)
+
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Unable to rebuild users table: %e", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Changed adds to the columns a change to a user writes the version bump
+// and update time every change makes, so that the user's ETag moves with it.
+// Every write to a user's row goes through it.
+func Changed(columns map[string]interface{}) map[string]interface{} {
+	columns["version"] = gorm.Expr("version + 1")
+	columns["updated_at"] = time.Now()
+
+	return columns
 }
 
+func SetRole(cfg *config.Config, email, role string) (*User, error) {
+	u, err := FindByEmail(cfg, email)
+
+	if err != nil {
+		log.Printf("Unable to find user with email '%s': %e", email, err)
+		return nil, err
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).
+			Where("id = ?", u.ID).
+			UpdateColumns(Changed(map[string]interface{}{"role": role}))
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return RecordRevision(tx, u.ID, ActorSystem)
+	})
+
+	if err != nil {
+		log.Printf("Unable to update User record: %e", err)
+		return nil, err
+	}
+
+	return FindByEmail(cfg, email)
+}
+
+// updateProfile writes the user's profile fields, and its attributes unless
+// they are empty, bumps its version and records the revision, but only while
+// the stored user is still at the given version.
+func updateProfile(db *gorm.DB, id uint, version uint, u *User) (bool, error) {
+	columns := Changed(map[string]interface{}{
+		"first_name": u.FirstName,
+		"last_name":  u.LastName,
+	})
+
+	if u.Attributes != "" {
+		columns["attributes"] = u.Attributes
+	}
+
+	updated := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).
+			Where("id = ? AND version = ?", id, version).
+			UpdateColumns(columns)
+
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		updated = true
+
+		return RecordRevision(tx, id, "")
+	})
+
+	if err != nil {
+		log.Printf("Unable to update User record: %e", err)
+		return false, err
+	}
+
+	return updated, nil
+}
+
+// Update changes the profile of the user with the same email.  When u carries
+// a version the change only applies while the stored user is still at that
+// version; otherwise it applies to the version just read.
 func Update(cfg *config.Config, u *User) (*User, error) {
 	uu, err := FindByEmail(cfg, u.Email)
 
@@ -27,9 +309,38 @@ func Update(cfg *config.Config, u *User) (*User, error) {
 		return nil, err
 	}
 
-	uu.FirstName = u.FirstName
-	uu.LastName = u.LastName
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	version := u.Version
+
+	if version == 0 {
+		version = uu.Version
+	}
+
+	ok, err := updateProfile(db.DB, uu.ID, version, u)
 
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrVersionMismatch
+	}
+
+	return FindByEmail(cfg, u.Email)
+}
+
+// Modify loads the user with the given primary key, applies fn to it and
+// writes back its profile fields.  The write is conditional on the user not
+// having changed since it was loaded, and, when version is non-zero, on it
+// being at that version.  Any error returned by fn aborts the change and is
+// returned as is.
+func Modify(cfg *config.Config, id uint, version uint, fn func(u *User) error) (*User, error) {
 	db, err := internal.NewConnection(cfg)
 
 	if err != nil {
@@ -37,18 +348,90 @@ func Update(cfg *config.Config, u *User) (*User, error) {
 		return nil, err
 	}
 
-	result := db.Save(uu)
+	var user User
+
+	result := db.Where("id = ?", id).Limit(1).Find(&user)
 
 	if result.Error != nil {
-		log.Printf("Unable to update User record: %e", result.Error)
 		return nil, result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return u, nil
+		return nil, ErrNotFound
+	}
+
+	if version != 0 && user.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	if err := fn(&user); err != nil {
+		return nil, err
+	}
+
+	ok, err := updateProfile(db.DB, id, user.Version, &user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrVersionMismatch
+	}
+
+	user.Version++
+
+	return &user, nil
+}
+
+// SetAvatar points the user at a newly stored avatar, returning the one it
+// replaces so that its files can be removed.
+func SetAvatar(cfg *config.Config, id uint, avatar string) (string, *User, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return "", nil, err
 	}
 
-	return uu, nil
+	var (
+		user User
+		old  string
+	)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Limit(1).Find(&user)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		old = user.Avatar
+
+		result = tx.Model(&User{}).
+			Where("id = ?", id).
+			UpdateColumns(Changed(map[string]interface{}{"avatar": avatar}))
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if err := RecordRevision(tx, id, ""); err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", id).Limit(1).Find(&user).Error
+	})
+
+	if err != nil {
+		log.Printf("Unable to set avatar of User %d: %e", id, err)
+		return "", nil, err
+	}
+
+	return old, &user, nil
 }
 
 func Create(cfg *config.Config, u *User) (*User, error) {
@@ -70,17 +453,77 @@ func Create(cfg *config.Config, u *User) (*User, error) {
 		return nil, errors.New(fmt.Sprintf("Found extant User record with email '%s'", u.Email))
 	}
 
-	result := db.Create(u)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+
+		return RecordRevision(tx, u.ID, "")
+	})
+
+	if err != nil {
+		log.Printf("Unable to create User record: %e", err)
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// selectColumns limits a query to the given columns, or leaves it reading
+// every column when there are none.
+func selectColumns(db *gorm.DB, columns []string) *gorm.DB {
+	if len(columns) == 0 {
+		return db
+	}
+
+	return db.Select(columns)
+}
+
+// withColumns adds the extra columns that are not already listed.
+func withColumns(columns []string, extra ...string) []string {
+	out := append([]string{}, columns...)
+
+	for _, e := range extra {
+		found := false
+
+		for _, c := range out {
+			if c == e {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+func FindByEmail(cfg *config.Config, email string, columns ...string) (*User, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var user User
+	result := selectColumns(db.DB, columns).Where("email = ?", email).Limit(1).Find(&user)
 
 	if result.Error != nil {
-		log.Printf("Unable to create User record: %e", result.Error)
 		return nil, result.Error
 	}
 
-	return u, nil
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &user, nil
 }
 
-func FindByEmail(cfg *config.Config, email string) (*User, error) {
+func FindByPublicID(cfg *config.Config, id string, columns ...string) (*User, error) {
 	db, err := internal.NewConnection(cfg)
 
 	if err != nil {
@@ -89,19 +532,29 @@ func FindByEmail(cfg *config.Config, email string) (*User, error) {
 	}
 
 	var user User
-	result := db.Where("email = ?", email).Limit(1).Find(&user)
+	result := selectColumns(db.DB, columns).Where("public_id = ?", id).Limit(1).Find(&user)
 
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return nil, errors.New("No record found")
+		return nil, ErrNotFound
 	}
 
 	return &user, nil
 }
 
+// FindBySubject returns the user a token was issued to.  Tokens issued before
+// public IDs were introduced carry the user's email as their subject.
+func FindBySubject(cfg *config.Config, subj string, columns ...string) (*User, error) {
+	if IsPublicID(subj) {
+		return FindByPublicID(cfg, subj, columns...)
+	}
+
+	return FindByEmail(cfg, subj, columns...)
+}
+
 func All(cfg *config.Config) (*[]User, error) {
 	db, err := internal.NewConnection(cfg)
 
@@ -120,3 +573,294 @@ func All(cfg *config.Config) (*[]User, error) {
 
 	return &users, nil
 }
+
+// Each calls fn with every user matching the query's filters, in primary key
+// order, reading them from a database cursor one at a time.  Its pagination
+// and sort are ignored.
+func Each(cfg *config.Config, q Query, fn func(u *User) error) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	rows, err := selectColumns(filterQuery(db.Model(&User{}), q), q.Columns).
+		Order("id ASC").
+		Rows()
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var u User
+
+		if err := db.ScanRows(rows, &u); err != nil {
+			return err
+		}
+
+		if err := fn(&u); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// sortColumns whitelists the keys a page of users may be sorted by.
+var sortColumns = map[string]string{
+	"createdAt": "created_at",
+	"email":     "email",
+	"firstName": "first_name",
+	"lastName":  "last_name",
+}
+
+// Query selects a page of users.  Sort names a key in sortColumns, prefixed
+// with "-" for descending order; Cursor continues a previous page.  Tenant,
+// when set, limits the users to those of one tenant.
+type Query struct {
+	ID            uint
+	Tenant        *uint
+	Limit         int
+	Cursor        string
+	Email         string
+	Name          string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Sort          string
+	Attributes    map[string]string
+	Columns       []string
+}
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeCursor(c *cursor) string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+var (
+	ErrNotFound      = errors.New("No record found")
+	ErrInvalidSort   = errors.New("Invalid sort key")
+	ErrInvalidCursor = errors.New("Invalid cursor")
+
+	ErrVersionMismatch = errors.New("User has been modified")
+)
+
+// AttributeKey matches the attribute names a page of users may be filtered by.
+var AttributeKey = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// attributeMatches lists the JSON values an attribute filter matches: the
+// value as a string, and as a number, boolean or null when it reads as one.
+func attributeMatches(v string) []string {
+	s, _ := json.Marshal(v)
+	matches := []string{string(s)}
+
+	var scalar interface{}
+
+	if err := json.Unmarshal([]byte(v), &scalar); err == nil {
+		switch scalar.(type) {
+		case float64, bool, nil:
+			canonical, _ := json.Marshal(scalar)
+			matches = append(matches, string(canonical))
+		}
+	}
+
+	return matches
+}
+
+func parseSort(sort string) (string, string, bool, error) {
+	key := strings.TrimPrefix(sort, "-")
+	col, ok := sortColumns[key]
+
+	if !ok {
+		return "", "", false, ErrInvalidSort
+	}
+
+	return key, col, strings.HasPrefix(sort, "-"), nil
+}
+
+func sortValue(u *User, key string) string {
+	switch key {
+	case "email":
+		return u.Email
+	case "firstName":
+		return u.FirstName
+	case "lastName":
+		return u.LastName
+	default:
+		return u.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
+
+func cursorValue(c *cursor, key string) (interface{}, error) {
+	if key != "createdAt" {
+		return c.Value, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return t, nil
+}
+
+// filterQuery narrows a query for users to those matching the query's
+// filters.
+func filterQuery(query *gorm.DB, q Query) *gorm.DB {
+	query = tenantScope(query, q.Tenant)
+
+	if q.ID != 0 {
+		query = query.Where("id = ?", q.ID)
+	}
+
+	if q.Email != "" {
+		query = query.Where(`email LIKE ? ESCAPE '\'`, "%"+internal.EscapeLike(q.Email)+"%")
+	}
+
+	if q.Name != "" {
+		name := "%" + internal.EscapeLike(q.Name) + "%"
+		query = query.Where(`(first_name LIKE ? ESCAPE '\' OR last_name LIKE ? ESCAPE '\')`, name, name)
+	}
+
+	if q.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *q.CreatedAfter)
+	}
+
+	if q.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *q.CreatedBefore)
+	}
+
+	for _, k := range sortedKeys(q.Attributes) {
+		query = query.Where(
+			"CASE WHEN json_valid(attributes) THEN attributes -> ? END IN ?",
+			"$."+k, attributeMatches(q.Attributes[k]),
+		)
+	}
+
+	return query
+}
+
+// Page returns the users matching the query, along with a cursor for the
+// next page (empty when this is the last one).
+func Page(cfg *config.Config, q Query) (*[]User, string, error) {
+	if q.Sort == "" {
+		q.Sort = "-createdAt"
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = cfg.GetInt("pagination.defaultLimit")
+	}
+
+	key, col, desc, err := parseSort(q.Sort)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, "", err
+	}
+
+	query := filterQuery(db.Model(&User{}), q)
+
+	if len(q.Columns) > 0 {
+		query = query.Select(withColumns(q.Columns, col, "id"))
+	}
+
+	dir, op := "ASC", ">"
+
+	if desc {
+		dir, op = "DESC", "<"
+	}
+
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		if c.Sort != q.Sort {
+			return nil, "", ErrInvalidCursor
+		}
+
+		v, err := cursorValue(c, key)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		query = query.Where(
+			fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", col, op, col, op),
+			v, v, c.ID,
+		)
+	}
+
+	var users []User
+
+	result := query.
+		Order(fmt.Sprintf("%s %s, id %s", col, dir, dir)).
+		Limit(q.Limit + 1).
+		Find(&users)
+
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	next := ""
+
+	if len(users) > q.Limit {
+		users = users[:q.Limit]
+		last := &users[len(users)-1]
+
+		next = encodeCursor(&cursor{
+			Sort:  q.Sort,
+			Value: sortValue(last, key),
+			ID:    last.ID,
+		})
+	}
+
+	return &users, next, nil
+}
@@ -1,6 +1,11 @@
 package users
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
 	"github.com/adamstrickland/dapper-api/internal"
 	"github.com/adamstrickland/dapper-api/internal/config"
 	"github.com/bxcodec/faker/v3"
@@ -75,11 +80,143 @@ var _ = Describe("users/repository.go", func() {
 		})
 	})
 
+	Describe("Create()", func() {
+		It("assigns a public ID", func() {
+			u, err := Create(cfg, &User{Email: email})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(IsPublicID(u.PublicID)).To(BeTrue())
+		})
+	})
+
+	Describe("AutoIncrementIDs()", func() {
+		var (
+			dir         string
+			legacy      *config.Config
+			conn        *internal.Conn
+			first, gone User
+		)
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "users")
+
+			legacy = config.Configuration()
+			legacy.Set("databaseUrl", "sqlite:"+filepath.Join(dir, "legacy.sqlite3"))
+
+			conn, _ = internal.NewConnection(legacy)
+			Expect(conn.AutoMigrate(&User{})).To(Succeed())
+
+			first = User{Email: faker.Email(), FirstName: "Ford", Role: RoleAdmin, Avatar: "avatars/ford", Attributes: `{"towel":true}`, ExternalID: "ext-1"}
+			gone = User{Email: faker.Email()}
+
+			Expect(conn.Create(&first).Error).NotTo(HaveOccurred())
+			Expect(conn.Create(&gone).Error).NotTo(HaveOccurred())
+			Expect(conn.Delete(&gone).Error).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("copies every user, closed or not, with all of their columns", func() {
+			rebuilt, err := AutoIncrementIDs(legacy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rebuilt).To(BeTrue())
+
+			var u User
+			Expect(conn.Where("id = ?", first.ID).Take(&u).Error).NotTo(HaveOccurred())
+			Expect(u.PublicID).To(Equal(first.PublicID))
+			Expect(u.Email).To(Equal(first.Email))
+			Expect(u.FirstName).To(Equal("Ford"))
+			Expect(u.Role).To(Equal(RoleAdmin))
+			Expect(u.Avatar).To(Equal("avatars/ford"))
+			Expect(u.Attributes).To(Equal(`{"towel":true}`))
+			Expect(u.ExternalID).To(Equal("ext-1"))
+
+			var closed User
+			Expect(conn.Unscoped().Where("id = ?", gone.ID).Take(&closed).Error).NotTo(HaveOccurred())
+			Expect(closed.DeletedAt.Valid).To(BeTrue())
+		})
+
+		It("keeps the indexes", func() {
+			AutoIncrementIDs(legacy)
+
+			Expect(conn.Create(&User{Email: first.Email}).Error).To(HaveOccurred())
+		})
+
+		It("keeps the IDs of erased users from being reused", func() {
+			AutoIncrementIDs(legacy)
+
+			conn.Unscoped().Delete(&gone)
+
+			u := User{Email: faker.Email()}
+			Expect(conn.Create(&u).Error).NotTo(HaveOccurred())
+			Expect(u.ID).To(BeNumerically(">", gone.ID))
+		})
+
+		It("leaves the table as it is once rebuilt", func() {
+			AutoIncrementIDs(legacy)
+
+			rebuilt, err := AutoIncrementIDs(legacy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rebuilt).To(BeFalse())
+		})
+
+		It("refuses to drop columns it does not know", func() {
+			conn.Exec("ALTER TABLE users ADD COLUMN shoe_size integer")
+
+			_, err := AutoIncrementIDs(legacy)
+			Expect(err).To(HaveOccurred())
+
+			var n int64
+			conn.Raw("SELECT COUNT(*) FROM users").Scan(&n)
+			Expect(n).To(BeNumerically("==", 2))
+		})
+	})
+
+	Describe("FindBySubject()", func() {
+		var u *User
+
+		BeforeEach(func() {
+			u, _ = Create(cfg, &User{Email: email})
+		})
+
+		It("finds the user by public ID", func() {
+			found, err := FindBySubject(cfg, u.PublicID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.ID).To(Equal(u.ID))
+		})
+
+		It("finds the user by a legacy email subject", func() {
+			found, err := FindBySubject(cfg, email)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.ID).To(Equal(u.ID))
+		})
+
+		It("returns an error for unknown subjects", func() {
+			_, err := FindBySubject(cfg, NewPublicID())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("BackfillPublicIDs()", func() {
+		BeforeEach(func() {
+			db.Exec(insert, email)
+		})
+
+		It("assigns public IDs to users without one", func() {
+			_, err := BackfillPublicIDs(cfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			u, _ := FindByEmail(cfg, email)
+			Expect(IsPublicID(u.PublicID)).To(BeTrue())
+		})
+	})
+
 	Describe("All()", func() {
 		It("returns a slice containing each entry", func() {
 			db.Exec(insert, email)
 
-			db.Raw("SELECT COUNT(*) FROM users").Scan(&count)
+			db.Raw("SELECT COUNT(*) FROM users WHERE deleted_at IS NULL").Scan(&count)
 			Expect(count).To(BeNumerically(">", 0))
 
 			us, err := All(cfg)
@@ -145,6 +282,161 @@ var _ = Describe("users/repository.go", func() {
 				_, err := Update(cfg, nu)
 				Expect(err).NotTo(HaveOccurred())
 			})
+
+			It("bumps the version", func() {
+				before, _ := FindByEmail(cfg, email)
+				uu, _ := Update(cfg, nu)
+
+				Expect(uu.Version).To(Equal(before.Version + 1))
+			})
+
+			When("the given version is stale", func() {
+				JustBeforeEach(func() {
+					before, _ := FindByEmail(cfg, email)
+					nu.Version = before.Version + 1
+				})
+
+				It("returns an error", func() {
+					_, err := Update(cfg, nu)
+					Expect(err).To(MatchError(ErrVersionMismatch))
+				})
+
+				It("does not update the record", func() {
+					Update(cfg, nu)
+
+					uu, _ := FindByEmail(cfg, email)
+					Expect(uu.FirstName).NotTo(Equal(nu.FirstName))
+				})
+			})
+		})
+	})
+
+	Describe("SetRole()", func() {
+		BeforeEach(func() {
+			db.Exec(insert, email)
+		})
+
+		It("defaults to the user role", func() {
+			u, _ := FindByEmail(cfg, email)
+			Expect(u.Role).To(Equal(RoleUser))
+			Expect(u.IsAdmin()).To(BeFalse())
+		})
+
+		It("changes the role", func() {
+			_, err := SetRole(cfg, email, RoleAdmin)
+			Expect(err).NotTo(HaveOccurred())
+
+			u, _ := FindByEmail(cfg, email)
+			Expect(u.IsAdmin()).To(BeTrue())
+		})
+
+		It("bumps the version", func() {
+			before, _ := FindByEmail(cfg, email)
+
+			u, err := SetRole(cfg, email, RoleAdmin)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(u.Version).To(Equal(before.Version + 1))
+		})
+	})
+
+	Describe("Page()", func() {
+		var (
+			name string
+			q    Query
+		)
+
+		BeforeEach(func() {
+			name = faker.UUIDDigit()
+
+			for _, fn := range []string{"Charlie", "Alice", "Bob"} {
+				_, err := Create(cfg, &User{Email: faker.Email(), FirstName: fn, LastName: name})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			q = Query{Name: name, Sort: "firstName", Limit: 2}
+		})
+
+		It("returns the first page and a cursor", func() {
+			us, next, err := Page(cfg, q)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*us).To(HaveLen(2))
+			Expect((*us)[0].FirstName).To(Equal("Alice"))
+			Expect((*us)[1].FirstName).To(Equal("Bob"))
+			Expect(next).NotTo(BeEmpty())
+		})
+
+		It("continues from the cursor", func() {
+			_, next, _ := Page(cfg, q)
+
+			q.Cursor = next
+			us, next, err := Page(cfg, q)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*us).To(HaveLen(1))
+			Expect((*us)[0].FirstName).To(Equal("Charlie"))
+			Expect(next).To(BeEmpty())
+		})
+
+		It("sorts in descending order", func() {
+			q.Sort = "-firstName"
+
+			us, _, _ := Page(cfg, q)
+			Expect((*us)[0].FirstName).To(Equal("Charlie"))
+		})
+
+		It("pages by creation time", func() {
+			q.Sort = "-createdAt"
+			q.Limit = 1
+			seen := make([]string, 0)
+
+			for {
+				us, next, err := Page(cfg, q)
+				Expect(err).NotTo(HaveOccurred())
+
+				for _, u := range *us {
+					seen = append(seen, u.FirstName)
+				}
+
+				if next == "" {
+					break
+				}
+
+				q.Cursor = next
+			}
+
+			Expect(seen).To(Equal([]string{"Bob", "Alice", "Charlie"}))
+		})
+
+		It("matches wildcards in the name filter literally", func() {
+			q.Name = "_" + name[1:]
+
+			us, _, err := Page(cfg, q)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*us).To(BeEmpty())
+		})
+
+		It("filters by creation time", func() {
+			future := time.Now().Add(time.Hour)
+			q.CreatedAfter = &future
+
+			us, _, _ := Page(cfg, q)
+			Expect(*us).To(BeEmpty())
+		})
+
+		It("rejects unknown sort keys", func() {
+			q.Sort = "unencryptedPassword"
+
+			_, _, err := Page(cfg, q)
+			Expect(err).To(MatchError(ErrInvalidSort))
+		})
+
+		It("rejects cursors for another sort", func() {
+			_, next, _ := Page(cfg, q)
+
+			q.Cursor = next
+			q.Sort = "email"
+
+			_, _, err := Page(cfg, q)
+			Expect(err).To(MatchError(ErrInvalidCursor))
 		})
 	})
 })
@@ -0,0 +1,297 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"gorm.io/gorm"
+)
+
+// The actors of changes not made by a user through the API.
+const (
+	ActorSystem = "system"
+	ActorImport = "import"
+	ActorSCIM   = "scim"
+)
+
+var ErrImmutableRevision = errors.New("Revisions cannot be changed")
+
+// Revision is a snapshot of a user taken whenever they are written.  Revisions
+// are never changed in place; together they are the user's history.
+type Revision struct {
+	gorm.Model
+	UserID        uint `gorm:"uniqueIndex:idx_user_revisions_user_number"`
+	Number        uint `gorm:"uniqueIndex:idx_user_revisions_user_number"`
+	Actor         string
+	ChangedFields string `gorm:"type:text;not null;default:'[]'"`
+	Snapshot      string `gorm:"type:text;not null"`
+}
+
+func (Revision) TableName() string {
+	return "user_revisions"
+}
+
+func (*Revision) BeforeUpdate(tx *gorm.DB) error {
+	return ErrImmutableRevision
+}
+
+// Snapshot is everything about a user worth keeping a history of; passwords
+// are left out.
+type Snapshot struct {
+	ID         string          `json:"id"`
+	Email      string          `json:"email"`
+	FirstName  string          `json:"firstName"`
+	LastName   string          `json:"lastName"`
+	Role       string          `json:"role"`
+	Avatar     string          `json:"avatar"`
+	Attributes json.RawMessage `json:"attributes"`
+	Version    uint            `json:"version"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+// untracked are the snapshot fields that change on every write, and so are
+// never reported as changed.
+var untracked = []string{"version", "updatedAt"}
+
+func NewSnapshot(u *User) Snapshot {
+	return Snapshot{
+		ID:         u.PublicID,
+		Email:      u.Email,
+		FirstName:  u.FirstName,
+		LastName:   u.LastName,
+		Role:       u.Role,
+		Avatar:     u.Avatar,
+		Attributes: attributesOf(u),
+		Version:    u.Version,
+		CreatedAt:  u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+	}
+}
+
+// User is the user as they were when the snapshot was taken.
+func (s *Snapshot) User(id uint) *User {
+	u := &User{
+		PublicID:   s.ID,
+		Email:      s.Email,
+		FirstName:  s.FirstName,
+		LastName:   s.LastName,
+		Role:       s.Role,
+		Avatar:     s.Avatar,
+		Attributes: string(s.Attributes),
+		Version:    s.Version,
+	}
+
+	u.ID = id
+	u.CreatedAt = s.CreatedAt
+	u.UpdatedAt = s.UpdatedAt
+
+	return u
+}
+
+// trackedFields is the snapshot as a document of its tracked fields.
+func trackedFields(s *Snapshot) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+
+	data, err := json.Marshal(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, f := range untracked {
+		delete(fields, f)
+	}
+
+	return fields, nil
+}
+
+// RecordRevision snapshots the user with the given primary key as just
+// written in tx, noting which fields differ from their previous revision.  An
+// empty actor records the user as having made the change themselves.
+func RecordRevision(tx *gorm.DB, id uint, actor string) error {
+	var (
+		u    User
+		prev Revision
+	)
+
+	if err := tx.Unscoped().Where("id = ?", id).Limit(1).Find(&u).Error; err != nil {
+		return err
+	}
+
+	result := tx.Where("user_id = ?", id).Order("number DESC").Limit(1).Find(&prev)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var before map[string]json.RawMessage
+
+	if result.RowsAffected > 0 {
+		var s Snapshot
+
+		if err := json.Unmarshal([]byte(prev.Snapshot), &s); err != nil {
+			return err
+		}
+
+		tracked, err := trackedFields(&s)
+
+		if err != nil {
+			return err
+		}
+
+		before = tracked
+	}
+
+	after := NewSnapshot(&u)
+	fields, err := trackedFields(&after)
+
+	if err != nil {
+		return err
+	}
+
+	changed := changedFields(before, fields)
+
+	if changed == nil {
+		changed = []string{}
+	}
+
+	snapshot, err := json.Marshal(&after)
+
+	if err != nil {
+		return err
+	}
+
+	names, err := json.Marshal(changed)
+
+	if err != nil {
+		return err
+	}
+
+	if actor == "" {
+		actor = u.PublicID
+	}
+
+	return tx.Create(&Revision{
+		UserID:        id,
+		Number:        prev.Number + 1,
+		Actor:         actor,
+		ChangedFields: string(names),
+		Snapshot:      string(snapshot),
+	}).Error
+}
+
+// BackfillRevisions records a first revision of every user written before
+// revisions were introduced, returning the number of users recorded.
+func BackfillRevisions(cfg *config.Config) (int, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return 0, err
+	}
+
+	var ids []uint
+
+	result := db.Model(&User{}).
+		Unscoped().
+		Where("id NOT IN (SELECT user_id FROM user_revisions)").
+		Pluck("id", &ids)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	for _, id := range ids {
+		if err := RecordRevision(db.DB, id, ActorSystem); err != nil {
+			log.Printf("Unable to record revision of User %d: %e", id, err)
+			return 0, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+// History returns up to limit of the user's revisions, newest first, starting
+// before the revision numbered by the cursor when one is given.  The cursor
+// for the following page is empty on the last one.
+func History(cfg *config.Config, id uint, cursor string, limit int) (*[]Revision, string, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, "", err
+	}
+
+	query := db.Where("user_id = ?", id)
+
+	if cursor != "" {
+		before, err := strconv.ParseUint(cursor, 10, 64)
+
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+
+		query = query.Where("number < ?", before)
+	}
+
+	var revs []Revision
+
+	result := query.Order("number DESC").Limit(limit + 1).Find(&revs)
+
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	next := ""
+
+	if len(revs) > limit {
+		revs = revs[:limit]
+		next = strconv.FormatUint(uint64(revs[limit-1].Number), 10)
+	}
+
+	return &revs, next, nil
+}
+
+// At returns the user with the given primary key as they were at the time,
+// or ErrNotFound when they did not exist yet.
+func At(cfg *config.Config, id uint, t time.Time) (*User, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	var rev Revision
+
+	result := db.Where("user_id = ? AND created_at <= ?", id, t).
+		Order("number DESC").
+		Limit(1).
+		Find(&rev)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	var s Snapshot
+
+	if err := json.Unmarshal([]byte(rev.Snapshot), &s); err != nil {
+		return nil, err
+	}
+
+	return s.User(id), nil
+}
@@ -0,0 +1,121 @@
+package users
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("users/revisions.go", func() {
+	var (
+		cfg *config.Config
+		u   *User
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		u, _ = Create(cfg, &User{Email: faker.Email(), FirstName: "Arthur"})
+	})
+
+	changed := func(rev Revision) []string {
+		var fields []string
+
+		json.Unmarshal([]byte(rev.ChangedFields), &fields)
+
+		return fields
+	}
+
+	Describe("RecordRevision()", func() {
+		It("records the user's creation by themselves", func() {
+			revs, _, err := History(cfg, u.ID, "", 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*revs).To(HaveLen(1))
+
+			rev := (*revs)[0]
+			Expect(rev.Number).To(BeNumerically("==", 1))
+			Expect(rev.Actor).To(Equal(u.PublicID))
+			Expect(changed(rev)).To(ContainElements("email", "firstName"))
+			Expect(rev.Snapshot).NotTo(ContainSubstring("assword"))
+		})
+
+		It("notes only the fields that changed", func() {
+			Update(cfg, &User{Email: u.Email, FirstName: "Zaphod"})
+
+			revs, _, _ := History(cfg, u.ID, "", 10)
+			Expect(*revs).To(HaveLen(2))
+			Expect((*revs)[0].Number).To(BeNumerically("==", 2))
+			Expect(changed((*revs)[0])).To(Equal([]string{"firstName"}))
+		})
+
+		It("records who else made the change", func() {
+			SetRole(cfg, u.Email, RoleAdmin)
+
+			revs, _, _ := History(cfg, u.ID, "", 1)
+			Expect((*revs)[0].Actor).To(Equal(ActorSystem))
+			Expect(changed((*revs)[0])).To(Equal([]string{"role"}))
+		})
+	})
+
+	Describe("Revision", func() {
+		It("cannot be changed", func() {
+			db, _ := internal.NewConnection(cfg)
+
+			var rev Revision
+
+			db.Where("user_id = ?", u.ID).First(&rev)
+
+			err := db.Model(&rev).Update("actor", "someone").Error
+			Expect(err).To(Equal(ErrImmutableRevision))
+		})
+	})
+
+	Describe("History()", func() {
+		BeforeEach(func() {
+			Update(cfg, &User{Email: u.Email, FirstName: "Zaphod"})
+			Update(cfg, &User{Email: u.Email, FirstName: "Ford"})
+		})
+
+		It("pages through the revisions, newest first", func() {
+			revs, next, err := History(cfg, u.ID, "", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*revs).To(HaveLen(2))
+			Expect((*revs)[0].Number).To(BeNumerically("==", 3))
+			Expect(next).To(Equal("2"))
+
+			revs, next, _ = History(cfg, u.ID, next, 2)
+			Expect(*revs).To(HaveLen(1))
+			Expect((*revs)[0].Number).To(BeNumerically("==", 1))
+			Expect(next).To(BeEmpty())
+		})
+
+		It("refuses malformed cursors", func() {
+			_, _, err := History(cfg, u.ID, "latest", 2)
+			Expect(err).To(Equal(ErrInvalidCursor))
+		})
+	})
+
+	Describe("At()", func() {
+		It("returns the user as they were at the time", func() {
+			then := time.Now()
+			Update(cfg, &User{Email: u.Email, FirstName: "Zaphod"})
+
+			was, err := At(cfg, u.ID, then)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(was.FirstName).To(Equal("Arthur"))
+			Expect(was.PublicID).To(Equal(u.PublicID))
+
+			is, _ := At(cfg, u.ID, time.Now())
+			Expect(is.FirstName).To(Equal("Zaphod"))
+		})
+
+		It("does not find users before they were created", func() {
+			_, err := At(cfg, u.ID, u.CreatedAt.Add(-time.Hour))
+			Expect(err).To(Equal(ErrNotFound))
+		})
+	})
+})
@@ -0,0 +1,180 @@
+package users
+
+import (
+	"errors"
+	"html"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"gorm.io/gorm"
+)
+
+const (
+	markStart = "<mark>"
+	markEnd   = "</mark>"
+)
+
+// The search implementations: FTS5 when the SQLite linked in supports it,
+// which the driver's does under the sqlite_fts5 build tag, and LIKE queries
+// scored here otherwise.
+const (
+	SearchIndexFTS5 = "fts5"
+	SearchIndexLike = "like"
+)
+
+var ErrEmptySearch = errors.New("A search query is required")
+
+// SearchResult is a user matching a search, with a score (higher is better)
+// and a snippet of the best matching field with the matches marked.  The
+// snippet is HTML, the field's text escaped.
+type SearchResult struct {
+	User    `gorm:"embedded"`
+	Score   float64
+	Snippet string
+}
+
+var (
+	index     string
+	indexOnce sync.Once
+)
+
+func init() {
+	// Writes to users fail on the triggers an FTS5 build installs when the
+	// SQLite linked in has no FTS5, so the index is brought in line with it
+	// before anything else touches the database.
+	internal.OnConnect(func(db *gorm.DB) error {
+		if !db.Migrator().HasTable(&User{}) {
+			return nil
+		}
+
+		_, err := ensureSearchIndex(db)
+
+		return err
+	})
+}
+
+// searchIndex returns the search implementation the SQLite linked in
+// supports.
+func searchIndex(db *gorm.DB) string {
+	indexOnce.Do(func() {
+		var fts5 bool
+
+		if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error; err != nil {
+			log.Printf("Unable to detect FTS5 support: %e", err)
+		}
+
+		index = SearchIndexLike
+
+		if fts5 {
+			index = SearchIndexFTS5
+		}
+	})
+
+	return index
+}
+
+// searchTerms splits a query into the terms that must all match, each as a
+// prefix of a word in the user's names or email.
+func searchTerms(q string) []string {
+	return strings.Fields(q)
+}
+
+// searchScope is the condition narrowing a search to the users of a tenant,
+// if given, and to the one user the viewer may see, if they may not see all.
+func searchScope(cfg *config.Config, tenant *uint, viewer *User) (string, []interface{}) {
+	conds, args := []string{"users.deleted_at IS NULL"}, []interface{}{}
+
+	if tenant != nil {
+		cond, targs := tenantCondition(*tenant)
+		conds, args = append(conds, cond), append(args, targs...)
+	}
+
+	if id := ScopeQuery(cfg, viewer, Query{}).ID; id != 0 {
+		conds, args = append(conds, "users.id = ?"), append(args, id)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// Search finds the users matching every term of the query, best first.  A
+// non-nil tenant limits the results to the users of that tenant, and a
+// non-nil viewer to the users they may see.
+func Search(cfg *config.Config, q string, limit int, tenant *uint, viewer *User) (*[]SearchResult, error) {
+	terms := searchTerms(q)
+
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return nil, err
+	}
+
+	scope, args := searchScope(cfg, tenant, viewer)
+
+	var results []SearchResult
+
+	if searchIndex(db.DB) == SearchIndexFTS5 {
+		results, err = searchFTS5(db.DB, terms, limit, scope, args)
+	} else {
+		results, err = searchLike(db.DB, terms, limit, scope, args)
+	}
+
+	if err != nil {
+		log.Printf("Unable to search users: %e", err)
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// ensureSearchIndex prepares the index of the search implementation the
+// SQLite linked in supports, returning which that is.
+func ensureSearchIndex(db *gorm.DB) (string, error) {
+	if searchIndex(db) == SearchIndexFTS5 {
+		return SearchIndexFTS5, ensureFTS5Index(db)
+	}
+
+	return SearchIndexLike, dropFTS5Triggers(db)
+}
+
+// EnsureSearchIndex prepares the search index, returning the search
+// implementation in use.
+func EnsureSearchIndex(cfg *config.Config) (string, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return "", err
+	}
+
+	return ensureSearchIndex(db.DB)
+}
+
+// RebuildSearchIndex re-indexes every user.
+func RebuildSearchIndex(cfg *config.Config) error {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return err
+	}
+
+	if searchIndex(db.DB) != SearchIndexFTS5 {
+		return nil
+	}
+
+	return rebuildFTS5Index(db.DB)
+}
+
+// escapeSnippet escapes the text of a snippet whose matches are delimited by
+// the given markers, and marks them.
+func escapeSnippet(s, start, end string) string {
+	return strings.NewReplacer(start, markStart, end, markEnd).Replace(html.EscapeString(s))
+}
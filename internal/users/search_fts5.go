@@ -0,0 +1,119 @@
+package users
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// The index is an external content table over users, kept in sync by
+// triggers so that every write path, including raw updates, is covered.
+var searchIndexStatements = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts5(
+		first_name, last_name, email,
+		content='users', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS users_fts_insert AFTER INSERT ON users BEGIN
+		INSERT INTO users_fts(rowid, first_name, last_name, email)
+		VALUES (new.id, new.first_name, new.last_name, new.email);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS users_fts_delete AFTER DELETE ON users BEGIN
+		INSERT INTO users_fts(users_fts, rowid, first_name, last_name, email)
+		VALUES ('delete', old.id, old.first_name, old.last_name, old.email);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS users_fts_update AFTER UPDATE ON users BEGIN
+		INSERT INTO users_fts(users_fts, rowid, first_name, last_name, email)
+		VALUES ('delete', old.id, old.first_name, old.last_name, old.email);
+		INSERT INTO users_fts(rowid, first_name, last_name, email)
+		VALUES (new.id, new.first_name, new.last_name, new.email);
+	END`,
+}
+
+var searchIndexTriggers = []string{"users_fts_insert", "users_fts_delete", "users_fts_update"}
+
+// The markers snippet() delimits matches with, replaced by markStart and
+// markEnd once the snippet is escaped.  A name holding them gains no more
+// than a stray mark.
+const (
+	snippetStart = "\x02"
+	snippetEnd   = "\x03"
+)
+
+// ensureFTS5Index creates the index and its triggers, rebuilding it when the
+// triggers were missing and writes may not have been indexed.
+func ensureFTS5Index(db *gorm.DB) error {
+	var triggers int64
+
+	err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ?", searchIndexTriggers).
+		Scan(&triggers).Error
+
+	if err != nil {
+		return err
+	}
+
+	if triggers == int64(len(searchIndexTriggers)) {
+		return nil
+	}
+
+	for _, stmt := range searchIndexStatements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	return rebuildFTS5Index(db)
+}
+
+func rebuildFTS5Index(db *gorm.DB) error {
+	return db.Exec("INSERT INTO users_fts(users_fts) VALUES ('rebuild')").Error
+}
+
+// dropFTS5Triggers drops the triggers an FTS5 build installs, since they
+// would make every write to users fail without FTS5 support.  The index is
+// rebuilt once a build with it runs again.
+func dropFTS5Triggers(db *gorm.DB) error {
+	for _, t := range searchIndexTriggers {
+		if err := db.Exec("DROP TRIGGER IF EXISTS " + t).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchExpression quotes each term, so that FTS5 query syntax in user input
+// is matched literally, and matches it as a prefix.
+func matchExpression(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+
+	for _, t := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(t, `"`, `""`)+`"*`)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+func searchFTS5(db *gorm.DB, terms []string, limit int, scope string, args []interface{}) ([]SearchResult, error) {
+	var results []SearchResult
+
+	params := append([]interface{}{snippetStart, snippetEnd, matchExpression(terms)}, args...)
+
+	err := db.Raw(`
+		SELECT users.*,
+			-bm25(users_fts) AS score,
+			snippet(users_fts, -1, ?, ?, '…', 8) AS snippet
+		FROM users_fts
+		JOIN users ON users.id = users_fts.rowid
+		WHERE users_fts MATCH ? AND `+scope+`
+		ORDER BY bm25(users_fts), users.id
+		LIMIT ?`,
+		append(params, limit)...,
+	).Scan(&results).Error
+
+	for i := range results {
+		results[i].Snippet = escapeSnippet(results[i].Snippet, snippetStart, snippetEnd)
+	}
+
+	return results, err
+}
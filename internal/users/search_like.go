@@ -0,0 +1,138 @@
+package users
+
+import (
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"gorm.io/gorm"
+)
+
+// searchLike finds the users with every term in one of their names or
+// email, scoring them here, for SQLite without FTS5.
+func searchLike(db *gorm.DB, terms []string, limit int, scope string, args []interface{}) ([]SearchResult, error) {
+	var us []User
+
+	query := db.Model(&User{}).Where(scope, args...)
+
+	for _, t := range terms {
+		p := "%" + internal.EscapeLike(strings.ToLower(t)) + "%"
+
+		query = query.Where(
+			`LOWER(first_name) LIKE ? ESCAPE '\' OR LOWER(last_name) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\'`,
+			p, p, p,
+		)
+	}
+
+	if err := query.Find(&us).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(us))
+
+	for _, u := range us {
+		score, snippet := scoreUser(&u, terms)
+		results = append(results, SearchResult{User: u, Score: score, Snippet: snippet})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return results[i].ID < results[j].ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// scoreUser favours terms that start a word over those found within one, and
+// marks the matches in the best scoring field.
+func scoreUser(u *User, terms []string) (float64, string) {
+	var (
+		total, best float64
+		snippet     string
+	)
+
+	for _, field := range []string{u.FirstName, u.LastName, u.Email} {
+		var score float64
+
+		lower := strings.ToLower(field)
+
+		for _, t := range terms {
+			t = strings.ToLower(t)
+
+			switch {
+			case strings.HasPrefix(lower, t) || strings.Contains(lower, " "+t):
+				score += 2
+			case strings.Contains(lower, t):
+				score++
+			}
+		}
+
+		total += score
+
+		if score > best {
+			best = score
+			snippet = mark(field, terms)
+		}
+	}
+
+	return total, snippet
+}
+
+// mark escapes the field as HTML and wraps each case-insensitive occurrence
+// of the terms in it.  The field is left unmarked in the rare case that
+// lowering it changes its length.
+func mark(field string, terms []string) string {
+	lower := strings.ToLower(field)
+
+	if len(lower) != len(field) {
+		return html.EscapeString(field)
+	}
+
+	marked := make([]bool, len(field))
+
+	for _, t := range terms {
+		t = strings.ToLower(t)
+
+		for i := 0; t != "" && i+len(t) <= len(lower); {
+			j := strings.Index(lower[i:], t)
+
+			if j < 0 {
+				break
+			}
+
+			for k := i + j; k < i+j+len(t); k++ {
+				marked[k] = true
+			}
+
+			i += j + len(t)
+		}
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(field); {
+		j := i
+
+		for j < len(field) && marked[j] == marked[i] {
+			j++
+		}
+
+		if marked[i] {
+			b.WriteString(markStart + html.EscapeString(field[i:j]) + markEnd)
+		} else {
+			b.WriteString(html.EscapeString(field[i:j]))
+		}
+
+		i = j
+	}
+
+	return b.String()
+}
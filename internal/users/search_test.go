@@ -0,0 +1,131 @@
+package users
+
+import (
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("users/search.go", func() {
+	var (
+		cfg                 *config.Config
+		name                string
+		first, second, gone *User
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		_, err := EnsureSearchIndex(cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		name = "Quixote" + faker.UUIDDigit()[:12]
+
+		first, _ = Create(cfg, &User{Email: faker.Email(), FirstName: "Alonso", LastName: name})
+		second, _ = Create(cfg, &User{Email: faker.Email(), FirstName: "Sancho", LastName: "Panza" + name})
+		gone, _ = Create(cfg, &User{Email: faker.Email(), FirstName: "Alonso", LastName: name + "x"})
+
+		db, _ := internal.NewConnection(cfg)
+		db.Delete(gone)
+	})
+
+	AfterEach(func() {
+		db, _ := internal.NewConnection(cfg)
+		db.Unscoped().Delete(gone)
+	})
+
+	Describe("Search()", func() {
+		It("matches prefixes of names", func() {
+			rs, err := Search(cfg, name[:10], 10, nil, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*rs).To(ContainElement(HaveField("User.ID", first.ID)))
+		})
+
+		It("requires every term to match", func() {
+			rs, _ := Search(cfg, "alonso "+name, 10, nil, nil)
+
+			Expect(*rs).To(HaveLen(1))
+			Expect((*rs)[0].ID).To(Equal(first.ID))
+		})
+
+		It("matches email addresses", func() {
+			rs, _ := Search(cfg, first.Email, 10, nil, nil)
+
+			Expect(*rs).To(ContainElement(HaveField("User.ID", first.ID)))
+		})
+
+		It("ranks better matches first", func() {
+			rs, _ := Search(cfg, name, 10, nil, nil)
+
+			Expect(*rs).NotTo(BeEmpty())
+			Expect((*rs)[0].ID).To(Equal(first.ID))
+		})
+
+		It("marks the matches in a snippet", func() {
+			rs, _ := Search(cfg, name, 10, nil, nil)
+
+			Expect((*rs)[0].Snippet).To(ContainSubstring("<mark>"))
+		})
+
+		It("excludes deleted users", func() {
+			rs, _ := Search(cfg, name, 10, nil, nil)
+
+			Expect(*rs).NotTo(ContainElement(HaveField("User.ID", gone.ID)))
+		})
+
+		It("finds changes to users", func() {
+			Update(cfg, &User{Email: second.Email, FirstName: "Rocinante" + name})
+
+			rs, _ := Search(cfg, "rocinante"+name, 10, nil, nil)
+
+			Expect(*rs).To(HaveLen(1))
+			Expect((*rs)[0].ID).To(Equal(second.ID))
+		})
+
+		It("treats query syntax literally", func() {
+			_, err := Search(cfg, `"* OR % _ NEAR(`, 10, nil, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("limits the results", func() {
+			rs, _ := Search(cfg, name, 1, nil, nil)
+
+			Expect(*rs).To(HaveLen(1))
+		})
+
+		It("escapes the fields in snippets", func() {
+			Create(cfg, &User{Email: faker.Email(), FirstName: "Dulcinea", LastName: name + "<script>"})
+
+			rs, _ := Search(cfg, name+"<script>", 10, nil, nil)
+
+			Expect(*rs).To(HaveLen(1))
+			Expect((*rs)[0].Snippet).To(ContainSubstring("&lt;script"))
+			Expect((*rs)[0].Snippet).NotTo(ContainSubstring("<script>"))
+		})
+
+		It("only finds the users the viewer may see before limiting", func() {
+			cfg.Set("users.visibility", VisibilitySelf)
+			viewer, _ := Create(cfg, &User{Email: faker.Email(), FirstName: "Teresa", LastName: name})
+
+			rs, _ := Search(cfg, name, 1, nil, viewer)
+
+			Expect(*rs).To(HaveLen(1))
+			Expect((*rs)[0].ID).To(Equal(viewer.ID))
+		})
+
+		It("requires a query", func() {
+			_, err := Search(cfg, "  ", 10, nil, nil)
+
+			Expect(err).To(MatchError(ErrEmptySearch))
+		})
+	})
+
+	Describe("RebuildSearchIndex()", func() {
+		It("succeeds", func() {
+			Expect(RebuildSearchIndex(cfg)).To(Succeed())
+		})
+	})
+})
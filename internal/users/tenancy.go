@@ -0,0 +1,66 @@
+package users
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/adamstrickland/dapper-api/internal"
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"gorm.io/gorm"
+)
+
+// tenantCondition selects the users of a tenant: the members of the
+// organization with the given ID, or, for zero, the users who belong to no
+// organization.  Memberships are kept by the organizations package.
+func tenantCondition(tenant uint) (string, []interface{}) {
+	if tenant == 0 {
+		return "users.id NOT IN (SELECT user_id FROM memberships WHERE deleted_at IS NULL)", nil
+	}
+
+	return "users.id IN (SELECT user_id FROM memberships WHERE organization_id = ? AND deleted_at IS NULL)", []interface{}{tenant}
+}
+
+// tenantScope narrows a query for users to a tenant; a nil tenant leaves it
+// unscoped.
+func tenantScope(query *gorm.DB, tenant *uint) *gorm.DB {
+	if tenant == nil {
+		return query
+	}
+
+	cond, args := tenantCondition(*tenant)
+
+	return query.Where(cond, args...)
+}
+
+// TenantScope narrows a query for users to a tenant.
+func TenantScope(query *gorm.DB, tenant uint) *gorm.DB {
+	return tenantScope(query, &tenant)
+}
+
+// requestTenant scopes a query to the tenant the request acts in.
+func requestTenant(r *http.Request) *uint {
+	tenant := security.RequestTenant(r)
+
+	return &tenant
+}
+
+// InTenant reports whether the user with the given ID belongs to the tenant.
+func InTenant(cfg *config.Config, id uint, tenant uint) (bool, error) {
+	db, err := internal.NewConnection(cfg)
+
+	if err != nil {
+		log.Printf("Unable to connect to database: %e", err)
+		return false, err
+	}
+
+	var count int64
+
+	result := tenantScope(db.Model(&User{}).Where("users.id = ?", id), &tenant).Count(&count)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
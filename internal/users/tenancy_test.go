@@ -0,0 +1,121 @@
+package users_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/adamstrickland/dapper-api/internal/config"
+	"github.com/adamstrickland/dapper-api/internal/organizations"
+	"github.com/adamstrickland/dapper-api/internal/security"
+	"github.com/adamstrickland/dapper-api/internal/users"
+	"github.com/bxcodec/faker/v3"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("users/tenancy.go", func() {
+	var (
+		cfg                    *config.Config
+		rr                     *httptest.ResponseRecorder
+		ford, arthur, loner    *users.User
+		heartOfGold, vogsphere *organizations.Organization
+		name                   string
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		rr = httptest.NewRecorder()
+		name = "Tenant" + faker.Username()
+
+		ford, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: name})
+		arthur, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: name})
+		loner, _ = users.Create(cfg, &users.User{Email: faker.Email(), FirstName: name})
+
+		heartOfGold, _ = organizations.Create(cfg, &organizations.Organization{Name: "Heart of Gold"}, ford)
+		vogsphere, _ = organizations.Create(cfg, &organizations.Organization{Name: "Vogsphere"}, arthur)
+	})
+
+	request := func(path string, viewer *users.User, tenant uint) *http.Request {
+		r, _ := http.NewRequest("GET", path, nil)
+
+		token, _ := security.NewTokenForSubject(cfg, viewer.PublicID)
+		r.Header.Set(cfg.GetString("tokenHeader"), token)
+
+		return security.WithTenant(r, tenant, "")
+	}
+
+	emailsIn := func(body []byte, key string) []string {
+		var result map[string][]map[string]interface{}
+
+		json.Unmarshal(body, &result)
+
+		emails := []string{}
+
+		for _, item := range result[key] {
+			if u, ok := item["user"].(map[string]interface{}); ok {
+				item = u
+			}
+
+			emails = append(emails, item["email"].(string))
+		}
+
+		return emails
+	}
+
+	Describe("InTenant()", func() {
+		It("is true for members of the organization", func() {
+			ok, err := users.InTenant(cfg, ford.ID, heartOfGold.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+		})
+
+		It("is false for members of another organization", func() {
+			ok, _ := users.InTenant(cfg, arthur.ID, heartOfGold.ID)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("puts users in no organization in tenant zero", func() {
+			ok, _ := users.InTenant(cfg, loner.ID, 0)
+			Expect(ok).To(BeTrue())
+
+			ok, _ = users.InTenant(cfg, ford.ID, 0)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("listing users", func() {
+		It("only returns users of the request's tenant", func() {
+			users.NewGetHandler(cfg)(rr, request("/users?name="+name, ford, heartOfGold.ID))
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(emailsIn(rr.Body.Bytes(), "users")).To(ConsistOf(ford.Email))
+		})
+	})
+
+	Describe("searching users", func() {
+		It("only finds users of the request's tenant", func() {
+			users.NewSearchHandler(cfg)(rr, request("/users/search?q="+name, arthur, vogsphere.ID))
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(emailsIn(rr.Body.Bytes(), "results")).To(ConsistOf(arthur.Email))
+		})
+	})
+
+	Describe("getting a user", func() {
+		It("hides users of another tenant", func() {
+			r := mux.SetURLVars(request("/users/"+arthur.PublicID, ford, heartOfGold.ID), map[string]string{"id": arthur.PublicID})
+			users.NewGetOneHandler(cfg)(rr, r)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("finds users of the same tenant", func() {
+			r := mux.SetURLVars(request("/users/"+ford.PublicID, ford, heartOfGold.ID), map[string]string{"id": ford.PublicID})
+			users.NewGetOneHandler(cfg)(rr, r)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,30 @@
+package users
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+)
+
+const (
+	// VisibilityAll lets any authenticated user see every other user.
+	VisibilityAll = "all"
+	// VisibilitySelf limits non-admins to their own record.
+	VisibilitySelf = "self"
+)
+
+// CanView reports whether the viewer may see the user's record.
+func CanView(cfg *config.Config, viewer, u *User) bool {
+	if viewer.ID == u.ID || viewer.IsAdmin() {
+		return true
+	}
+
+	return cfg.GetString("users.visibility") == VisibilityAll
+}
+
+// ScopeQuery narrows a list query to the users the viewer may see.
+func ScopeQuery(cfg *config.Config, viewer *User, q Query) Query {
+	if viewer != nil && !viewer.IsAdmin() && cfg.GetString("users.visibility") != VisibilityAll {
+		q.ID = viewer.ID
+	}
+
+	return q
+}
@@ -0,0 +1,59 @@
+package users
+
+import (
+	"github.com/adamstrickland/dapper-api/internal/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("users/visibility.go", func() {
+	var (
+		cfg                  *config.Config
+		viewer, other, admin *User
+	)
+
+	BeforeEach(func() {
+		cfg = config.Configuration()
+		viewer = &User{Role: RoleUser}
+		viewer.ID = 1
+		other = &User{Role: RoleUser}
+		other.ID = 2
+		admin = &User{Role: RoleAdmin}
+		admin.ID = 3
+	})
+
+	Describe("CanView()", func() {
+		When("everyone is visible", func() {
+			BeforeEach(func() {
+				cfg.Set("users.visibility", VisibilityAll)
+			})
+
+			It("allows viewing others", func() {
+				Expect(CanView(cfg, viewer, other)).To(BeTrue())
+			})
+		})
+
+		When("users may only see themselves", func() {
+			BeforeEach(func() {
+				cfg.Set("users.visibility", VisibilitySelf)
+			})
+
+			It("allows viewing oneself", func() {
+				Expect(CanView(cfg, viewer, viewer)).To(BeTrue())
+			})
+
+			It("does not allow viewing others", func() {
+				Expect(CanView(cfg, viewer, other)).To(BeFalse())
+			})
+
+			It("allows admins to view others", func() {
+				Expect(CanView(cfg, admin, other)).To(BeTrue())
+			})
+
+			It("narrows lists to the viewer", func() {
+				Expect(ScopeQuery(cfg, viewer, Query{}).ID).To(Equal(viewer.ID))
+				Expect(ScopeQuery(cfg, admin, Query{}).ID).To(BeZero())
+			})
+		})
+	})
+})
@@ -0,0 +1,185 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	CodeRequired = "required"
+	CodeEmail    = "email"
+	CodeTooShort = "too_short"
+	CodeTooLong  = "too_long"
+	CodeInvalid  = "invalid"
+	CodeReadOnly = "read_only"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors collects every failed rule; it is nil when validation succeeds.
+type Errors []FieldError
+
+func (es Errors) Error() string {
+	msgs := make([]string, 0, len(es))
+
+	for _, e := range es {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", e.Field, e.Message))
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+type errorsPayload struct {
+	Errors Errors `json:"errors"`
+}
+
+func fieldName(f reflect.StructField) string {
+	if tag := f.Tag.Get("json"); tag != "" {
+		if name := strings.Split(tag, ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return f.Name
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return false
+	}
+
+	domain := s[strings.LastIndex(s, "@")+1:]
+
+	return strings.Contains(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// check applies a single rule (e.g. "required" or "max=100") to a value.
+func check(field, rule, s string) *FieldError {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	switch name {
+	case "required":
+		if s == "" {
+			return &FieldError{field, CodeRequired, fmt.Sprintf("%s is required", field)}
+		}
+	case "email":
+		if s != "" && !isEmail(s) {
+			return &FieldError{field, CodeEmail, fmt.Sprintf("%s must be a valid email address", field)}
+		}
+	case "min":
+		n, _ := strconv.Atoi(arg)
+
+		if s != "" && utf8.RuneCountInString(s) < n {
+			return &FieldError{field, CodeTooShort, fmt.Sprintf("%s must be at least %d characters", field, n)}
+		}
+	case "max":
+		n, _ := strconv.Atoi(arg)
+
+		if utf8.RuneCountInString(s) > n {
+			return &FieldError{field, CodeTooLong, fmt.Sprintf("%s must be at most %d characters", field, n)}
+		}
+	}
+
+	return nil
+}
+
+// Validate normalizes the string fields of the struct pointed to by v and
+// checks them against the rules in their "validate" tags, e.g.
+//
+//	Email string `json:"email" validate:"required,email,max=254"`
+//
+// Strings are trimmed and converted to Unicode NFC unless tagged "raw".
+// Only the first failing rule of each field is reported.
+func Validate(v interface{}) Errors {
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		log.Printf("Unable to validate %T: not a pointer to a struct", v)
+		return Errors{{"", CodeInvalid, "Payload could not be validated"}}
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	var errs Errors
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		fv := rv.Field(i)
+
+		if f.PkgPath != "" || fv.Kind() != reflect.String {
+			continue
+		}
+
+		rules := strings.Split(f.Tag.Get("validate"), ",")
+		s := fv.String()
+
+		if !hasRule(rules, "raw") {
+			s = norm.NFC.String(strings.TrimSpace(s))
+			fv.SetString(s)
+		}
+
+		for _, rule := range rules {
+			if rule == "" || rule == "raw" {
+				continue
+			}
+
+			if fe := check(fieldName(f), rule, s); fe != nil {
+				errs = append(errs, *fe)
+				break
+			}
+		}
+	}
+
+	return errs
+}
+
+func hasRule(rules []string, rule string) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteErrors responds with 422 and the list of field errors.
+func WriteErrors(w http.ResponseWriter, errs Errors) {
+	var data bytes.Buffer
+
+	err := json.NewEncoder(&data).Encode(&errorsPayload{
+		Errors: errs,
+	})
+
+	if err != nil {
+		log.Printf("Unable to generate payload: %e", err)
+		http.Error(w, errs.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+
+	_, err = w.Write(data.Bytes())
+
+	if err != nil {
+		log.Printf("Unable to write body: %e", err)
+	}
+}
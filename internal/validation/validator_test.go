@@ -0,0 +1,128 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("validation/validator.go", func() {
+	type payload struct {
+		Email    string `json:"email" validate:"required,email,max=254"`
+		Password string `json:"password" validate:"raw,required,min=8"`
+		Name     string `json:"name" validate:"max=5"`
+		Count    int    `json:"count"`
+	}
+
+	var (
+		p    payload
+		errs Errors
+	)
+
+	BeforeEach(func() {
+		p = payload{
+			Email:    "ford@example.com",
+			Password: "p@ssw0rd",
+		}
+	})
+
+	JustBeforeEach(func() {
+		errs = Validate(&p)
+	})
+
+	Describe("Validate()", func() {
+		When("the payload is valid", func() {
+			It("returns no errors", func() {
+				Expect(errs).To(BeNil())
+			})
+		})
+
+		When("a required field is missing", func() {
+			BeforeEach(func() {
+				p.Email = "   "
+			})
+
+			It("reports it by its JSON name", func() {
+				Expect(errs).To(ConsistOf(HaveField("Field", "email")))
+				Expect(errs[0].Code).To(Equal(CodeRequired))
+			})
+		})
+
+		When("an email is malformed", func() {
+			BeforeEach(func() {
+				p.Email = "ford@"
+			})
+
+			It("reports it", func() {
+				Expect(errs[0].Code).To(Equal(CodeEmail))
+			})
+		})
+
+		When("a field is too short", func() {
+			BeforeEach(func() {
+				p.Password = "short"
+			})
+
+			It("reports it", func() {
+				Expect(errs[0].Code).To(Equal(CodeTooShort))
+			})
+		})
+
+		When("a field is too long", func() {
+			BeforeEach(func() {
+				p.Name = "Zaphod"
+			})
+
+			It("reports it", func() {
+				Expect(errs[0].Code).To(Equal(CodeTooLong))
+			})
+		})
+
+		When("strings need normalizing", func() {
+			BeforeEach(func() {
+				p.Name = " José "
+				p.Password = " p@ssw0rd "
+			})
+
+			It("trims and composes them", func() {
+				Expect(p.Name).To(Equal("José"))
+			})
+
+			It("counts characters, not bytes", func() {
+				Expect(errs).To(BeNil())
+			})
+
+			It("leaves raw fields alone", func() {
+				Expect(p.Password).To(Equal(" p@ssw0rd "))
+			})
+		})
+	})
+
+	Describe("WriteErrors()", func() {
+		var rr *httptest.ResponseRecorder
+
+		BeforeEach(func() {
+			p.Email = strings.Repeat("x", 300)
+		})
+
+		JustBeforeEach(func() {
+			rr = httptest.NewRecorder()
+			WriteErrors(rr, errs)
+		})
+
+		It("is unprocessable", func() {
+			Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+
+		It("returns machine-readable errors", func() {
+			var result map[string][]FieldError
+			Expect(json.Unmarshal(rr.Body.Bytes(), &result)).NotTo(HaveOccurred())
+			Expect(result["errors"]).To(HaveLen(1))
+			Expect(result["errors"][0].Field).To(Equal("email"))
+		})
+	})
+})